@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"strconv"
@@ -49,41 +50,103 @@ func (c *Console) Start() {
 		}
 	}
 
-	//1. load the titles JSON object
-	fmt.Println("Downloading latest switch titles json file")
-	progressBar = progressbar.New(2)
+	offlineMode := settingsObj.OfflineMode
+	if c.consoleFlags.Offline.IsSet() {
+		offlineMode = c.consoleFlags.Offline.Bool()
+	}
+
+	if c.consoleFlags.Import.IsSet() && c.consoleFlags.Import.String() != "" {
+		info, err := db.ImportTitlesSnapshot(c.baseFolder, c.consoleFlags.Import.String())
+		if err != nil {
+			fmt.Printf("Failed to import titles database snapshot %v - %v\n", c.consoleFlags.Import.String(), err)
+			zap.S().Errorf("Failed to import titles database snapshot %v - %v\n", c.consoleFlags.Import.String(), err)
+			return
+		}
+		fmt.Printf("Imported %v\n", info)
+	}
 
-	filename := filepath.Join(c.baseFolder, settings.TITLE_JSON_FILENAME)
-	titleFile, titlesEtag, err := db.LoadAndUpdateFile(settingsObj.TitlesJsonUrl, filename, settingsObj.TitlesEtag)
+	localDbManager, err := db.NewLocalSwitchDBManager(c.baseFolder)
 	if err != nil {
-		fmt.Printf("title json file doesn't exist\n")
+		fmt.Printf("failed to create local files db :%v\n", err)
 		return
 	}
-	settingsObj.TitlesEtag = titlesEtag
-	progressBar.Add(1)
-	//2. load the versions JSON object
-	filename = filepath.Join(c.baseFolder, settings.VERSIONS_JSON_FILENAME)
-	versionsFile, versionsEtag, err := db.LoadAndUpdateFile(settingsObj.VersionsJsonUrl, filename, settingsObj.VersionsEtag)
-	if err != nil {
-		fmt.Printf("version json file doesn't exist\n")
+	defer localDbManager.Close()
+
+	if c.consoleFlags.Purge.IsSet() {
+		purged, size, err := process.PurgeTrash(c.baseFolder, c.consoleFlags.Purge.String())
+		if err != nil {
+			fmt.Printf("Failed to purge the trash - %v\n", err)
+			zap.S().Errorf("Failed to purge the trash - %v\n", err)
+			return
+		}
+		fmt.Printf("Purged %v runs (%v MB) from %v\n", purged, size/(1024*1024), process.TrashFolder(c.baseFolder))
+		return
+	}
+
+	if c.consoleFlags.Undo.Bool() {
+		progressBar = progressbar.New(2000)
+		info, err := process.UndoLastRun(c.baseFolder, c)
+		progressBar.Finish()
+		if info != nil {
+			fmt.Printf("\nUndid run %v (%v changes)\n", info.OperationId, info.Entries)
+		}
+		if err != nil {
+			fmt.Printf("\n%v\n", err)
+			zap.S().Error(err)
+		}
 		return
 	}
-	settingsObj.VersionsEtag = versionsEtag
-	progressBar.Add(1)
+
+	if c.consoleFlags.Apply.IsSet() && c.consoleFlags.Apply.String() != "" {
+		plan, err := process.ReadPlan(c.consoleFlags.Apply.String())
+		if err != nil {
+			fmt.Printf("Failed to read plan %v - %v\n", c.consoleFlags.Apply.String(), err)
+			zap.S().Errorf("Failed to read plan %v - %v\n", c.consoleFlags.Apply.String(), err)
+			return
+		}
+		fmt.Printf("Executing plan %v created on %v\n", c.consoleFlags.Apply.String(), plan.Created.Format("2006-01-02 15:04"))
+		c.executePlan(plan)
+		return
+	}
+
+	//1. load the titles and versions JSON objects
+	if offlineMode {
+		fmt.Println("Offline mode, using the local switch titles database")
+	} else {
+		fmt.Println("Downloading latest switch titles json file")
+	}
+	progressBar = progressbar.New(3)
+	titlesDB, err := db.LoadSwitchTitlesDB(c.baseFolder, offlineMode, localDbManager, c)
 	progressBar.Finish()
-	newUpdate, err := settings.CheckForUpdates()
+	if err != nil {
+		fmt.Printf("\n%v\n", err)
+		zap.S().Errorf("%v", err)
+		return
+	}
+	if info := db.ReadTitlesSnapshotInfo(c.baseFolder); info != nil {
+		fmt.Printf("\nUsing %v\n", info)
+	}
 
-	if newUpdate {
-		fmt.Printf("\n=== New version available, download from Github ===\n")
+	if c.consoleFlags.Export.IsSet() && c.consoleFlags.Export.String() != "" {
+		err = db.ExportTitlesSnapshot(c.baseFolder, c.consoleFlags.Export.String())
+		if err != nil {
+			fmt.Printf("Failed to export titles database snapshot - %v\n", err)
+			zap.S().Errorf("Failed to export titles database snapshot - %v\n", err)
+		} else {
+			fmt.Printf("Exported titles database snapshot to %v\n", c.consoleFlags.Export.String())
+		}
 	}
 
-	//3. update the config file with new etag
-	settings.SaveSettings(settingsObj, c.baseFolder)
+	//2. check for a new version of the application
+	if !offlineMode {
+		newUpdate, _ := settings.CheckForUpdates()
 
-	//4. create switch title db
-	titlesDB, err := db.CreateSwitchTitleDB(titleFile, versionsFile)
+		if newUpdate {
+			fmt.Printf("\n=== New version available, download from Github ===\n")
+		}
+	}
 
-	//5. read local files
+	//3. read local files
 	folderToScan := settingsObj.Folder
 	if c.consoleFlags.NspFolder.IsSet() && c.consoleFlags.NspFolder.String() != "" {
 		folderToScan = c.consoleFlags.NspFolder.String()
@@ -98,6 +161,8 @@ func (c *Console) Start() {
 	keys, _ := settings.InitSwitchKeys(c.baseFolder)
 	if keys == nil || keys.GetKey("header_key") == "" {
 		fmt.Printf("\n!!NOTE!!: keys file was not found, deep scan is disabled, library will be based on file tags.\n %v", err)
+	} else {
+		fmt.Printf("\n%v", keys.Report())
 	}
 
 	recursiveMode := settingsObj.ScanRecursively
@@ -105,13 +170,6 @@ func (c *Console) Start() {
 		recursiveMode = c.consoleFlags.Recursive.Bool()
 	}
 
-	localDbManager, err := db.NewLocalSwitchDBManager(c.baseFolder)
-	if err != nil {
-		fmt.Printf("failed to create local files db :%v\n", err)
-		return
-	}
-	defer localDbManager.Close()
-
 	scanFolders := settingsObj.ScanFolders
 	scanFolders = append(scanFolders, folderToScan)
 
@@ -125,6 +183,123 @@ func (c *Console) Start() {
 	p := (float32(len(localDB.TitlesMap)) / float32(len(titlesDB.TitlesMap))) * 100
 
 	fmt.Printf("Local library completion status: %.2f%% (have %d titles, out of %d titles)\n", p, len(localDB.TitlesMap), len(titlesDB.TitlesMap))
+	trimmed, untrimmed, savings := process.XciTrimSummary(localDB)
+	if trimmed+untrimmed != 0 {
+		fmt.Printf("XCI files: %d trimmed, %d untrimmed (trimming would save %.2f GB)\n", trimmed, untrimmed, float64(savings)/(1024*1024*1024))
+	}
+
+	if c.consoleFlags.Verify.Bool() {
+		fmt.Printf("\nVerifying library files\n")
+		progressBar = progressbar.New(2000)
+		corrupted, err := process.VerifyLibrary(localDbManager, localDB, c)
+		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\nfailed to verify library files\n %v", err)
+		} else {
+			fmt.Printf("\nFound %d corrupted files\n", corrupted)
+		}
+	}
+
+	if c.consoleFlags.Decompress.IsSet() {
+		titleId := c.consoleFlags.Decompress.String()
+		if strings.EqualFold(titleId, "all") {
+			titleId = ""
+		}
+		fmt.Printf("\nDecompressing NSZ/XCZ files\n")
+		progressBar = progressbar.New(2000)
+		created, err := process.DecompressTitle(localDB, titleId, c)
+		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\nfailed to decompress files\n %v", err)
+		}
+		fmt.Printf("\nCreated %d files\n", len(created))
+		if len(created) != 0 {
+			_, err = localDbManager.UpdateLocalSwitchFilesDB(localDB, scanFolders, nil, recursiveMode)
+			if err != nil {
+				fmt.Printf("\nfailed to rescan local folder\n %v", err)
+			}
+		}
+	}
+
+	if c.consoleFlags.Trim.IsSet() {
+		titleId := c.consoleFlags.Trim.String()
+		if strings.EqualFold(titleId, "all") {
+			titleId = ""
+		}
+		fmt.Printf("\nTrimming XCI files\n")
+		progressBar = progressbar.New(2000)
+		count, saved, err := process.TrimTitle(localDB, titleId, c)
+		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\nfailed to trim files\n %v", err)
+		}
+		fmt.Printf("\nTrimmed %d files, saved %.2f GB\n", count, float64(saved)/(1024*1024*1024))
+		if count != 0 {
+			_, err = localDbManager.UpdateLocalSwitchFilesDB(localDB, scanFolders, nil, recursiveMode)
+			if err != nil {
+				fmt.Printf("\nfailed to rescan local folder\n %v", err)
+			}
+		}
+	}
+
+	if c.consoleFlags.Untrim.IsSet() {
+		titleId := c.consoleFlags.Untrim.String()
+		if strings.EqualFold(titleId, "all") {
+			titleId = ""
+		}
+		fmt.Printf("\nUntrimming XCI files\n")
+		progressBar = progressbar.New(2000)
+		count, err := process.UntrimTitle(localDB, titleId, c)
+		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\nfailed to untrim files\n %v", err)
+		}
+		fmt.Printf("\nUntrimmed %d files\n", count)
+		if count != 0 {
+			_, err = localDbManager.UpdateLocalSwitchFilesDB(localDB, scanFolders, nil, recursiveMode)
+			if err != nil {
+				fmt.Printf("\nfailed to rescan local folder\n %v", err)
+			}
+		}
+	}
+
+	if c.consoleFlags.Merge.IsSet() {
+		titleId, dlcIds, _ := strings.Cut(c.consoleFlags.Merge.String(), ":")
+		var dlcs []string
+		if dlcIds != "" {
+			dlcs = strings.Split(dlcIds, ",")
+		}
+		fmt.Printf("\nMerging title %v\n", titleId)
+		progressBar = progressbar.New(2000)
+		outputPath, err := process.MergeTitle(localDB, titleId, dlcs, c)
+		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\nfailed to merge title\n %v", err)
+		} else {
+			fmt.Printf("\nCreated %v\n", outputPath)
+			_, err = localDbManager.UpdateLocalSwitchFilesDB(localDB, scanFolders, nil, recursiveMode)
+			if err != nil {
+				fmt.Printf("\nfailed to rescan local folder\n %v", err)
+			}
+		}
+	}
+
+	if c.consoleFlags.Split.IsSet() {
+		fmt.Printf("\nSplitting title %v\n", c.consoleFlags.Split.String())
+		progressBar = progressbar.New(2000)
+		created, err := process.SplitTitle(c.baseFolder, localDB, titlesDB, c.consoleFlags.Split.String(), false, c)
+		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\nfailed to split title\n %v", err)
+		}
+		fmt.Printf("\nCreated %d files\n", len(created))
+		if len(created) != 0 {
+			_, err = localDbManager.UpdateLocalSwitchFilesDB(localDB, scanFolders, nil, recursiveMode)
+			if err != nil {
+				fmt.Printf("\nfailed to rescan local folder\n %v", err)
+			}
+		}
+	}
 
 	issuesCsvFile := ""
 	if csvOutput != "" {
@@ -132,20 +307,27 @@ func (c *Console) Start() {
 	}
 	c.processIssues(localDB, issuesCsvFile)
 
+	plan := process.NewPlan()
 	if settingsObj.OrganizeOptions.DeleteOldUpdateFiles {
-		progressBar = progressbar.New(2000)
-		fmt.Printf("\nDeleting old updates\n")
-		process.DeleteOldUpdates(c.baseFolder, localDB, c)
-		progressBar.Finish()
+		plan.Append(process.PlanDeleteOldUpdates(c.baseFolder, localDB))
 	}
 
 	if settingsObj.OrganizeOptions.RenameFiles || settingsObj.OrganizeOptions.CreateFolderPerGame {
 		progressBar = progressbar.New(2000)
-		fmt.Printf("\nStarting library organization\n")
-		process.OrganizeByFolders(folderToScan, localDB, titlesDB, c)
+		fmt.Printf("\nPlanning library organization\n")
+		_, err := process.PlanOrganizeByFolders(folderToScan, localDB, titlesDB, plan, c)
 		progressBar.Finish()
+		if err != nil {
+			fmt.Printf("\n%v\n", err)
+			zap.S().Error(err)
+		}
 	}
 
+	if len(plan.Actions) != 0 || c.consoleFlags.DryRun.Bool() {
+		c.processPlan(plan)
+	}
+
+	var missingUpdates, missingDLC map[string]process.IncompleteTitle
 	if settingsObj.CheckForMissingUpdates {
 		fmt.Printf("\nChecking for missing updates\n")
 
@@ -154,7 +336,7 @@ func (c *Console) Start() {
 			missingUpdatesCsvFile = filepath.Join(csvOutput, "missing_updates.csv")
 		}
 
-		c.processMissingUpdates(localDB, titlesDB, settingsObj, missingUpdatesCsvFile)
+		missingUpdates = c.processMissingUpdates(localDB, titlesDB, settingsObj, missingUpdatesCsvFile)
 	}
 
 	if settingsObj.CheckForMissingDLC {
@@ -165,10 +347,132 @@ func (c *Console) Start() {
 			missingDlcCsvFile = filepath.Join(csvOutput, "missing_dlc.csv")
 		}
 
-		c.processMissingDLC(localDB, titlesDB, missingDlcCsvFile)
+		missingDLC = c.processMissingDLC(localDB, titlesDB, missingDlcCsvFile)
+	}
+
+	if settingsObj.TargetFirmware != "" {
+		fmt.Printf("\nChecking for titles needing a newer firmware than %v\n", settingsObj.TargetFirmware)
+
+		firmwareCsvFile := ""
+		if csvOutput != "" {
+			firmwareCsvFile = filepath.Join(csvOutput, "needs_newer_firmware.csv")
+		}
+
+		c.processNeedsNewerFirmware(localDB, titlesDB, settingsObj.TargetFirmware, firmwareCsvFile)
+	}
+
+	if csvOutput != "" {
+		c.exportLibrary(localDB, titlesDB, offlineMode, filepath.Join(csvOutput, "library.csv"))
 	}
 
 	fmt.Printf("Completed")
+
+	watchMode := settingsObj.WatchFolders
+	if c.consoleFlags.Watch.IsSet() {
+		watchMode = c.consoleFlags.Watch.Bool()
+	}
+	if watchMode {
+		c.watchLibrary(localDbManager, localDB, titlesDB, missingUpdates, missingDLC, scanFolders, recursiveMode)
+	}
+}
+
+// processPlan prints the plan of the files to delete, move and rename, writes it when requested and
+// executes it unless in dry run mode
+func (c *Console) processPlan(plan *process.Plan) {
+	if c.consoleFlags.DryRun.Bool() {
+		fmt.Printf("\nDry run, the library will not be changed:\n%v\n", plan)
+	} else {
+		fmt.Printf("\nLibrary changes: %v\n", plan.Summary())
+		for _, collision := range plan.Collisions() {
+			fmt.Printf("  %v\n", collision)
+		}
+	}
+
+	if c.consoleFlags.Plan.IsSet() && c.consoleFlags.Plan.String() != "" {
+		err := plan.ExportPlan(c.consoleFlags.Plan.String())
+		if err != nil {
+			fmt.Printf("Failed to write plan - %v\n", err)
+			zap.S().Errorf("Failed to write plan - %v\n", err)
+		} else {
+			fmt.Printf("Plan written to %v\n", c.consoleFlags.Plan.String())
+		}
+	}
+
+	if !c.consoleFlags.DryRun.Bool() {
+		c.executePlan(plan)
+	}
+}
+
+func (c *Console) executePlan(plan *process.Plan) {
+	progressBar = progressbar.New(2000)
+	err := plan.Execute(c.baseFolder, c)
+	progressBar.Finish()
+	if err != nil {
+		fmt.Printf("\n%v\n", err)
+	}
+}
+
+// watchLibrary updates the library on changes to the scan folders, and prints the missing updates and DLC
+// again after recomputing them for the affected titles only
+func (c *Console) watchLibrary(localDbManager *db.LocalSwitchDBManager, localDB *db.LocalSwitchFilesDB,
+	titlesDB *db.SwitchTitlesDB, missingUpdates map[string]process.IncompleteTitle, missingDLC map[string]process.IncompleteTitle,
+	folders []string, recursive bool) {
+	settingsObj := settings.ReadSettings(c.baseFolder)
+
+	changes := make(chan struct{}, 1)
+	watcher, err := db.WatchFolders(folders, recursive, func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		fmt.Printf("\nfailed to watch folders\n %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	fmt.Printf("\n\nWatching folders for changes, press Ctrl+C to stop\n")
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-changes:
+			progressBar = progressbar.New(2000)
+			affected, err := localDbManager.UpdateLocalSwitchFilesDB(localDB, folders, c, recursive)
+			progressBar.Finish()
+			if err != nil {
+				fmt.Printf("\nfailed to update local library\n %v", err)
+				continue
+			}
+			if len(affected) == 0 {
+				continue
+			}
+			fmt.Printf("\nLocal library changed, %d titles affected\n", len(affected))
+
+			if missingUpdates != nil {
+				process.UpdateMissingUpdates(missingUpdates, localDB.TitlesMap, titlesDB.TitlesMap,
+					toIgnoreMap(settingsObj.IgnoreUpdateTitleIds), settingsObj.IgnoreDLCUpdates, settingsObj.PreferredLanguages, affected)
+				c.printMissingUpdates(missingUpdates, "")
+			}
+			if missingDLC != nil {
+				process.UpdateMissingDLC(missingDLC, localDB.TitlesMap, titlesDB.TitlesMap,
+					toIgnoreMap(settingsObj.IgnoreDLCTitleIds), settingsObj.PreferredLanguages, affected)
+				c.printMissingDLC(missingDLC, "")
+			}
+		}
+	}
+}
+
+func toIgnoreMap(titleIds []string) map[string]struct{} {
+	ignoreIds := map[string]struct{}{}
+	for _, id := range titleIds {
+		ignoreIds[strings.ToLower(id)] = struct{}{}
+	}
+	return ignoreIds
 }
 
 func (c *Console) processIssues(localDB *db.LocalSwitchFilesDB, csvOutput string) {
@@ -197,13 +501,14 @@ func (c *Console) processIssues(localDB *db.LocalSwitchFilesDB, csvOutput string
 	csv.Close()
 }
 
-func (c *Console) processMissingUpdates(localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, settingsObj *settings.AppSettings, csvOutput string) {
-	ignoreIds := map[string]struct{}{}
-	for _, id := range settingsObj.IgnoreUpdateTitleIds {
-		ignoreIds[strings.ToLower(id)] = struct{}{}
-	}
+func (c *Console) processMissingUpdates(localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, settingsObj *settings.AppSettings, csvOutput string) map[string]process.IncompleteTitle {
+	ignoreIds := toIgnoreMap(settingsObj.IgnoreUpdateTitleIds)
+	incompleteTitles := process.ScanForMissingUpdates(localDB.TitlesMap, titlesDB.TitlesMap, ignoreIds, settingsObj.IgnoreDLCUpdates, settingsObj.PreferredLanguages)
+	c.printMissingUpdates(incompleteTitles, csvOutput)
+	return incompleteTitles
+}
 
-	incompleteTitles := process.ScanForMissingUpdates(localDB.TitlesMap, titlesDB.TitlesMap, ignoreIds, settingsObj.IgnoreDLCUpdates)
+func (c *Console) printMissingUpdates(incompleteTitles map[string]process.IncompleteTitle, csvOutput string) {
 	if len(incompleteTitles) != 0 {
 		fmt.Print("\nFound available updates:\n\n")
 	} else {
@@ -230,13 +535,15 @@ func (c *Console) processMissingUpdates(localDB *db.LocalSwitchFilesDB, titlesDB
 	csv.Close()
 }
 
-func (c *Console) processMissingDLC(localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, csvOutput string) {
+func (c *Console) processMissingDLC(localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, csvOutput string) map[string]process.IncompleteTitle {
 	settingsObj := settings.ReadSettings(c.baseFolder)
-	ignoreIds := map[string]struct{}{}
-	for _, id := range settingsObj.IgnoreDLCTitleIds {
-		ignoreIds[strings.ToLower(id)] = struct{}{}
-	}
-	incompleteTitles := process.ScanForMissingDLC(localDB.TitlesMap, titlesDB.TitlesMap, ignoreIds)
+	ignoreIds := toIgnoreMap(settingsObj.IgnoreDLCTitleIds)
+	incompleteTitles := process.ScanForMissingDLC(localDB.TitlesMap, titlesDB.TitlesMap, ignoreIds, settingsObj.PreferredLanguages)
+	c.printMissingDLC(incompleteTitles, csvOutput)
+	return incompleteTitles
+}
+
+func (c *Console) printMissingDLC(incompleteTitles map[string]process.IncompleteTitle, csvOutput string) {
 	if len(incompleteTitles) != 0 {
 		fmt.Print("\nFound missing DLCS:\n\n")
 	} else {
@@ -265,6 +572,70 @@ func (c *Console) processMissingDLC(localDB *db.LocalSwitchFilesDB, titlesDB *db
 	csv.Close()
 }
 
+func (c *Console) processNeedsNewerFirmware(localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, targetFirmware string, csvOutput string) {
+	var csv *CsvFile
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleColoredBright)
+	t.AppendHeader(table.Row{"#", "Title", "TitleId", "Required firmware", "Key generation"})
+	preferredLanguages := settings.ReadSettings(c.baseFolder).PreferredLanguages
+	i := 0
+	for idPrefix, v := range localDB.TitlesMap {
+		if !v.BaseExist {
+			continue
+		}
+		firmware := process.GetTitleFirmware(v)
+		if !process.NeedsNewerFirmware(firmware, targetFirmware) {
+			continue
+		}
+		if csv == nil {
+			csv = CreateCsvFile(csvOutput, []string{"Title", "TitleId", "Required firmware", "Key generation"})
+		}
+		name := process.GetTitleName(titlesDB.TitlesMap[idPrefix], v, preferredLanguages)
+		titleId := v.File.Metadata.TitleId
+		csv.Write([]string{name, titleId, firmware.RequiredFirmware(), strconv.Itoa(firmware.KeyGeneration)})
+
+		t.AppendRow([]interface{}{i, name, titleId, firmware.RequiredFirmware(), firmware.KeyGeneration})
+		i++
+	}
+	if i == 0 {
+		fmt.Print("\nAll titles can run on the target firmware!\n\n")
+		return
+	}
+	fmt.Print("\nFound titles needing a newer firmware:\n\n")
+	t.AppendFooter(table.Row{"", "", "", "Total", i})
+	t.Render()
+
+	csv.Close()
+}
+
+// exportLibrary writes all the local titles, including their required firmware, to a csv file
+func (c *Console) exportLibrary(localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, offlineMode bool, csvOutput string) {
+	csv := CreateCsvFile(csvOutput, []string{"Title", "TitleId", "Type", "Update", "Version", "Required firmware", "Key generation", "Save data size", "Icon", "File name"})
+	preferredLanguages := settings.ReadSettings(c.baseFolder).PreferredLanguages
+	for idPrefix, v := range localDB.TitlesMap {
+		if !v.BaseExist {
+			continue
+		}
+		version := ""
+		if v.File.Metadata != nil && v.File.Metadata.Ncap != nil {
+			version = v.File.Metadata.Ncap.DisplayVersion
+		}
+		if update, ok := v.Updates[v.LatestUpdate]; ok && update.Metadata != nil && update.Metadata.Ncap != nil {
+			version = update.Metadata.Ncap.DisplayVersion
+		}
+		firmware := process.GetTitleFirmware(v)
+		iconUrl := ""
+		if title, ok := titlesDB.TitlesMap[idPrefix]; ok {
+			iconUrl = title.Attributes.IconUrl
+		}
+		icon, _ := db.GetIcon(c.baseFolder, iconUrl, v.File.Metadata.TitleId, offlineMode)
+		csv.Write([]string{process.GetTitleName(titlesDB.TitlesMap[idPrefix], v, preferredLanguages), v.File.Metadata.TitleId, v.File.Metadata.Type, strconv.Itoa(v.LatestUpdate), version,
+			firmware.RequiredFirmware(), strconv.Itoa(firmware.KeyGeneration), strconv.FormatInt(process.GetTitleSaveDataSize(v), 10), icon, filepath.Join(v.File.ExtendedInfo.BaseFolder, v.File.ExtendedInfo.FileName)})
+	}
+	csv.Close()
+}
+
 func (c *Console) UpdateProgress(curr int, total int, message string) {
 	progressBar.ChangeMax(total)
 	progressBar.Set(curr)
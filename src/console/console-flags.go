@@ -29,16 +29,46 @@ func (sf *flagValue) IsSet() bool {
 }
 
 type ConsoleFlags struct {
-	Mode      flagValue
-	NspFolder flagValue
-	Recursive flagValue
-	ExportCsv flagValue
+	Mode       flagValue
+	NspFolder  flagValue
+	Recursive  flagValue
+	ExportCsv  flagValue
+	Watch      flagValue
+	Verify     flagValue
+	Decompress flagValue
+	Merge      flagValue
+	Split      flagValue
+	Trim       flagValue
+	Untrim     flagValue
+	Offline    flagValue
+	Import     flagValue
+	Export     flagValue
+	DryRun     flagValue
+	Plan       flagValue
+	Apply      flagValue
+	Undo       flagValue
+	Purge      flagValue
 }
 
 var mode string
 var nspFolder string
 var recursive bool
 var exportCsv string
+var watch bool
+var verify bool
+var decompress string
+var merge string
+var split string
+var trim string
+var untrim string
+var offline bool
+var importSnapshot string
+var exportSnapshot string
+var dryRun bool
+var planFile string
+var applyPlan string
+var undo bool
+var purge string
 
 func InitializeFlags() {
 	if flag.Parsed() {
@@ -49,6 +79,21 @@ func InitializeFlags() {
 	flag.StringVar(&nspFolder, "f", "", "path to NSP folder")
 	flag.BoolVar(&recursive, "r", true, "recursively scan sub folders")
 	flag.StringVar(&exportCsv, "e", "", "output missing updates, dlcs and issues as csv")
+	flag.BoolVar(&watch, "w", false, "keep running and watch the scanned folders for changes")
+	flag.BoolVar(&verify, "v", false, "verify the content hashes of all scanned files")
+	flag.StringVar(&decompress, "d", "", "decompress the NSZ/XCZ files of the given title id (or 'all') into NSP/XCI")
+	flag.StringVar(&merge, "c", "", "combine base, latest update and DLC of a title id into a multi-content file (titleId or titleId:dlcId,dlcId)")
+	flag.StringVar(&split, "s", "", "split the multi-content file of the given title id into separate base/update/DLC NSPs")
+	flag.StringVar(&trim, "t", "", "trim the padding of the XCI files of the given title id (or 'all')")
+	flag.StringVar(&untrim, "u", "", "pad the trimmed XCI files of the given title id (or 'all') back to their gamecard size")
+	flag.BoolVar(&offline, "o", false, "use the local titles database snapshot without downloading, overrides the offline_mode in settings.json")
+	flag.StringVar(&importSnapshot, "i", "", "import a titles database snapshot archive before scanning")
+	flag.StringVar(&exportSnapshot, "x", "", "export the titles database as a snapshot archive to the given path")
+	flag.BoolVar(&dryRun, "n", false, "print the files which would be deleted, moved and renamed without changing anything")
+	flag.StringVar(&planFile, "p", "", "write the plan of the files to delete, move and rename as json to the given path")
+	flag.StringVar(&applyPlan, "a", "", "execute a plan written with -p as is, instead of scanning the library")
+	flag.BoolVar(&undo, "z", false, "undo the last organize/delete run, instead of scanning the library")
+	flag.StringVar(&purge, "q", "", "permanently delete the files of the trash, 'expired' (past the retention rules) or 'all'")
 
 	flag.Parse()
 }
@@ -88,11 +133,101 @@ func GetFlagsValues() *ConsoleFlags {
 		exportCsvFlag.Set(exportCsv)
 	}
 
+	watchFlag := &flagValue{}
+	if flagset["w"] {
+		watchFlag.Set(strconv.FormatBool(watch))
+	}
+
+	verifyFlag := &flagValue{}
+	if flagset["v"] {
+		verifyFlag.Set(strconv.FormatBool(verify))
+	}
+
+	decompressFlag := &flagValue{}
+	if flagset["d"] {
+		decompressFlag.Set(decompress)
+	}
+
+	mergeFlag := &flagValue{}
+	if flagset["c"] {
+		mergeFlag.Set(merge)
+	}
+
+	splitFlag := &flagValue{}
+	if flagset["s"] {
+		splitFlag.Set(split)
+	}
+
+	trimFlag := &flagValue{}
+	if flagset["t"] {
+		trimFlag.Set(trim)
+	}
+
+	untrimFlag := &flagValue{}
+	if flagset["u"] {
+		untrimFlag.Set(untrim)
+	}
+
+	offlineFlag := &flagValue{}
+	if flagset["o"] {
+		offlineFlag.Set(strconv.FormatBool(offline))
+	}
+
+	importFlag := &flagValue{}
+	if flagset["i"] {
+		importFlag.Set(importSnapshot)
+	}
+
+	exportFlag := &flagValue{}
+	if flagset["x"] {
+		exportFlag.Set(exportSnapshot)
+	}
+
+	dryRunFlag := &flagValue{}
+	if flagset["n"] {
+		dryRunFlag.Set(strconv.FormatBool(dryRun))
+	}
+
+	planFlag := &flagValue{}
+	if flagset["p"] {
+		planFlag.Set(planFile)
+	}
+
+	applyFlag := &flagValue{}
+	if flagset["a"] {
+		applyFlag.Set(applyPlan)
+	}
+
+	undoFlag := &flagValue{}
+	if flagset["z"] {
+		undoFlag.Set(strconv.FormatBool(undo))
+	}
+
+	purgeFlag := &flagValue{}
+	if flagset["q"] {
+		purgeFlag.Set(purge)
+	}
+
 	consoleFlagsInstance = &ConsoleFlags{
-		Mode:      *modeFlag,
-		NspFolder: *nspFolderFlag,
-		Recursive: *recursiveFlag,
-		ExportCsv: *exportCsvFlag,
+		Mode:       *modeFlag,
+		NspFolder:  *nspFolderFlag,
+		Recursive:  *recursiveFlag,
+		ExportCsv:  *exportCsvFlag,
+		Watch:      *watchFlag,
+		Verify:     *verifyFlag,
+		Decompress: *decompressFlag,
+		Merge:      *mergeFlag,
+		Split:      *splitFlag,
+		Trim:       *trimFlag,
+		Untrim:     *untrimFlag,
+		Offline:    *offlineFlag,
+		Import:     *importFlag,
+		Export:     *exportFlag,
+		DryRun:     *dryRunFlag,
+		Plan:       *planFlag,
+		Apply:      *applyFlag,
+		Undo:       *undoFlag,
+		Purge:      *purgeFlag,
 	}
 
 	return consoleFlagsInstance
@@ -105,6 +240,21 @@ func LogFlags(sugar *zap.SugaredLogger) {
 	logFlag(sugar, "f", values.NspFolder)
 	logFlag(sugar, "r", values.Recursive)
 	logFlag(sugar, "e", values.ExportCsv)
+	logFlag(sugar, "w", values.Watch)
+	logFlag(sugar, "v", values.Verify)
+	logFlag(sugar, "d", values.Decompress)
+	logFlag(sugar, "c", values.Merge)
+	logFlag(sugar, "s", values.Split)
+	logFlag(sugar, "t", values.Trim)
+	logFlag(sugar, "u", values.Untrim)
+	logFlag(sugar, "o", values.Offline)
+	logFlag(sugar, "i", values.Import)
+	logFlag(sugar, "x", values.Export)
+	logFlag(sugar, "n", values.DryRun)
+	logFlag(sugar, "p", values.Plan)
+	logFlag(sugar, "a", values.Apply)
+	logFlag(sugar, "z", values.Undo)
+	logFlag(sugar, "q", values.Purge)
 }
 
 func logFlag(sugar *zap.SugaredLogger, flagName string, flag flagValue) {
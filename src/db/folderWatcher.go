@@ -0,0 +1,149 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// files being copied into a watched folder generate a stream of events,
+// so changes are only reported once the folders have been quiet for a while
+const watchQuietPeriod = 5 * time.Second
+
+type FolderWatcher struct {
+	watcher  *fsnotify.Watcher
+	onChange func()
+	timer    *time.Timer
+	done     chan struct{}
+	mutex    sync.Mutex
+}
+
+// WatchFolders monitors the given folders for created, renamed and deleted NSP/NSZ/XCI/XCZ files and folders,
+// and calls onChange (from the watcher goroutine) after a batch of changes has settled.
+func WatchFolders(folders []string, recursive bool, onChange func()) (*FolderWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := &FolderWatcher{watcher: watcher, onChange: onChange, done: make(chan struct{})}
+	for _, folder := range folders {
+		if folder == "" {
+			continue
+		}
+		err = fw.addFolder(folder, recursive)
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	go fw.run(recursive)
+	return fw, nil
+}
+
+func (fw *FolderWatcher) Close() {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	select {
+	case <-fw.done:
+		return
+	default:
+	}
+	close(fw.done)
+	if fw.timer != nil {
+		fw.timer.Stop()
+	}
+	fw.watcher.Close()
+}
+
+func (fw *FolderWatcher) addFolder(folder string, recursive bool) error {
+	if !recursive {
+		return fw.watcher.Add(folder)
+	}
+	return filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			zap.S().Error("Error while adding folders to watch", err)
+			return nil
+		}
+		if info.IsDir() {
+			return fw.watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// removeFolder stops watching a folder and its sub folders, and reports if the folder was watched
+func (fw *FolderWatcher) removeFolder(folder string) bool {
+	removed := false
+	prefix := folder + string(filepath.Separator)
+	for _, path := range fw.watcher.WatchList() {
+		if path == folder || strings.HasPrefix(path, prefix) {
+			// already removed by the watcher when the folder was deleted
+			_ = fw.watcher.Remove(path)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (fw *FolderWatcher) run(recursive bool) {
+	for {
+		select {
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) && recursive {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err = fw.addFolder(event.Name, recursive); err != nil {
+						zap.S().Warnf("Failed to watch new folder %v - %v", event.Name, err)
+					}
+					fw.scheduleChange()
+					continue
+				}
+			}
+			// a watched folder renamed or moved out of the library, the files it held are gone
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				if fw.removeFolder(event.Name) {
+					zap.S().Debugf("Watched folder removed [%v]", event)
+					fw.scheduleChange()
+					continue
+				}
+			}
+			if !isSupportedFile(filepath.Base(event.Name)) {
+				continue
+			}
+			zap.S().Debugf("Watched file changed [%v]", event)
+			fw.scheduleChange()
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			zap.S().Warnf("Folder watcher error - %v", err)
+		case <-fw.done:
+			return
+		}
+	}
+}
+
+func (fw *FolderWatcher) scheduleChange() {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	if fw.timer != nil {
+		fw.timer.Stop()
+	}
+	fw.timer = time.AfterFunc(watchQuietPeriod, func() {
+		select {
+		case <-fw.done:
+			return
+		default:
+		}
+		fw.onChange()
+	})
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFolderWatcherRemoveFolder(t *testing.T) {
+	library := t.TempDir()
+	gameFolder := filepath.Join(library, "Game")
+	_ = os.MkdirAll(filepath.Join(gameFolder, "Updates"), os.ModePerm)
+	_ = os.MkdirAll(filepath.Join(library, "Game 2"), os.ModePerm)
+
+	fw, err := WatchFolders([]string{library}, true, func() {})
+	if err != nil {
+		t.Fatalf("failed to watch folders: %v", err)
+	}
+	defer fw.Close()
+
+	if fw.removeFolder(filepath.Join(library, "Game.nsp")) {
+		t.Fatalf("expected a file not to be reported as a watched folder")
+	}
+	if !fw.removeFolder(gameFolder) {
+		t.Fatalf("expected %v to be watched", gameFolder)
+	}
+	// Game 2 shares the prefix of Game, but is not a sub folder
+	if watched := fw.watcher.WatchList(); len(watched) != 2 {
+		t.Fatalf("expected the library and Game 2 to be watched, got %v", watched)
+	}
+}
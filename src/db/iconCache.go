@@ -0,0 +1,74 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/trembon/switch-library-manager/switchfs"
+	"go.uber.org/zap"
+)
+
+const ICONS_FOLDER = "icons"
+
+// iconLanguages is the order in which the icons of a control NCA are preferred
+var iconLanguages = []string{"AmericanEnglish", "BritishEnglish", "CanadianFrench", "French", "German", "Spanish",
+	"LatinAmericanSpanish", "Italian", "Dutch", "Portuguese", "BrazilianPortuguese", "Russian", "Japanese", "Korean", "Taiwanese", "Chinese"}
+
+// GetIconPath returns the path of the cached icon of a title, empty when no icon was extracted for the title
+func GetIconPath(baseFolder string, titleId string) string {
+	iconPath := getIconPath(baseFolder, titleId)
+	if _, err := os.Stat(iconPath); err != nil {
+		return ""
+	}
+	return iconPath
+}
+
+// GetIcon returns the icon url of the titles db, or the path of the icon extracted from the files (and true)
+// when there is no url or in offline mode, where the remote icons can not be loaded
+func GetIcon(baseFolder string, iconUrl string, titleId string, offline bool) (string, bool) {
+	if iconUrl != "" && !offline {
+		return iconUrl, false
+	}
+	if iconPath := GetIconPath(baseFolder, titleId); iconPath != "" {
+		return iconPath, true
+	}
+	return iconUrl, false
+}
+
+func getIconPath(baseFolder string, titleId string) string {
+	return filepath.Join(baseFolder, ICONS_FOLDER, GetTitlePrefix(titleId)+"000.jpg")
+}
+
+// saveIcons stores the icons extracted from the control NCAs of the scanned file, an update
+// only provides the icon when the base game did not, the icons are cleared from the metadata so they are
+// not kept in the scan cache
+func saveIcons(baseFolder string, metadata map[string]*switchfs.ContentMetaAttributes) {
+	for _, meta := range metadata {
+		if meta.Ncap == nil || len(meta.Ncap.Icons) == 0 {
+			continue
+		}
+		icons := meta.Ncap.Icons
+		meta.Ncap.Icons = nil
+
+		iconPath := getIconPath(baseFolder, meta.TitleId)
+		if meta.Type != "BASE" {
+			if _, err := os.Stat(iconPath); err == nil {
+				continue
+			}
+		}
+		for _, language := range iconLanguages {
+			icon, ok := icons[language]
+			if !ok {
+				continue
+			}
+			err := os.MkdirAll(filepath.Dir(iconPath), os.ModePerm)
+			if err == nil {
+				err = os.WriteFile(iconPath, icon, 0644)
+			}
+			if err != nil {
+				zap.S().Warnf("Failed to save icon of %v - %v", meta.TitleId, err)
+			}
+			break
+		}
+	}
+}
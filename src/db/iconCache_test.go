@@ -0,0 +1,72 @@
+package db
+
+import (
+	"os"
+	"testing"
+
+	"github.com/trembon/switch-library-manager/switchfs"
+)
+
+func TestSaveIcons(t *testing.T) {
+	baseFolder := t.TempDir()
+	newUpdate := func() map[string]*switchfs.ContentMetaAttributes {
+		return map[string]*switchfs.ContentMetaAttributes{
+			"0100000000010800": {TitleId: "0100000000010800", Type: "UPD",
+				Ncap: &switchfs.Nacp{Icons: map[string][]byte{"Japanese": []byte("update")}}},
+		}
+	}
+	update := newUpdate()
+	saveIcons(baseFolder, update)
+	if update["0100000000010800"].Ncap.Icons != nil {
+		t.Fatalf("expected icons to be released after saving")
+	}
+
+	iconPath := GetIconPath(baseFolder, "0100000000010000")
+	if data, err := os.ReadFile(iconPath); err != nil || string(data) != "update" {
+		t.Fatalf("expected the update icon to be used when the base icon is missing, got %v (%v)", string(data), err)
+	}
+
+	base := map[string]*switchfs.ContentMetaAttributes{
+		"0100000000010000": {TitleId: "0100000000010000", Type: "BASE",
+			Ncap: &switchfs.Nacp{Icons: map[string][]byte{"Japanese": []byte("japanese"), "AmericanEnglish": []byte("english")}}},
+	}
+	saveIcons(baseFolder, base)
+	if data, _ := os.ReadFile(iconPath); string(data) != "english" {
+		t.Fatalf("expected the AmericanEnglish icon of the base game, got %v", string(data))
+	}
+
+	saveIcons(baseFolder, newUpdate())
+	if data, _ := os.ReadFile(iconPath); string(data) != "english" {
+		t.Fatalf("expected an update not to replace the base game icon, got %v", string(data))
+	}
+
+	if GetIconPath(baseFolder, "0100000000020000") != "" {
+		t.Fatalf("expected no icon for an unknown title")
+	}
+}
+
+func TestGetIcon(t *testing.T) {
+	baseFolder := t.TempDir()
+	saveIcons(baseFolder, map[string]*switchfs.ContentMetaAttributes{
+		"0100000000010000": {TitleId: "0100000000010000", Type: "BASE",
+			Ncap: &switchfs.Nacp{Icons: map[string][]byte{"AmericanEnglish": []byte("english")}}},
+	})
+	iconPath := GetIconPath(baseFolder, "0100000000010000")
+	tests := []struct {
+		iconUrl  string
+		titleId  string
+		offline  bool
+		expected string
+		local    bool
+	}{
+		{"https://icon", "0100000000010000", false, "https://icon", false},
+		{"https://icon", "0100000000010000", true, iconPath, true},
+		{"", "0100000000010000", false, iconPath, true},
+		{"https://icon", "0100000000020000", true, "https://icon", false},
+	}
+	for _, test := range tests {
+		if icon, local := GetIcon(baseFolder, test.iconUrl, test.titleId, test.offline); icon != test.expected || local != test.local {
+			t.Errorf("%+v: got %v (%v)", test, icon, local)
+		}
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/trembon/switch-library-manager/fileio"
 	"github.com/trembon/switch-library-manager/settings"
@@ -33,10 +36,17 @@ const (
 	REASON_UNRECOGNISED
 	REASON_MALFORMED_FILE
 	REASON_MISSING_BASE
+	REASON_CORRUPTED_FILE
+	REASON_MISSING_KEYS
 )
 
+// metadataVersion is increased when more information is read from the files,
+// so the cached scan results of older versions are read again
+const metadataVersion = 5
+
 type LocalSwitchDBManager struct {
-	db *PersistentDB
+	db         *PersistentDB
+	baseFolder string
 }
 
 func NewLocalSwitchDBManager(baseFolder string) (*LocalSwitchDBManager, error) {
@@ -44,7 +54,7 @@ func NewLocalSwitchDBManager(baseFolder string) (*LocalSwitchDBManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &LocalSwitchDBManager{db: db}, nil
+	return &LocalSwitchDBManager{db: db, baseFolder: baseFolder}, nil
 }
 
 func (ldb *LocalSwitchDBManager) Close() {
@@ -55,6 +65,7 @@ type ExtendedFileInfo struct {
 	FileName   string
 	BaseFolder string
 	Size       int64
+	ModTime    int64
 	IsDir      bool
 }
 
@@ -91,31 +102,47 @@ func (ldb *LocalSwitchDBManager) CreateLocalSwitchFilesDB(folders []string,
 	titles := map[string]*SwitchGameFiles{}
 	skipped := map[ExtendedFileInfo]SkippedFile{}
 	files := []ExtendedFileInfo{}
+	// left nil when there is no cached library, an empty library is cached as an empty map
+	var fileTitles map[string][]string
 
-	if !ignoreCache {
+	cachedVersion := 0
+	ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "metadata-version", &cachedVersion)
+	if !ignoreCache && cachedVersion == metadataVersion {
 		ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "files", &files)
 		ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", &skipped)
 		ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "titles", &titles)
+		ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "file-titles", &fileTitles)
 	}
 
-	if len(titles) == 0 {
-
-		for i, folder := range folders {
-			err := scanFolder(folder, recursive, &files, progress)
-			if progress != nil {
-				progress.UpdateProgress(i+1, len(folders)+1, "Scanning files in "+folder)
-			}
-			if err != nil {
-				continue
-			}
+	scannedFiles := []ExtendedFileInfo{}
+	for i, folder := range folders {
+		err := scanFolder(folder, recursive, &scannedFiles, progress)
+		if progress != nil {
+			progress.UpdateProgress(i+1, len(folders)+1, "Scanning files in "+folder)
 		}
+		if err != nil {
+			continue
+		}
+	}
 
-		ldb.processLocalFiles(files, progress, titles, skipped)
-
-		ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "files", files)
-		ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", skipped)
-		ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "titles", titles)
+	if fileTitles == nil {
+		titles = map[string]*SwitchGameFiles{}
+		skipped = map[ExtendedFileInfo]SkippedFile{}
+		fileTitles = map[string][]string{}
+		ldb.processLocalFiles(scannedFiles, progress, titles, skipped, fileTitles)
+	} else if _, changed := ldb.updateLocalFiles(files, scannedFiles, progress, titles, skipped, fileTitles); !changed {
+		if progress != nil {
+			progress.UpdateProgress(len(files), len(files), "Complete")
+		}
+		return &LocalSwitchFilesDB{TitlesMap: titles, Skipped: skipped, NumFiles: len(files)}, nil
 	}
+	files = scannedFiles
+
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "metadata-version", metadataVersion)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "files", files)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", skipped)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "titles", titles)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "file-titles", fileTitles)
 
 	if progress != nil {
 		progress.UpdateProgress(len(files), len(files), "Complete")
@@ -124,6 +151,164 @@ func (ldb *LocalSwitchDBManager) CreateLocalSwitchFilesDB(folders []string,
 	return &LocalSwitchFilesDB{TitlesMap: titles, Skipped: skipped, NumFiles: len(files)}, nil
 }
 
+// UpdateLocalSwitchFilesDB applies the changes made to the scan folders since the last scan to
+// localDB in place, and returns the id prefixes of the titles that were affected.
+func (ldb *LocalSwitchDBManager) UpdateLocalSwitchFilesDB(localDB *LocalSwitchFilesDB, folders []string,
+	progress ProgressUpdater, recursive bool) (map[string]struct{}, error) {
+
+	files := []ExtendedFileInfo{}
+	fileTitles := map[string][]string{}
+	ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "files", &files)
+	ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "file-titles", &fileTitles)
+
+	scannedFiles := []ExtendedFileInfo{}
+	for _, folder := range folders {
+		err := scanFolder(folder, recursive, &scannedFiles, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	affected, changed := ldb.updateLocalFiles(files, scannedFiles, progress, localDB.TitlesMap, localDB.Skipped, fileTitles)
+	if !changed {
+		return affected, nil
+	}
+	localDB.NumFiles = len(scannedFiles)
+
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "files", scannedFiles)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", localDB.Skipped)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "titles", localDB.TitlesMap)
+	ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "file-titles", fileTitles)
+
+	return affected, nil
+}
+
+// SaveSkippedFiles persists the skipped files of localDB, like the corrupted files found when verifying the library
+func (ldb *LocalSwitchDBManager) SaveSkippedFiles(localDB *LocalSwitchFilesDB) error {
+	return ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", localDB.Skipped)
+}
+
+// updateLocalFiles diffs the scanned files against the cached file list (by path, size and
+// modification time) and only re-processes the titles touched by added, removed or changed files.
+// It returns the id prefixes of the affected titles, and false when nothing changed.
+func (ldb *LocalSwitchDBManager) updateLocalFiles(cachedFiles []ExtendedFileInfo,
+	scannedFiles []ExtendedFileInfo,
+	progress ProgressUpdater,
+	titles map[string]*SwitchGameFiles,
+	skipped map[ExtendedFileInfo]SkippedFile,
+	fileTitles map[string][]string) (map[string]struct{}, bool) {
+
+	cached := map[string]ExtendedFileInfo{}
+	for _, file := range cachedFiles {
+		cached[filepath.Join(file.BaseFolder, file.FileName)] = file
+	}
+
+	var outdated []ExtendedFileInfo
+	var modified []ExtendedFileInfo
+	for _, file := range scannedFiles {
+		filePath := filepath.Join(file.BaseFolder, file.FileName)
+		old, ok := cached[filePath]
+		delete(cached, filePath)
+		if !ok {
+			modified = append(modified, file)
+		} else if old.Size != file.Size || old.ModTime != file.ModTime {
+			outdated = append(outdated, old)
+			modified = append(modified, file)
+		}
+	}
+	for _, file := range cached {
+		outdated = append(outdated, file)
+	}
+
+	if len(outdated) == 0 && len(modified) == 0 {
+		return nil, false
+	}
+	zap.S().Infof("Local library changed, %v files added/changed, %v files removed/changed", len(modified), len(outdated))
+
+	//collect the titles affected by the changes
+	affected := map[string]struct{}{}
+	for _, file := range outdated {
+		filePath := filepath.Join(file.BaseFolder, file.FileName)
+		for _, idPrefix := range fileTitles[filePath] {
+			affected[idPrefix] = struct{}{}
+		}
+		delete(fileTitles, filePath)
+		delete(skipped, file)
+		_ = ldb.db.DeleteEntry(DB_TABLE_FILE_SCAN_METADATA, getFileKey(file, filePath))
+	}
+
+	//the metadata of the added and changed files is read once, and merged with the other files of their titles
+	results := selectGameFiles(modified, skipped)
+	ldb.readGameMetadata(results, progress)
+	resultByFile := map[ExtendedFileInfo]*metadataResult{}
+	for _, result := range results {
+		resultByFile[result.file] = result
+		for _, metadata := range result.contentMap {
+			affected[GetTitlePrefix(metadata.TitleId)] = struct{}{}
+		}
+	}
+
+	//a reprocessed file is removed from all of its titles, so the titles of multi-content files spanning
+	//several titles are affected too, until no other title is reached
+	isModified := map[ExtendedFileInfo]struct{}{}
+	for _, file := range modified {
+		isModified[file] = struct{}{}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, file := range scannedFiles {
+			idPrefixes := fileTitles[filepath.Join(file.BaseFolder, file.FileName)]
+			if _, ok := isModified[file]; ok || !containsAny(affected, idPrefixes) {
+				continue
+			}
+			for _, idPrefix := range idPrefixes {
+				if _, ok := affected[idPrefix]; !ok {
+					affected[idPrefix] = struct{}{}
+					changed = true
+				}
+			}
+		}
+	}
+
+	//reprocess every file belonging to an affected title, keeping the scan order
+	var unmodified []ExtendedFileInfo
+	for _, file := range scannedFiles {
+		filePath := filepath.Join(file.BaseFolder, file.FileName)
+		if _, ok := isModified[file]; ok || !containsAny(affected, fileTitles[filePath]) {
+			continue
+		}
+		unmodified = append(unmodified, file)
+		delete(skipped, file)
+		delete(fileTitles, filePath)
+	}
+	unmodifiedResults := selectGameFiles(unmodified, skipped)
+	ldb.readGameMetadata(unmodifiedResults, progress)
+	for _, result := range unmodifiedResults {
+		resultByFile[result.file] = result
+	}
+	var toMerge []*metadataResult
+	for _, file := range scannedFiles {
+		if result, ok := resultByFile[file]; ok {
+			toMerge = append(toMerge, result)
+		}
+	}
+	for idPrefix := range affected {
+		delete(titles, idPrefix)
+	}
+
+	mergeGameMetadata(toMerge, titles, skipped, fileTitles)
+	return affected, true
+}
+
+func containsAny(set map[string]struct{}, values []string) bool {
+	for _, value := range values {
+		if _, ok := set[value]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 func scanFolder(folder string, recursive bool, files *[]ExtendedFileInfo, progress ProgressUpdater) error {
 	filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
 		if path == folder {
@@ -150,7 +335,7 @@ func scanFolder(folder string, recursive bool, files *[]ExtendedFileInfo, progre
 		if progress != nil {
 			progress.UpdateProgress(-1, -1, "Scanning "+info.Name())
 		}
-		*files = append(*files, ExtendedFileInfo{FileName: info.Name(), BaseFolder: base, Size: info.Size(), IsDir: info.IsDir()})
+		*files = append(*files, ExtendedFileInfo{FileName: info.Name(), BaseFolder: base, Size: info.Size(), ModTime: info.ModTime().UnixNano(), IsDir: info.IsDir()})
 
 		return nil
 	})
@@ -164,8 +349,16 @@ func (ldb *LocalSwitchDBManager) ClearScanData() error {
 func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 	progress ProgressUpdater,
 	titles map[string]*SwitchGameFiles,
-	skipped map[ExtendedFileInfo]SkippedFile) {
+	skipped map[ExtendedFileInfo]SkippedFile,
+	fileTitles map[string][]string) {
+
+	results := selectGameFiles(files, skipped)
+	ldb.readGameMetadata(results, progress)
+	mergeGameMetadata(results, titles, skipped, fileTitles)
+}
 
+// selectGameFiles returns the files whose metadata has to be read, the unsupported files are added to skipped
+func selectGameFiles(files []ExtendedFileInfo, skipped map[ExtendedFileInfo]SkippedFile) []*metadataResult {
 	settings := settings.ReadSettings("") // use empty path, as it will use existing settings instance
 	ignoreFileTypes := map[string]struct{}{}
 	for _, ext := range settings.IgnoreFileTypes {
@@ -179,16 +372,8 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 		ignoreFileTypes[".ds_store"] = struct{}{}
 	}
 
-	ind := 0
-	total := len(files)
+	var results []*metadataResult
 	for _, file := range files {
-		ind += 1
-		if progress != nil {
-			progress.UpdateProgress(ind, total, "Processing: "+file.FileName)
-		}
-
-		//scan sub-folders if flag is present
-		filePath := filepath.Join(file.BaseFolder, file.FileName)
 		if file.IsDir {
 			continue
 		}
@@ -221,11 +406,31 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 			continue
 		}
 
-		contentMap, err := ldb.getGameMetadata(file, filePath, skipped)
+		results = append(results, &metadataResult{file: file, isSplit: isSplit})
+	}
+	return results
+}
+
+// mergeGameMetadata adds the read files to the titles, in the order of the results so duplicate and old
+// update decisions are stable
+func mergeGameMetadata(results []*metadataResult,
+	titles map[string]*SwitchGameFiles,
+	skipped map[ExtendedFileInfo]SkippedFile,
+	fileTitles map[string][]string) {
 
-		if err != nil {
+	settings := settings.ReadSettings("") // use empty path, as it will use existing settings instance
+	for _, result := range results {
+		file := result.file
+		filePath := filepath.Join(file.BaseFolder, file.FileName)
+		isSplit := result.isSplit
+		contentMap := result.contentMap
+		for k, v := range result.skipped {
+			skipped[k] = v
+		}
+
+		if result.err != nil {
 			if _, ok := skipped[file]; !ok {
-				skipped[file] = SkippedFile{ReasonText: "Unable to determine Title ID / Version: " + err.Error(), ReasonCode: REASON_UNRECOGNISED}
+				skipped[file] = SkippedFile{ReasonText: "Unable to determine Title ID / Version: " + result.err.Error(), ReasonCode: REASON_UNRECOGNISED}
 			}
 			continue
 		}
@@ -243,17 +448,17 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 			}
 		}
 
+		sortByTitleId(baseMetadata)
+		sortByTitleId(otherMetadata)
+
 		hasBase := len(baseMetadata) > 0
 		orderedMetadata := append(baseMetadata, otherMetadata...)
 
 		for _, metadata := range orderedMetadata {
 
-			id := metadata.TitleId
-			idPrefix := id[0 : len(id)-3]
-			if !(strings.HasSuffix(id, "000") || strings.HasSuffix(id, "800")) {
-				intVar, _ := strconv.ParseUint(id[len(id)-4:len(id)-3], 16, 64)
-				h := fmt.Sprintf("%x", intVar-1)
-				idPrefix = id[0:len(id)-4] + h
+			idPrefix := GetTitlePrefix(metadata.TitleId)
+			if !slices.Contains(fileTitles[filePath], idPrefix) {
+				fileTitles[filePath] = append(fileTitles[filePath], idPrefix)
 			}
 
 			multiContent := len(contentMap) > 1
@@ -275,7 +480,7 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 				metadata.Type = "Update"
 
 				if update, ok := switchTitle.Updates[metadata.Version]; ok {
-					if settings.OrganizeOptions.PrioritizeCompressed && isCompressed(file.FileName) && !isCompressed(update.ExtendedInfo.FileName) {
+					if settings.OrganizeOptions.PrioritizeCompressed && IsCompressed(file.FileName) && !IsCompressed(update.ExtendedInfo.FileName) {
 						skipped[update.ExtendedInfo] = SkippedFile{ReasonCode: REASON_DUPLICATE, ReasonText: "Duplicate update file. Keeping compressed version.\nOld: " + filepath.Join(update.ExtendedInfo.BaseFolder, update.ExtendedInfo.FileName) + "\nNew: " + filepath.Join(file.BaseFolder, file.FileName)}
 						zap.S().Warnf("-->Duplicate update file found. Keeping compressed version [%v] over [%v]", file.FileName, update.ExtendedInfo.FileName)
 						delete(switchTitle.Updates, update.Metadata.Version)
@@ -314,7 +519,7 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 			if strings.HasSuffix(metadata.TitleId, "000") {
 				metadata.Type = "Base"
 				if switchTitle.BaseExist {
-					if settings.OrganizeOptions.PrioritizeCompressed && isCompressed(file.FileName) && !isCompressed(switchTitle.File.ExtendedInfo.FileName) {
+					if settings.OrganizeOptions.PrioritizeCompressed && IsCompressed(file.FileName) && !IsCompressed(switchTitle.File.ExtendedInfo.FileName) {
 						skipped[switchTitle.File.ExtendedInfo] = SkippedFile{ReasonCode: REASON_DUPLICATE, ReasonText: "Duplicate base file. Keeping compressed version.\nOld: " + filepath.Join(switchTitle.File.ExtendedInfo.BaseFolder, switchTitle.File.ExtendedInfo.FileName) + "\nNew: " + filepath.Join(file.BaseFolder, file.FileName)}
 						zap.S().Warnf("-->Duplicate base file found. Keeping compressed version [%v] over [%v]", file.FileName, switchTitle.File.ExtendedInfo.FileName)
 					} else {
@@ -337,7 +542,7 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 					zap.S().Warnf("-->Old DLC file found [%v] and [%v]", file.FileName, dlc.ExtendedInfo.FileName)
 					continue
 				} else if metadata.Version == dlc.Metadata.Version {
-					if settings.OrganizeOptions.PrioritizeCompressed && isCompressed(file.FileName) && !isCompressed(dlc.ExtendedInfo.FileName) {
+					if settings.OrganizeOptions.PrioritizeCompressed && IsCompressed(file.FileName) && !IsCompressed(dlc.ExtendedInfo.FileName) {
 						skipped[dlc.ExtendedInfo] = SkippedFile{ReasonCode: REASON_DUPLICATE, ReasonText: "Duplicate DLC file. Keeping compressed version.\nOld: " + filepath.Join(dlc.ExtendedInfo.BaseFolder, dlc.ExtendedInfo.FileName) + "\nNew: " + filepath.Join(file.BaseFolder, file.FileName)}
 						zap.S().Warnf("-->Duplicate DLC found. Keeping compressed version [%v] over [%v]", file.FileName, dlc.ExtendedInfo.FileName)
 						delete(switchTitle.Dlc, dlc.Metadata.TitleId)
@@ -358,6 +563,63 @@ func (ldb *LocalSwitchDBManager) processLocalFiles(files []ExtendedFileInfo,
 
 }
 
+type metadataResult struct {
+	file       ExtendedFileInfo
+	isSplit    bool
+	contentMap map[string]*switchfs.ContentMetaAttributes
+	skipped    map[ExtendedFileInfo]SkippedFile
+	err        error
+}
+
+// readGameMetadata reads the metadata of the given files using a bounded pool of workers.
+// The results are filled in place, so callers can still process them in their original order.
+func (ldb *LocalSwitchDBManager) readGameMetadata(results []*metadataResult, progress ProgressUpdater) {
+	workers := settings.ReadSettings("").ScanWorkers // use empty path, as it will use existing settings instance
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan *metadataResult)
+	done := make(chan *metadataResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for result := range jobs {
+				result.skipped = map[ExtendedFileInfo]SkippedFile{}
+				filePath := filepath.Join(result.file.BaseFolder, result.file.FileName)
+				result.contentMap, result.err = ldb.getGameMetadata(result.file, filePath, result.skipped)
+				done <- result
+			}
+		}()
+	}
+
+	go func() {
+		for _, result := range results {
+			jobs <- result
+		}
+		close(jobs)
+		wg.Wait()
+		close(done)
+	}()
+
+	// progress is reported from the calling goroutine only
+	ind := 0
+	for result := range done {
+		ind += 1
+		if progress != nil {
+			progress.UpdateProgress(ind, len(results), "Processing: "+result.file.FileName)
+		}
+	}
+}
+
+func sortByTitleId(metadata []*switchfs.ContentMetaAttributes) {
+	sort.Slice(metadata, func(i, j int) bool {
+		return metadata[i].TitleId < metadata[j].TitleId
+	})
+}
+
 func (ldb *LocalSwitchDBManager) getGameMetadata(file ExtendedFileInfo,
 	filePath string,
 	skipped map[ExtendedFileInfo]SkippedFile) (map[string]*switchfs.ContentMetaAttributes, error) {
@@ -365,7 +627,7 @@ func (ldb *LocalSwitchDBManager) getGameMetadata(file ExtendedFileInfo,
 	var metadata map[string]*switchfs.ContentMetaAttributes = nil
 	keys, _ := settings.SwitchKeys()
 	var err error
-	fileKey := filePath + "|" + file.FileName + "|" + strconv.Itoa(int(file.Size))
+	fileKey := getFileKey(file, filePath)
 	if keys != nil && keys.GetKey("header_key") != "" {
 		err = ldb.db.GetEntry(DB_TABLE_FILE_SCAN_METADATA, fileKey, &metadata)
 
@@ -373,22 +635,28 @@ func (ldb *LocalSwitchDBManager) getGameMetadata(file ExtendedFileInfo,
 			zap.S().Warnf("%v", err)
 		}
 
+		fileName := strings.ToLower(file.FileName)
 		if metadata != nil {
 			return metadata, nil
 		}
 
-		fileName := strings.ToLower(file.FileName)
 		if strings.HasSuffix(fileName, "nsp") ||
 			strings.HasSuffix(fileName, "nsz") {
 			metadata, err = switchfs.ReadNspMetadata(filePath)
-			if err != nil {
+			if errors.Is(err, switchfs.ErrMissingKey) {
+				skipped[file] = SkippedFile{ReasonCode: REASON_MISSING_KEYS, ReasonText: fmt.Sprintf("Failed to decrypt NSP [Reason: %v]", err)}
+				zap.S().Errorf("[file:%v] failed to decrypt NSP [reason: %v]\n", file.FileName, err)
+			} else if err != nil {
 				skipped[file] = SkippedFile{ReasonCode: REASON_MALFORMED_FILE, ReasonText: fmt.Sprintf("Failed to read NSP [Reason: %v]", err)}
 				zap.S().Errorf("[file:%v] failed to read NSP [reason: %v]\n", file.FileName, err)
 			}
 		} else if strings.HasSuffix(fileName, "xci") ||
 			strings.HasSuffix(fileName, "xcz") {
 			metadata, err = switchfs.ReadXciMetadata(filePath)
-			if err != nil {
+			if errors.Is(err, switchfs.ErrMissingKey) {
+				skipped[file] = SkippedFile{ReasonCode: REASON_MISSING_KEYS, ReasonText: fmt.Sprintf("Failed to decrypt XCI [Reason: %v]", err)}
+				zap.S().Errorf("[file:%v] failed to decrypt XCI [reason: %v]\n", file.FileName, err)
+			} else if err != nil {
 				skipped[file] = SkippedFile{ReasonCode: REASON_MALFORMED_FILE, ReasonText: fmt.Sprintf("Failed to read XCI [Reason: %v]", err)}
 				zap.S().Errorf("[file:%v] failed to read file [reason: %v]\n", file.FileName, err)
 			}
@@ -402,6 +670,7 @@ func (ldb *LocalSwitchDBManager) getGameMetadata(file ExtendedFileInfo,
 	}
 
 	if metadata != nil {
+		saveIcons(ldb.baseFolder, metadata)
 		err = ldb.db.AddEntry(DB_TABLE_FILE_SCAN_METADATA, fileKey, metadata)
 
 		if err != nil {
@@ -425,6 +694,35 @@ func (ldb *LocalSwitchDBManager) getGameMetadata(file ExtendedFileInfo,
 	return metadata, nil
 }
 
+func getFileKey(file ExtendedFileInfo, filePath string) string {
+	return filePath + "|" + file.FileName + "|" + strconv.Itoa(int(file.Size)) + "|" + strconv.Itoa(metadataVersion)
+}
+
+// GetTitlePrefix returns the id prefix shared by a base title, its updates and its DLC.
+// Dlc adds 1 to 4th char starting from the right (always odd) and
+// have a running counter (starting with 001) in the 3 last chars
+func GetTitlePrefix(id string) string {
+	idPrefix := id[0 : len(id)-3]
+	if !(strings.HasSuffix(id, "000") || strings.HasSuffix(id, "800")) {
+		intVar, _ := strconv.ParseUint(id[len(id)-4:len(id)-3], 16, 64)
+		h := fmt.Sprintf("%x", intVar-1)
+		idPrefix = id[0:len(id)-4] + h
+	}
+	return idPrefix
+}
+
+func isSupportedFile(fileName string) bool {
+	fileName = strings.ToLower(fileName)
+	if len(fileName) < 2 {
+		return false
+	}
+	if partNum, err := strconv.Atoi(fileName[len(fileName)-2:]); err == nil {
+		return partNum == 0
+	}
+	fileExtension := filepath.Ext(fileName)
+	return fileExtension == ".xci" || fileExtension == ".xcz" || fileExtension == ".nsp" || fileExtension == ".nsz"
+}
+
 func parseVersionFromFileName(fileName string) (*int, error) {
 	res := versionRegex.FindStringSubmatch(fileName)
 	if len(res) != 2 {
@@ -455,6 +753,6 @@ func ParseTitleNameFromFileName(fileName string) string {
 	return fileName
 }
 
-func isCompressed(filename string) bool {
+func IsCompressed(filename string) bool {
 	return strings.HasSuffix(strings.ToLower(filename), ".xcz") || strings.HasSuffix(strings.ToLower(filename), ".nsz")
-}
\ No newline at end of file
+}
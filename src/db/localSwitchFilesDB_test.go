@@ -1,6 +1,16 @@
 package db
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/trembon/switch-library-manager/settings"
+	"github.com/trembon/switch-library-manager/switchfs"
+)
 
 func TestParseTitleIdFromFileName(t *testing.T) {
 	fileName := "Super Mario [0100000000010000][v0].nsp"
@@ -23,3 +33,265 @@ func TestParseTitleIdFromFileNameInvalid(t *testing.T) {
 		t.Fatalf("expected error for invalid title id")
 	}
 }
+
+func TestGetTitlePrefix(t *testing.T) {
+	tests := map[string]string{
+		"0100000000010000": "0100000000010",
+		"0100000000010800": "0100000000010",
+		"0100000000011001": "0100000000010",
+		"010000000001b00a": "010000000001a",
+	}
+	for id, expected := range tests {
+		if prefix := GetTitlePrefix(id); prefix != expected {
+			t.Errorf("expected prefix %v for %v, got %v", expected, id, prefix)
+		}
+	}
+}
+
+func TestUpdateLocalSwitchFilesDB(t *testing.T) {
+	settings.ReadSettings(t.TempDir())
+	const (
+		base   = "Game [0100000000010000][v0].nsp"
+		update = "Game [0100000000010800][v65536].nsp"
+		dlc    = "Game DLC [0100000000011001][v0].nsp"
+		other  = "Other [0100000000020000][v0].nsp"
+	)
+	tests := []struct {
+		name       string
+		change     func(folder string)
+		affected   []string
+		fileTitles map[string][]string
+		check      func(localDB *LocalSwitchFilesDB) bool
+	}{
+		{
+			name:     "unchanged",
+			change:   func(folder string) {},
+			affected: []string{},
+			fileTitles: map[string][]string{
+				base: {"0100000000010"}, update: {"0100000000010"}, other: {"0100000000020"},
+			},
+		},
+		{
+			name: "added",
+			change: func(folder string) {
+				_ = os.WriteFile(filepath.Join(folder, dlc), []byte("dlc"), 0644)
+			},
+			affected: []string{"0100000000010"},
+			fileTitles: map[string][]string{
+				base: {"0100000000010"}, update: {"0100000000010"}, dlc: {"0100000000010"}, other: {"0100000000020"},
+			},
+			check: func(localDB *LocalSwitchFilesDB) bool {
+				title := localDB.TitlesMap["0100000000010"]
+				return title.BaseExist && len(title.Updates) == 1 && len(title.Dlc) == 1
+			},
+		},
+		{
+			name: "removed",
+			change: func(folder string) {
+				_ = os.Remove(filepath.Join(folder, update))
+			},
+			affected: []string{"0100000000010"},
+			fileTitles: map[string][]string{
+				base: {"0100000000010"}, other: {"0100000000020"},
+			},
+			check: func(localDB *LocalSwitchFilesDB) bool {
+				title := localDB.TitlesMap["0100000000010"]
+				return title.BaseExist && len(title.Updates) == 0 && localDB.TitlesMap["0100000000020"] != nil
+			},
+		},
+		{
+			name: "modified",
+			change: func(folder string) {
+				_ = os.WriteFile(filepath.Join(folder, other), []byte("modified"), 0644)
+			},
+			affected: []string{"0100000000020"},
+			fileTitles: map[string][]string{
+				base: {"0100000000010"}, update: {"0100000000010"}, other: {"0100000000020"},
+			},
+			check: func(localDB *LocalSwitchFilesDB) bool {
+				return localDB.TitlesMap["0100000000020"].File.ExtendedInfo.Size == int64(len("modified"))
+			},
+		},
+		{
+			name: "unsupported file added",
+			change: func(folder string) {
+				_ = os.WriteFile(filepath.Join(folder, "notes.txt"), []byte("notes"), 0644)
+			},
+			affected: []string{},
+			fileTitles: map[string][]string{
+				base: {"0100000000010"}, update: {"0100000000010"}, other: {"0100000000020"},
+			},
+			check: func(localDB *LocalSwitchFilesDB) bool {
+				return len(localDB.Skipped) == 1
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			folder := t.TempDir()
+			for _, name := range []string{base, update, other} {
+				_ = os.WriteFile(filepath.Join(folder, name), []byte(name), 0644)
+			}
+			ldb, err := NewLocalSwitchDBManager(t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to create the db: %v", err)
+			}
+			defer ldb.Close()
+			localDB, err := ldb.CreateLocalSwitchFilesDB([]string{folder}, nil, false, false)
+			if err != nil {
+				t.Fatalf("failed to scan: %v", err)
+			}
+
+			test.change(folder)
+			affected, err := ldb.UpdateLocalSwitchFilesDB(localDB, []string{folder}, nil, false)
+			if err != nil {
+				t.Fatalf("failed to update: %v", err)
+			}
+			affectedIds := []string{}
+			for idPrefix := range affected {
+				affectedIds = append(affectedIds, idPrefix)
+			}
+			slices.Sort(affectedIds)
+			if !reflect.DeepEqual(affectedIds, test.affected) {
+				t.Errorf("expected affected titles %v, got %v", test.affected, affectedIds)
+			}
+
+			var fileTitles map[string][]string
+			_ = ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "file-titles", &fileTitles)
+			expected := map[string][]string{}
+			for name, idPrefixes := range test.fileTitles {
+				expected[filepath.Join(folder, name)] = idPrefixes
+			}
+			if !reflect.DeepEqual(fileTitles, expected) {
+				t.Errorf("expected file titles %v, got %v", expected, fileTitles)
+			}
+			if test.check != nil && !test.check(localDB) {
+				t.Errorf("unexpected library %v", localDB.TitlesMap)
+			}
+		})
+	}
+}
+
+func TestCreateLocalSwitchFilesDBEmptyLibrary(t *testing.T) {
+	settings.ReadSettings(t.TempDir())
+	folder := t.TempDir()
+	_ = os.WriteFile(filepath.Join(folder, "notes.txt"), []byte("notes"), 0644)
+	ldb, err := NewLocalSwitchDBManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create the db: %v", err)
+	}
+	defer ldb.Close()
+	if _, err = ldb.CreateLocalSwitchFilesDB([]string{folder}, nil, false, false); err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+
+	// a full rescan would rebuild the skipped files
+	marker := ExtendedFileInfo{FileName: "marker"}
+	skipped := map[ExtendedFileInfo]SkippedFile{}
+	_ = ldb.db.GetEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", &skipped)
+	skipped[marker] = SkippedFile{}
+	_ = ldb.db.AddEntry(DB_TABLE_LOCAL_LIBRARY, "skipped", skipped)
+
+	localDB, err := ldb.CreateLocalSwitchFilesDB([]string{folder}, nil, false, false)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	if _, ok := localDB.Skipped[marker]; !ok || len(localDB.TitlesMap) != 0 {
+		t.Fatalf("expected the empty library to be loaded from the cache, got %v", localDB.Skipped)
+	}
+}
+
+func TestUpdateLocalSwitchFilesDBMultiContent(t *testing.T) {
+	settings.ReadSettings(t.TempDir())
+	// with a header key the metadata is read from the scan cache
+	keysFolder := t.TempDir()
+	keysPath := filepath.Join(keysFolder, "prod.keys")
+	_ = os.WriteFile(keysPath, []byte("header_key = "+strings.Repeat("00", 0x20)+"\n"), 0644)
+	if _, err := settings.InitSwitchKeys(keysFolder); err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.WriteFile(keysPath, []byte{}, 0644)
+		_, _ = settings.InitSwitchKeys(keysFolder)
+	})
+
+	folder := t.TempDir()
+	ldb, err := NewLocalSwitchDBManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create the db: %v", err)
+	}
+	defer ldb.Close()
+	writeFile := func(name string, content string, metadata ...*switchfs.ContentMetaAttributes) {
+		filePath := filepath.Join(folder, name)
+		_ = os.WriteFile(filePath, []byte(content), 0644)
+		contentMap := map[string]*switchfs.ContentMetaAttributes{}
+		for _, m := range metadata {
+			contentMap[m.TitleId] = m
+		}
+		file := ExtendedFileInfo{FileName: name, BaseFolder: folder, Size: int64(len(content))}
+		_ = ldb.db.AddEntry(DB_TABLE_FILE_SCAN_METADATA, getFileKey(file, filePath), contentMap)
+	}
+	// a single file holding two games
+	writeFile("collection.xci", "collection",
+		&switchfs.ContentMetaAttributes{TitleId: "0100000000010000", Type: "BASE"},
+		&switchfs.ContentMetaAttributes{TitleId: "0100000000020000", Type: "BASE"})
+	writeFile("update.nsp", "update",
+		&switchfs.ContentMetaAttributes{TitleId: "0100000000010800", Version: 65536})
+
+	localDB, err := ldb.CreateLocalSwitchFilesDB([]string{folder}, nil, false, false)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	// the update of the first game is replaced
+	writeFile("update.nsp", "newer update",
+		&switchfs.ContentMetaAttributes{TitleId: "0100000000010800", Version: 131072})
+	affected, err := ldb.UpdateLocalSwitchFilesDB(localDB, []string{folder}, nil, false)
+	if err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	if len(affected) != 2 {
+		t.Errorf("expected both games of the collection to be affected, got %v", affected)
+	}
+	if len(localDB.Skipped) != 0 {
+		t.Errorf("expected no skipped files, got %v", localDB.Skipped)
+	}
+	for _, idPrefix := range []string{"0100000000010", "0100000000020"} {
+		title := localDB.TitlesMap[idPrefix]
+		if title == nil || !title.BaseExist || title.File.ExtendedInfo.FileName != "collection.xci" {
+			t.Errorf("expected %v to be in collection.xci, got %+v", idPrefix, title)
+		}
+	}
+	if update := localDB.TitlesMap["0100000000010"].Updates; len(update) != 1 || update[131072].ExtendedInfo.FileName != "update.nsp" {
+		t.Errorf("expected the newer update, got %v", update)
+	}
+}
+
+func TestSaveSkippedFiles(t *testing.T) {
+	settings.ReadSettings(t.TempDir())
+	folder := t.TempDir()
+	_ = os.WriteFile(filepath.Join(folder, "Game [0100000000010000][v0].nsp"), []byte("game"), 0644)
+	ldb, err := NewLocalSwitchDBManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create the db: %v", err)
+	}
+	defer ldb.Close()
+	localDB, err := ldb.CreateLocalSwitchFilesDB([]string{folder}, nil, false, false)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+
+	file := localDB.TitlesMap["0100000000010"].File.ExtendedInfo
+	localDB.Skipped[file] = SkippedFile{ReasonCode: REASON_CORRUPTED_FILE, ReasonText: "Corrupted or truncated file"}
+	if err = ldb.SaveSkippedFiles(localDB); err != nil {
+		t.Fatalf("failed to save skipped files: %v", err)
+	}
+
+	localDB, err = ldb.CreateLocalSwitchFilesDB([]string{folder}, nil, false, false)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	if skipped, ok := localDB.Skipped[file]; !ok || skipped.ReasonCode != REASON_CORRUPTED_FILE {
+		t.Fatalf("expected the corrupted file to be kept, got %v", localDB.Skipped)
+	}
+}
@@ -83,6 +83,17 @@ func (pd *PersistentDB) AddEntry(tableName string, key string, value interface{}
 	return err
 }
 
+func (pd *PersistentDB) DeleteEntry(tableName string, key string) error {
+	err := pd.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tableName))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+	return err
+}
+
 func (pd *PersistentDB) GetEntry(tableName string, key string, value interface{}) error {
 	err := pd.db.View(func(tx *bolt.Tx) error {
 
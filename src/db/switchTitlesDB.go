@@ -36,20 +36,22 @@ type SwitchTitlesDB struct {
 
 func CreateSwitchTitleDB(titlesFile, versionsFile io.Reader) (*SwitchTitlesDB, error) {
 	//parse the titles objects
-	var titles = map[string]TitleAttributes{}
-	err := decodeToJsonObject(titlesFile, &titles)
+	titles, err := decodeTitlesData(titlesFile, PROVIDER_TYPE_TITLES)
 	if err != nil {
 		return nil, err
 	}
 
 	//parse the titles objects
 	//titleID -> versionId-> release date
-	var versions = map[string]map[int]string{}
-	err = decodeToJsonObject(versionsFile, &versions)
+	versions, err := decodeTitlesData(versionsFile, PROVIDER_TYPE_VERSIONS)
 	if err != nil {
 		return nil, err
 	}
 
+	return buildSwitchTitlesDB(titles.Titles, versions.Versions), nil
+}
+
+func buildSwitchTitlesDB(titles map[string]TitleAttributes, versions map[string]map[int]string) *SwitchTitlesDB {
 	result := SwitchTitlesDB{TitlesMap: map[string]*SwitchTitle{}}
 	for id, attr := range titles {
 		id = strings.ToLower(id)
@@ -98,5 +100,5 @@ func CreateSwitchTitleDB(titlesFile, versionsFile io.Reader) (*SwitchTitlesDB, e
 
 	}
 
-	return &result, nil
+	return &result
 }
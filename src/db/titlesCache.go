@@ -0,0 +1,43 @@
+package db
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+const DB_TABLE_TITLES_CACHE = "titles-db"
+
+// titlesCacheVersion is increased when the layout of the cached titles database changes
+const titlesCacheVersion = 1
+
+// getCachedTitlesDB returns the titles database built from the providers versions identified by key,
+// nil when it was not cached
+func (ldb *LocalSwitchDBManager) getCachedTitlesDB(key string) *SwitchTitlesDB {
+	cachedKey := ""
+	if err := ldb.db.GetEntry(DB_TABLE_TITLES_CACHE, "key", &cachedKey); err != nil || cachedKey != titlesCacheKey(key) {
+		return nil
+	}
+	titlesDB := &SwitchTitlesDB{}
+	if err := ldb.db.GetEntry(DB_TABLE_TITLES_CACHE, "titles", &titlesDB.TitlesMap); err != nil || len(titlesDB.TitlesMap) == 0 {
+		zap.S().Warnf("Ignoring the cached titles database - %v", err)
+		return nil
+	}
+	return titlesDB
+}
+
+func (ldb *LocalSwitchDBManager) saveTitlesDB(key string, titlesDB *SwitchTitlesDB) {
+	// the key is removed first, so a partly saved titles database is never used
+	_ = ldb.db.DeleteEntry(DB_TABLE_TITLES_CACHE, "key")
+	if err := ldb.db.AddEntry(DB_TABLE_TITLES_CACHE, "titles", titlesDB.TitlesMap); err != nil {
+		zap.S().Warnf("Failed to cache the titles database - %v", err)
+		return
+	}
+	if err := ldb.db.AddEntry(DB_TABLE_TITLES_CACHE, "key", titlesCacheKey(key)); err != nil {
+		zap.S().Warnf("Failed to cache the titles database - %v", err)
+	}
+}
+
+func titlesCacheKey(key string) string {
+	return strconv.Itoa(titlesCacheVersion) + "|" + key
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+func TestLoadSwitchTitlesDBCache(t *testing.T) {
+	folder := t.TempDir()
+	titlesPath := filepath.Join(folder, settings.TITLE_JSON_FILENAME)
+	_ = os.WriteFile(titlesPath, []byte(`{"0100000000010000":{"id":"0100000000010000","name":"Game"}}`), 0644)
+	_ = os.WriteFile(filepath.Join(folder, settings.VERSIONS_JSON_FILENAME), []byte(`{"0100000000010000":{"65536":"2020-01-01"}}`), 0644)
+
+	manager, err := NewLocalSwitchDBManager(folder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.Close()
+
+	load := func() *SwitchTitlesDB {
+		titlesDB, err := LoadSwitchTitlesDB(folder, true, manager, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return titlesDB
+	}
+	if title := load().TitlesMap["0100000000010"]; title == nil || title.Attributes.Name != "Game" {
+		t.Fatalf("expected the title of titles.json, got %+v", title)
+	}
+
+	// the cached titles database is used while the files are unchanged
+	manager.saveTitlesDB(cachedKey(t, manager), &SwitchTitlesDB{TitlesMap: map[string]*SwitchTitle{"0100000000010": {Attributes: TitleAttributes{Name: "Cached"}}}})
+	if title := load().TitlesMap["0100000000010"]; title == nil || title.Attributes.Name != "Cached" {
+		t.Fatalf("expected the cached title, got %+v", title)
+	}
+
+	_ = os.WriteFile(titlesPath, []byte(`{"0100000000010000":{"id":"0100000000010000","name":"New name"}}`), 0644)
+	_ = os.Chtimes(titlesPath, time.Now(), time.Now().Add(time.Minute))
+	if title := load().TitlesMap["0100000000010"]; title == nil || title.Attributes.Name != "New name" {
+		t.Fatalf("expected the cache to be replaced when titles.json changes, got %+v", title)
+	}
+}
+
+func cachedKey(t *testing.T, manager *LocalSwitchDBManager) string {
+	key := ""
+	if err := manager.db.GetEntry(DB_TABLE_TITLES_CACHE, "key", &key); err != nil || key == "" {
+		t.Fatalf("expected the titles database to be cached: %v", err)
+	}
+	return strings.SplitN(key, "|", 2)[1]
+}
+
+func TestDecodeTitlesDataMalformed(t *testing.T) {
+	for _, content := range []string{`[]`, `{"0100000000010000":{"id":"0100000000010000"}`, `{"0100000000010000":"name"}`} {
+		if _, err := decodeTitlesData(strings.NewReader(content), PROVIDER_TYPE_TITLES); err == nil {
+			t.Errorf("expected an error for %v", content)
+		}
+	}
+	for _, content := range []string{`[]`, `{"0100000000010000":{"id":"0100000000010000"}`, `{"a":1,}`} {
+		if err := validateJsonObject(strings.NewReader(content)); err == nil {
+			t.Errorf("expected %v to be invalid", content)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/settings"
+	"go.uber.org/zap"
+)
+
+const (
+	PROVIDER_TYPE_TITLES   = "titles"   // tinfoil titles.json, keyed by title id
+	PROVIDER_TYPE_TITLEDB  = "titledb"  // blawar titledb region file (like US.en.json), keyed by nsu id
+	PROVIDER_TYPE_VERSIONS = "versions" // blawar versions.json, title id -> version -> release date
+	PROVIDER_TYPE_CSV      = "csv"      // csv file with a header row, id column required
+
+	PROVIDER_MODE_OVERRIDE = "override"
+	PROVIDER_MODE_FILL     = "fill"
+
+	PROVIDERS_FOLDER = "providers"
+)
+
+// TitlesData is the part of the titles database read from a single provider
+type TitlesData struct {
+	Titles   map[string]TitleAttributes // by lower case title id
+	Versions map[string]map[int]string  // by lower case base title id
+}
+
+// TitlesProvider is a source of titles and/or versions, merged into a SwitchTitlesDB
+type TitlesProvider interface {
+	Name() string
+	// Override tells if the values of the provider replace the ones of the previous providers,
+	// or only set the values they are missing
+	Override() bool
+	// Required tells if the titles database can not be created without the provider
+	Required() bool
+	// Update downloads a newer version of the provider unless offline, and returns a key which changes
+	// with the version that Load reads
+	Update(offline bool) (string, error)
+	Load() (*TitlesData, error)
+}
+
+// fileProvider reads a provider from a local file, or from a url cached in a local file
+type fileProvider struct {
+	name      string
+	format    string
+	override  bool
+	required  bool
+	url       string
+	cachePath string
+	etag      *string
+}
+
+func (p *fileProvider) Name() string {
+	return p.name
+}
+
+func (p *fileProvider) Override() bool {
+	return p.override
+}
+
+func (p *fileProvider) Required() bool {
+	return p.required
+}
+
+func (p *fileProvider) Update(offline bool) (string, error) {
+	if p.url != "" && !offline {
+		validate := validateJsonObject
+		if p.format == PROVIDER_TYPE_CSV {
+			validate = func(reader io.Reader) error {
+				_, err := csv.NewReader(reader).Read()
+				return err
+			}
+		}
+		file, etag, err := loadAndUpdateFile(p.url, p.cachePath, *p.etag, validate)
+		if err != nil {
+			return "", err
+		}
+		file.Close()
+		*p.etag = etag
+	}
+
+	fileInfo, err := os.Stat(p.cachePath)
+	if err != nil || fileInfo.Size() == 0 {
+		if p.url != "" {
+			return "", errors.New("offline mode is enabled, but " + filepath.Base(p.cachePath) + " is missing - import a titles database snapshot")
+		}
+		return "", errors.New(p.cachePath + " is missing or empty")
+	}
+	// the local file also changes without a new etag, when a snapshot is imported
+	key := fmt.Sprintf("%v:%v:%v:%v", p.format, fileInfo.Size(), fileInfo.ModTime().UnixNano(), p.override)
+	if p.etag != nil && *p.etag != "" {
+		key += ":" + *p.etag
+	}
+	return key, nil
+}
+
+func (p *fileProvider) Load() (*TitlesData, error) {
+	file, err := os.Open(p.cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decodeTitlesData(file, p.format)
+}
+
+// NewTitlesProvider creates a provider from its settings, the etag of an url provider is kept in the settings
+func NewTitlesProvider(baseFolder string, config *settings.TitlesProviderSettings) (TitlesProvider, error) {
+	switch config.Type {
+	case PROVIDER_TYPE_TITLES, PROVIDER_TYPE_TITLEDB, PROVIDER_TYPE_VERSIONS, PROVIDER_TYPE_CSV:
+	default:
+		return nil, errors.New("unsupported titles provider type [" + config.Type + "]")
+	}
+	override := true
+	switch config.Mode {
+	case "", PROVIDER_MODE_OVERRIDE:
+	case PROVIDER_MODE_FILL:
+		override = false
+	default:
+		return nil, errors.New("unsupported titles provider mode [" + config.Mode + "]")
+	}
+	if config.Name == "" {
+		return nil, errors.New("titles provider name is missing")
+	}
+
+	provider := &fileProvider{name: config.Name, format: config.Type, override: override, etag: &config.Etag}
+	if config.Path != "" {
+		provider.cachePath = config.Path
+	} else if config.Url != "" {
+		provider.url = config.Url
+		provider.cachePath = filepath.Join(baseFolder, PROVIDERS_FOLDER, providerFileName(config.Name, config.Type))
+	} else {
+		return nil, errors.New("titles provider [" + config.Name + "] has neither a path nor an url")
+	}
+	return provider, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func providerFileName(name string, format string) string {
+	extension := ".json"
+	if format == PROVIDER_TYPE_CSV {
+		extension = ".csv"
+	}
+	return unsafeFileNameChars.ReplaceAllString(name, "_") + extension
+}
+
+// defaultTitlesProviders returns the providers of the titles_json_url and versions_json_url settings
+func defaultTitlesProviders(baseFolder string, settingsObj *settings.AppSettings) []TitlesProvider {
+	return []TitlesProvider{
+		&fileProvider{name: settings.TITLE_JSON_FILENAME, format: PROVIDER_TYPE_TITLES, override: true, required: true,
+			url: settingsObj.TitlesJsonUrl, cachePath: filepath.Join(baseFolder, settings.TITLE_JSON_FILENAME), etag: &settingsObj.TitlesEtag},
+		&fileProvider{name: settings.VERSIONS_JSON_FILENAME, format: PROVIDER_TYPE_VERSIONS, override: true, required: true,
+			url: settingsObj.VersionsJsonUrl, cachePath: filepath.Join(baseFolder, settings.VERSIONS_JSON_FILENAME), etag: &settingsObj.VersionsEtag},
+	}
+}
+
+// MergeTitlesProviders loads the providers in order and merges them into a single titles database,
+// a provider which fails to load is skipped unless it is required
+func MergeTitlesProviders(providers []TitlesProvider, offline bool, progress ProgressUpdater) (*SwitchTitlesDB, error) {
+	providers, _, err := updateTitlesProviders(providers, offline, progress)
+	if err != nil {
+		return nil, err
+	}
+	return loadTitlesProviders(providers, progress), nil
+}
+
+// updateTitlesProviders updates the providers, and returns the ones which can be loaded with a key
+// identifying the versions of all of them
+func updateTitlesProviders(providers []TitlesProvider, offline bool, progress ProgressUpdater) ([]TitlesProvider, string, error) {
+	var available []TitlesProvider
+	var keys []string
+	for i, provider := range providers {
+		if progress != nil {
+			progress.UpdateProgress(i+1, len(providers)+1, "Loading "+provider.Name())
+		}
+		key, err := provider.Update(offline)
+		if err != nil && provider.Required() {
+			return nil, "", errors.New("failed to load " + provider.Name() + " [reason:" + err.Error() + "]")
+		} else if err != nil {
+			zap.S().Warnf("Skipping titles provider [%v] - %v", provider.Name(), err)
+			continue
+		}
+		available = append(available, provider)
+		keys = append(keys, provider.Name()+"="+key)
+	}
+	return available, strings.Join(keys, "|"), nil
+}
+
+func loadTitlesProviders(providers []TitlesProvider, progress ProgressUpdater) *SwitchTitlesDB {
+	if progress != nil {
+		progress.UpdateProgress(len(providers)+1, len(providers)+1, "Processing switch titles and updates")
+	}
+	var titles map[string]TitleAttributes
+	var versions map[string]map[int]string
+	for _, provider := range providers {
+		data, err := provider.Load()
+		if err != nil {
+			zap.S().Warnf("Skipping titles provider [%v] - %v", provider.Name(), err)
+			continue
+		}
+		if titles == nil {
+			// nothing to merge with, use the (usually largest) first provider as is instead of copying it
+			titles, versions = data.Titles, data.Versions
+			continue
+		}
+		mergeTitlesData(titles, versions, data, provider.Override())
+	}
+	if titles == nil {
+		titles, versions = map[string]TitleAttributes{}, map[string]map[int]string{}
+	}
+	return buildSwitchTitlesDB(titles, versions)
+}
+
+func mergeTitlesData(titles map[string]TitleAttributes, versions map[string]map[int]string, data *TitlesData, override bool) {
+	for id, attr := range data.Titles {
+		if existing, ok := titles[id]; ok {
+			titles[id] = mergeTitleAttributes(existing, attr, override)
+		} else {
+			titles[id] = attr
+		}
+	}
+	for id, titleVersions := range data.Versions {
+		existing, ok := versions[id]
+		if !ok {
+			existing = map[int]string{}
+			versions[id] = existing
+		}
+		for version, date := range titleVersions {
+			if _, ok := existing[version]; !ok || override {
+				existing[version] = date
+			}
+		}
+	}
+}
+
+// mergeTitleAttributes sets the values of the provider which are not empty, existing values
+// are only replaced when overriding
+func mergeTitleAttributes(existing TitleAttributes, attr TitleAttributes, override bool) TitleAttributes {
+	mergeString := func(current *string, value string) {
+		if value != "" && (override || *current == "") {
+			*current = value
+		}
+	}
+	mergeString(&existing.Name, attr.Name)
+	mergeString(&existing.Region, attr.Region)
+	mergeString(&existing.Publisher, attr.Publisher)
+	mergeString(&existing.IconUrl, attr.IconUrl)
+	mergeString(&existing.BannerUrl, attr.BannerUrl)
+	mergeString(&existing.Description, attr.Description)
+	if attr.Version != "" && (override || existing.Version == "") {
+		existing.Version = attr.Version
+	}
+	if attr.ReleaseDate != 0 && (override || existing.ReleaseDate == 0) {
+		existing.ReleaseDate = attr.ReleaseDate
+	}
+	if attr.Size != 0 && (override || existing.Size == 0) {
+		existing.Size = attr.Size
+	}
+	if len(attr.Screenshots) != 0 && (override || len(existing.Screenshots) == 0) {
+		existing.Screenshots = attr.Screenshots
+	}
+	existing.IsDemo = existing.IsDemo || attr.IsDemo
+	return existing
+}
+
+func decodeTitlesData(reader io.Reader, format string) (*TitlesData, error) {
+	data := &TitlesData{Titles: map[string]TitleAttributes{}, Versions: map[string]map[int]string{}}
+	// the files are read one title at a time, as titles.json is too large to be held in memory twice
+	switch format {
+	case PROVIDER_TYPE_TITLES, PROVIDER_TYPE_TITLEDB:
+		err := decodeJsonObjectEntries(reader, func(key string, decoder *json.Decoder) error {
+			attr := TitleAttributes{}
+			if err := decoder.Decode(&attr); err != nil {
+				return err
+			}
+			// titledb files are keyed by nsu id, the title id is only in the attributes
+			id := attr.Id
+			if format == PROVIDER_TYPE_TITLES && id == "" {
+				id = key
+			}
+			addTitle(data, id, attr)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case PROVIDER_TYPE_VERSIONS:
+		err := decodeJsonObjectEntries(reader, func(id string, decoder *json.Decoder) error {
+			titleVersions := map[int]string{}
+			if err := decoder.Decode(&titleVersions); err != nil {
+				return err
+			}
+			data.Versions[strings.ToLower(id)] = titleVersions
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case PROVIDER_TYPE_CSV:
+		return decodeCsvTitles(reader)
+	}
+	return data, nil
+}
+
+func addTitle(data *TitlesData, id string, attr TitleAttributes) {
+	if !isTitleId(id) {
+		return
+	}
+	attr.Id = strings.ToLower(id)
+	data.Titles[attr.Id] = attr
+}
+
+func isTitleId(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// decodeCsvTitles reads the titles of a csv file, columns are matched by the json names of TitleAttributes
+// (id, name, region, publisher, releaseDate, iconUrl, bannerUrl, description, isDemo)
+func decodeCsvTitles(reader io.Reader) (*TitlesData, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	if _, ok := columns["id"]; !ok {
+		return nil, errors.New("csv file has no id column")
+	}
+
+	data := &TitlesData{Titles: map[string]TitleAttributes{}, Versions: map[string]map[int]string{}}
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		attr := TitleAttributes{
+			Name:        value("name"),
+			Region:      value("region"),
+			Publisher:   value("publisher"),
+			IconUrl:     value("iconUrl"),
+			BannerUrl:   value("bannerUrl"),
+			Description: value("description"),
+		}
+		attr.ReleaseDate, _ = strconv.Atoi(value("releaseDate"))
+		attr.IsDemo, _ = strconv.ParseBool(value("isDemo"))
+		addTitle(data, value("id"), attr)
+	}
+	return data, nil
+}
+
+// titlesProviders returns the default titles/versions providers followed by the ones of the settings
+func titlesProviders(baseFolder string, settingsObj *settings.AppSettings) []TitlesProvider {
+	providers := defaultTitlesProviders(baseFolder, settingsObj)
+	for i := range settingsObj.TitlesProviders {
+		provider, err := NewTitlesProvider(baseFolder, &settingsObj.TitlesProviders[i])
+		if err != nil {
+			zap.S().Warnf("Ignoring titles provider - %v", err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+func TestMergeTitlesProviders(t *testing.T) {
+	folder := t.TempDir()
+	files := map[string]string{
+		"titles.json":   `{"0100000000010000":{"id":"0100000000010000","name":"Game","publisher":"Nintendo"},"0100000000010800":{"id":"0100000000010800"}}`,
+		"versions.json": `{"0100000000010000":{"65536":"2020-01-01"}}`,
+		"JP.ja.json":    `{"70010000000025":{"id":"0100000000010000","name":"ゲーム","region":"JP"},"70010000000026":{"id":null,"name":"Unknown"}}`,
+		"team.csv":      "id,name,region,publisher\n0100000000010000,Team name,EU,Team publisher\n0100000000020000,Homebrew,,Team\ninvalid,Ignored,,\n",
+		"extra.json":    `{"0100000000010000":{"131072":"2021-01-01","65536":"2019-01-01"}}`,
+	}
+	for name, content := range files {
+		_ = os.WriteFile(filepath.Join(folder, name), []byte(content), 0644)
+	}
+
+	var providers []TitlesProvider
+	for _, config := range []settings.TitlesProviderSettings{
+		{Name: "titles", Type: PROVIDER_TYPE_TITLES, Path: filepath.Join(folder, "titles.json")},
+		{Name: "versions", Type: PROVIDER_TYPE_VERSIONS, Path: filepath.Join(folder, "versions.json")},
+		{Name: "japanese", Type: PROVIDER_TYPE_TITLEDB, Path: filepath.Join(folder, "JP.ja.json")},
+		{Name: "team", Type: PROVIDER_TYPE_CSV, Path: filepath.Join(folder, "team.csv"), Mode: PROVIDER_MODE_FILL},
+		{Name: "extra versions", Type: PROVIDER_TYPE_VERSIONS, Path: filepath.Join(folder, "extra.json"), Mode: PROVIDER_MODE_FILL},
+		{Name: "missing", Type: PROVIDER_TYPE_TITLES, Path: filepath.Join(folder, "missing.json")},
+	} {
+		provider, err := NewTitlesProvider(folder, &config)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		providers = append(providers, provider)
+	}
+
+	titlesDB, err := MergeTitlesProviders(providers, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	game := titlesDB.TitlesMap["0100000000010"]
+	if game == nil {
+		t.Fatalf("expected the game to be in the titles db")
+	}
+	if game.Attributes.Name != "ゲーム" || game.Attributes.Region != "JP" {
+		t.Errorf("expected the titledb provider to override the name and region, got %+v", game.Attributes)
+	}
+	if game.Attributes.Publisher != "Nintendo" {
+		t.Errorf("expected the fill provider not to replace the publisher, got %v", game.Attributes.Publisher)
+	}
+	if game.Updates[65536] != "2020-01-01" || game.Updates[131072] != "2021-01-01" {
+		t.Errorf("expected the versions to be merged without replacing existing ones, got %v", game.Updates)
+	}
+	if homebrew := titlesDB.TitlesMap["0100000000020"]; homebrew == nil || homebrew.Attributes.Name != "Homebrew" {
+		t.Errorf("expected the title of the csv provider, got %+v", homebrew)
+	}
+}
+
+func TestNewTitlesProviderInvalid(t *testing.T) {
+	for _, config := range []settings.TitlesProviderSettings{
+		{Name: "type", Type: "unknown", Path: "titles.json"},
+		{Name: "mode", Type: PROVIDER_TYPE_TITLES, Path: "titles.json", Mode: "unknown"},
+		{Name: "source", Type: PROVIDER_TYPE_TITLES},
+		{Type: PROVIDER_TYPE_TITLES, Path: "titles.json"},
+	} {
+		if _, err := NewTitlesProvider(t.TempDir(), &config); err == nil {
+			t.Errorf("expected an error for provider %+v", config)
+		}
+	}
+}
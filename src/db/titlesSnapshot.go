@@ -0,0 +1,277 @@
+package db
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/trembon/switch-library-manager/settings"
+	"go.uber.org/zap"
+)
+
+// SNAPSHOT_FORMAT_VERSION is increased when the layout of the snapshot archive changes
+const SNAPSHOT_FORMAT_VERSION = 1
+
+// TitlesSnapshotInfo describes where the local titles/versions files came from
+type TitlesSnapshotInfo struct {
+	FormatVersion  int       `json:"format_version"`
+	TitlesSource   string    `json:"titles_source"`
+	VersionsSource string    `json:"versions_source"`
+	Created        time.Time `json:"created"`                 // when the files were downloaded from the sources
+	ImportedFrom   string    `json:"imported_from,omitempty"` // archive the files were imported from
+}
+
+// Age returns the time since the files were downloaded from the sources
+func (i *TitlesSnapshotInfo) Age() time.Duration {
+	return time.Since(i.Created)
+}
+
+func (i *TitlesSnapshotInfo) String() string {
+	result := fmt.Sprintf("titles database downloaded from %v on %v (%v days old)", i.TitlesSource,
+		i.Created.Format("2006-01-02 15:04"), int(i.Age().Hours()/24))
+	if i.ImportedFrom != "" {
+		result += ", imported from " + i.ImportedFrom
+	}
+	return result
+}
+
+// ReadTitlesSnapshotInfo returns the information of the local titles/versions files, nil when unknown
+func ReadTitlesSnapshotInfo(baseFolder string) *TitlesSnapshotInfo {
+	data, err := os.ReadFile(filepath.Join(baseFolder, settings.TITLES_SNAPSHOT_FILENAME))
+	if err != nil {
+		return nil
+	}
+	info := &TitlesSnapshotInfo{}
+	if err = json.Unmarshal(data, info); err != nil {
+		zap.S().Warnf("Ignoring corrupted titles snapshot information - %v", err)
+		return nil
+	}
+	return info
+}
+
+func saveTitlesSnapshotInfo(baseFolder string, info *TitlesSnapshotInfo) error {
+	data, err := json.MarshalIndent(info, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(baseFolder, settings.TITLES_SNAPSHOT_FILENAME), data, 0644)
+}
+
+// LoadSwitchTitlesDB loads the titles database from its providers, downloading newer versions first unless offline,
+// the titles database is read from the cache of the local database (when not nil) while no provider changed
+func LoadSwitchTitlesDB(baseFolder string, offline bool, cache *LocalSwitchDBManager, progress ProgressUpdater) (*SwitchTitlesDB, error) {
+	settingsObj := settings.ReadSettings(baseFolder)
+	titlesEtag, versionsEtag := settingsObj.TitlesEtag, settingsObj.VersionsEtag
+
+	if err := os.MkdirAll(filepath.Join(baseFolder, PROVIDERS_FOLDER), os.ModePerm); err != nil {
+		return nil, err
+	}
+	providers, key, err := updateTitlesProviders(titlesProviders(baseFolder, settingsObj), offline, progress)
+	if err != nil {
+		return nil, err
+	}
+	var switchTitleDB *SwitchTitlesDB
+	if cache != nil {
+		switchTitleDB = cache.getCachedTitlesDB(key)
+	}
+	if switchTitleDB == nil {
+		switchTitleDB = loadTitlesProviders(providers, progress)
+		if cache != nil {
+			cache.saveTitlesDB(key, switchTitleDB)
+		}
+	} else if progress != nil {
+		progress.UpdateProgress(len(providers)+1, len(providers)+1, "Using the cached switch titles and updates")
+	}
+
+	if !offline {
+		if titlesEtag != settingsObj.TitlesEtag || versionsEtag != settingsObj.VersionsEtag {
+			err = saveTitlesSnapshotInfo(baseFolder, &TitlesSnapshotInfo{FormatVersion: SNAPSHOT_FORMAT_VERSION,
+				TitlesSource: settingsObj.TitlesJsonUrl, VersionsSource: settingsObj.VersionsJsonUrl, Created: time.Now()})
+			if err != nil {
+				zap.S().Warnf("Failed to save titles snapshot information - %v", err)
+			}
+		}
+		// keep the new etags
+		settings.SaveSettings(settingsObj, baseFolder)
+	}
+	return switchTitleDB, nil
+}
+
+// ExportTitlesSnapshot writes the local titles/versions files and the cached files of the titles providers,
+// with their information, to a zip archive
+func ExportTitlesSnapshot(baseFolder string, archivePath string) error {
+	info := ReadTitlesSnapshotInfo(baseFolder)
+	if info == nil {
+		// files downloaded before the snapshot information was kept
+		info = &TitlesSnapshotInfo{}
+		if fileInfo, err := os.Stat(filepath.Join(baseFolder, settings.TITLE_JSON_FILENAME)); err == nil {
+			info.Created = fileInfo.ModTime()
+		}
+	}
+	info.FormatVersion = SNAPSHOT_FORMAT_VERSION
+
+	output, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(output)
+
+	for _, fileName := range []string{settings.TITLE_JSON_FILENAME, settings.VERSIONS_JSON_FILENAME} {
+		err = addFileToArchive(archive, filepath.Join(baseFolder, fileName), fileName)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		// the cached files of the url titles providers
+		providerFiles, _ := os.ReadDir(filepath.Join(baseFolder, PROVIDERS_FOLDER))
+		for _, providerFile := range providerFiles {
+			if providerFile.IsDir() {
+				continue
+			}
+			err = addFileToArchive(archive, filepath.Join(baseFolder, PROVIDERS_FOLDER, providerFile.Name()), PROVIDERS_FOLDER+"/"+providerFile.Name())
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		var writer io.Writer
+		writer, err = archive.Create(settings.TITLES_SNAPSHOT_FILENAME)
+		if err == nil {
+			err = json.NewEncoder(writer).Encode(info)
+		}
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(archivePath)
+		return err
+	}
+	return nil
+}
+
+func addFileToArchive(archive *zip.Writer, filePath string, name string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// ImportTitlesSnapshot replaces the local titles/versions files with the ones of a snapshot archive
+func ImportTitlesSnapshot(baseFolder string, archivePath string) (*TitlesSnapshotInfo, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	infoFile, ok := files[settings.TITLES_SNAPSHOT_FILENAME]
+	if !ok {
+		return nil, errors.New("not a titles database snapshot, " + settings.TITLES_SNAPSHOT_FILENAME + " is missing")
+	}
+	info := &TitlesSnapshotInfo{}
+	if err = readArchiveJson(infoFile, info); err != nil {
+		return nil, err
+	}
+	if info.FormatVersion > SNAPSHOT_FORMAT_VERSION {
+		return nil, fmt.Errorf("unsupported snapshot version %v, update the application to import it", info.FormatVersion)
+	}
+
+	// validate all the files before replacing any of the local ones
+	for _, fileName := range []string{settings.TITLE_JSON_FILENAME, settings.VERSIONS_JSON_FILENAME} {
+		file, ok := files[fileName]
+		if !ok {
+			return nil, errors.New("invalid titles database snapshot, " + fileName + " is missing")
+		}
+		if err = validateArchiveJson(file); err != nil {
+			return nil, errors.New("invalid titles database snapshot, " + fileName + " is malformed")
+		}
+	}
+	for _, fileName := range []string{settings.TITLE_JSON_FILENAME, settings.VERSIONS_JSON_FILENAME} {
+		if err = extractArchiveFile(files[fileName], filepath.Join(baseFolder, fileName)); err != nil {
+			return nil, err
+		}
+	}
+	for name, file := range files {
+		if path.Dir(name) != PROVIDERS_FOLDER {
+			continue
+		}
+		if err = os.MkdirAll(filepath.Join(baseFolder, PROVIDERS_FOLDER), os.ModePerm); err != nil {
+			return nil, err
+		}
+		if err = extractArchiveFile(file, filepath.Join(baseFolder, PROVIDERS_FOLDER, path.Base(name))); err != nil {
+			return nil, err
+		}
+	}
+
+	info.ImportedFrom = archivePath
+	if err = saveTitlesSnapshotInfo(baseFolder, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func validateArchiveJson(file *zip.File) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return validateJsonObject(reader)
+}
+
+func readArchiveJson(file *zip.File, target interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return decodeToJsonObject(reader, target)
+}
+
+// extractArchiveFile writes the file next to the destination first, so a failed import keeps the existing file
+func extractArchiveFile(file *zip.File, destination string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tmpPath := destination + ".tmp"
+	output, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(output, reader)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, destination)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+func TestTitlesSnapshotExportImport(t *testing.T) {
+	source := t.TempDir()
+	titles := `{"0100000000010000":{"id":"0100000000010000","name":"Game"}}`
+	versions := `{"0100000000010000":{"65536":"2020-01-01"}}`
+	_ = os.WriteFile(filepath.Join(source, settings.TITLE_JSON_FILENAME), []byte(titles), 0644)
+	_ = os.WriteFile(filepath.Join(source, settings.VERSIONS_JSON_FILENAME), []byte(versions), 0644)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	err := saveTitlesSnapshotInfo(source, &TitlesSnapshotInfo{FormatVersion: SNAPSHOT_FORMAT_VERSION, TitlesSource: "http://mirror/titles.json", Created: created})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "snapshot.zip")
+	if err = ExportTitlesSnapshot(source, archivePath); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+
+	destination := t.TempDir()
+	if _, err = LoadSwitchTitlesDB(destination, true, nil, nil); err == nil {
+		t.Fatalf("expected an error when loading offline without a snapshot")
+	}
+
+	info, err := ImportTitlesSnapshot(destination, archivePath)
+	if err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if info.TitlesSource != "http://mirror/titles.json" || !info.Created.Equal(created) || info.ImportedFrom != archivePath {
+		t.Fatalf("unexpected snapshot information %+v", info)
+	}
+	if saved := ReadTitlesSnapshotInfo(destination); saved == nil || saved.ImportedFrom != archivePath {
+		t.Fatalf("expected the snapshot information to be saved, got %+v", saved)
+	}
+
+	titlesDB, err := LoadSwitchTitlesDB(destination, true, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to load the imported snapshot offline: %v", err)
+	}
+	if title, ok := titlesDB.TitlesMap["0100000000010"]; !ok || title.Attributes.Name != "Game" {
+		t.Fatalf("expected the imported title, got %+v", titlesDB.TitlesMap)
+	}
+}
+
+func TestTitlesSnapshotImportInvalid(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "snapshot.zip")
+	if err := ExportTitlesSnapshot(t.TempDir(), archivePath); err == nil {
+		t.Fatalf("expected an error when exporting without titles files")
+	}
+	if _, err := os.Stat(archivePath); err == nil {
+		t.Fatalf("expected the failed export to be removed")
+	}
+	_ = os.WriteFile(archivePath, []byte("not a zip"), 0644)
+	if _, err := ImportTitlesSnapshot(t.TempDir(), archivePath); err == nil {
+		t.Fatalf("expected an error when importing an invalid archive")
+	}
+}
@@ -1,13 +1,13 @@
 package db
 
 import (
-	bytes2 "bytes"
 	"encoding/json"
 	"errors"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"go.uber.org/zap"
@@ -18,51 +18,66 @@ type ProgressUpdater interface {
 }
 
 func LoadAndUpdateFile(url string, filePath string, etag string) (*os.File, string, error) {
+	return loadAndUpdateFile(url, filePath, etag, validateJsonObject)
+}
+
+// loadAndUpdateFile downloads a newer version of the file when there is one, the new version is streamed to
+// a temporary file next to the local one and only replaces it when it passes the validation
+func loadAndUpdateFile(url string, filePath string, etag string, validate func(reader io.Reader) error) (*os.File, string, error) {
 
 	//create file if not exist
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		_, err = os.Create(filePath)
+		file, err := os.Create(filePath)
 		if err != nil {
 			zap.S().Errorf("Failed to create file %v - %v\n", filePath, err)
 			return nil, "", err
 		}
+		file.Close()
 	}
 
-	var file *os.File = nil
-
 	//try to check if there is a new version
 	//if so, save the file
-	bytes, newEtag, err := downloadBytesFromUrl(url, etag)
+	tempPath, newEtag, err := downloadFileFromUrl(url, etag, filePath)
 	if err == nil {
-		//validate json structure
-		var test map[string]interface{}
-		err = decodeToJsonObject(bytes2.NewReader(bytes), &test)
+		//validate the file structure
+		err = validateFile(tempPath, validate)
+		if err == nil {
+			err = os.Rename(tempPath, filePath)
+		}
 		if err == nil {
-			file, err = saveFile(bytes, filePath)
 			etag = newEtag
 		} else {
-			zap.S().Infof("ignoring new update [%v], reason - [malformed json file]", url)
+			_ = os.Remove(tempPath)
+			zap.S().Infof("ignoring new update [%v], reason - [malformed file - %v]", url, err)
 		}
 	} else {
 		zap.S().Infof("file [%v] was not downloaded, reason - [%v]", url, err)
 	}
 
-	if file == nil {
-		//load file
-		file, err = os.Open(filePath)
-		if err != nil {
-			zap.S().Infof("ignoring new update [%v], reason - [malformed json file]", url)
-			return nil, "", err
-		}
+	//load file
+	file, err := os.Open(filePath)
+	if err != nil {
+		zap.S().Infof("ignoring new update [%v], reason - [malformed json file]", url)
+		return nil, "", err
+	}
 
-		fileInfo, err := os.Stat(filePath)
-		if err != nil || fileInfo.Size() == 0 {
-			zap.S().Infof("Local file is empty, or corrupted")
-			return nil, "", errors.New("unable to download switch titles db")
-		}
+	fileInfo, err := os.Stat(filePath)
+	if err != nil || fileInfo.Size() == 0 {
+		file.Close()
+		zap.S().Infof("Local file is empty, or corrupted")
+		return nil, "", errors.New("unable to download switch titles db")
 	}
 
-	return file, etag, err
+	return file, etag, nil
+}
+
+func validateFile(filePath string, validate func(reader io.Reader) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return validate(file)
 }
 
 func decodeToJsonObject(reader io.Reader, target interface{}) error {
@@ -70,10 +85,45 @@ func decodeToJsonObject(reader io.Reader, target interface{}) error {
 	return err
 }
 
-func downloadBytesFromUrl(url string, etag string) ([]byte, string, error) {
+// decodeJsonObjectEntries reads a json object one entry at a time, decodeValue is called with the key
+// of each entry and has to decode its value, so the whole object is never held in memory
+func decodeJsonObjectEntries(reader io.Reader, decodeValue func(key string, decoder *json.Decoder) error) error {
+	decoder := json.NewDecoder(reader)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errors.New("expected a json object")
+	}
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err = decodeValue(token.(string), decoder); err != nil {
+			return err
+		}
+	}
+	// closing brace of the object
+	_, err = decoder.Token()
+	return err
+}
+
+// validateJsonObject checks that the reader holds a well formed json object, without decoding it
+func validateJsonObject(reader io.Reader) error {
+	return decodeJsonObjectEntries(reader, func(key string, decoder *json.Decoder) error {
+		var value json.RawMessage
+		return decoder.Decode(&value)
+	})
+}
+
+// downloadFileFromUrl streams a newer version of the file to a temporary file next to filePath,
+// and returns the path of the temporary file
+func downloadFileFromUrl(url string, etag string, filePath string) (string, string, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, "", err
+		return "", "", err
 	}
 	req.Header.Set("If-None-Match", etag)
 	transport := &http.Transport{
@@ -86,37 +136,31 @@ func downloadBytesFromUrl(url string, etag string) ([]byte, string, error) {
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, "", err
+		return "", "", err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, "", errors.New("got a non 200 response - " + resp.Status)
+		return "", "", errors.New("got a non 200 response - " + resp.Status)
 	}
-	defer resp.Body.Close()
 	//getting the new etag
 	etag = resp.Header.Get("Etag")
 
-	if resp.StatusCode == http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, "", err
-		}
-		return body, etag, nil
+	if resp.StatusCode != http.StatusOK {
+		return "", "", errors.New("no new updates")
 	}
 
-	return nil, "", errors.New("no new updates")
-}
-
-func saveFile(bytes []byte, fileName string) (*os.File, error) {
-
-	err := os.WriteFile(fileName, bytes, 0644)
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
 	if err != nil {
-		return nil, err
+		return "", "", err
+	}
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
-
-	file, err := os.Open(fileName)
 	if err != nil {
-		return nil, err
+		_ = os.Remove(file.Name())
+		return "", "", err
 	}
-	return file, nil
+	return file.Name(), etag, nil
 }
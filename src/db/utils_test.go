@@ -0,0 +1,64 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAndUpdateFile(t *testing.T) {
+	content := `{"0100000000010000":{"name":"Game"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/valid.json":
+			if r.Header.Get("If-None-Match") == "v1" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Etag", "v1")
+			_, _ = w.Write([]byte(content))
+		case "/malformed.json":
+			w.Header().Set("Etag", "v2")
+			_, _ = w.Write([]byte(`{"0100000000010000":`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	folder := t.TempDir()
+	filePath := filepath.Join(folder, "titles.json")
+	file, etag, err := LoadAndUpdateFile(server.URL+"/valid.json", filePath, "")
+	if err != nil {
+		t.Fatalf("failed to load file: %v", err)
+	}
+	file.Close()
+	if data, _ := os.ReadFile(filePath); string(data) != content || etag != "v1" {
+		t.Fatalf("expected the downloaded file with etag v1, got %v (%v)", string(data), etag)
+	}
+
+	for _, test := range []struct {
+		path string
+		etag string
+	}{
+		{"/valid.json", "v1"},
+		{"/malformed.json", "v1"},
+		{"/missing.json", "v1"},
+	} {
+		file, etag, err = LoadAndUpdateFile(server.URL+test.path, filePath, "v1")
+		if err != nil {
+			t.Fatalf("%v: failed to load file: %v", test.path, err)
+		}
+		file.Close()
+		if data, _ := os.ReadFile(filePath); string(data) != content || etag != test.etag {
+			t.Errorf("%v: expected the local file to be kept, got %v (%v)", test.path, string(data), etag)
+		}
+	}
+
+	// the temporary files are removed
+	if entries, _ := os.ReadDir(folder); len(entries) != 1 {
+		t.Errorf("expected only the local file to be left, got %v", entries)
+	}
+}
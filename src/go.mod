@@ -7,7 +7,9 @@ require (
 	github.com/avast/retry-go/v5 v5.0.0
 	github.com/firebat20/go-astilectron v0.0.0-20260424023421-2261bc12f84b
 	github.com/firebat20/go-astilectron-bootstrap v0.0.0-20260424031731-a5b66249402f
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/jedib0t/go-pretty/v6 v6.7.10
+	github.com/klauspost/compress v1.18.0
 	github.com/magiconair/properties v1.8.10
 	github.com/mcuadros/go-version v0.0.0-20190830083331-035f6764e8d2
 	github.com/schollz/progressbar/v3 v3.19.0
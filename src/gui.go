@@ -2,9 +2,9 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -27,9 +27,11 @@ type Pair struct {
 }
 
 type LocalLibraryData struct {
-	LibraryData []LibraryTemplateData `json:"library_data"`
-	Issues      []Pair                `json:"issues"`
-	NumFiles    int                   `json:"num_files"`
+	LibraryData  []LibraryTemplateData `json:"library_data"`
+	Issues       []Pair                `json:"issues"`
+	NumFiles     int                   `json:"num_files"`
+	UntrimmedXci int                   `json:"untrimmed_xci"`
+	TrimSavings  int64                 `json:"trim_savings"`
 }
 
 type SwitchTitle struct {
@@ -41,16 +43,51 @@ type SwitchTitle struct {
 }
 
 type LibraryTemplateData struct {
-	Id      int    `json:"id"`
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Dlc     string `json:"dlc"`
-	TitleId string `json:"titleId"`
-	Path    string `json:"path"`
-	Icon    string `json:"icon"`
-	Update  int    `json:"update"`
-	Region  string `json:"region"`
-	Type    string `json:"type"`
+	Id         int    `json:"id"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Dlc        string `json:"dlc"`
+	TitleId    string `json:"titleId"`
+	Path       string `json:"path"`
+	Icon       string `json:"icon"`
+	Update     int    `json:"update"`
+	Region     string `json:"region"`
+	Type       string `json:"type"`
+	Compressed bool   `json:"compressed"`
+	CanMerge   bool   `json:"canMerge"`
+	Trimmed    *bool  `json:"trimmed,omitempty"`
+	// RequiredFirmware is the firmware needed by the base, latest update and DLC
+	RequiredFirmware   string `json:"requiredFirmware"`
+	KeyGeneration      int    `json:"keyGeneration"`
+	NeedsNewerFirmware bool   `json:"needsNewerFirmware"`
+	SaveDataSize       int64  `json:"saveDataSize"`
+}
+
+type SplitRequest struct {
+	TitleId      string `json:"titleId"`
+	DeleteSource bool   `json:"deleteSource"`
+}
+
+type MergeRequest struct {
+	TitleId    string `json:"titleId"`
+	IncludeDlc bool   `json:"includeDlc"`
+}
+
+type PlanResponse struct {
+	Summary    string          `json:"summary"`
+	Actions    []string        `json:"actions"`
+	Collisions int             `json:"collisions"` // skipped
+	Pending    []PlanCollision `json:"pending"`    // left to the user by the ask collision policy
+}
+
+type PlanCollision struct {
+	Index  int    `json:"index"`
+	Action string `json:"action"`
+}
+
+type CollisionResolution struct {
+	Index      int    `json:"index"`
+	Resolution string `json:"resolution"`
 }
 
 type ProgressUpdate struct {
@@ -61,9 +98,12 @@ type ProgressUpdate struct {
 
 type State struct {
 	sync.Mutex
-	switchDB *db.SwitchTitlesDB
-	localDB  *db.LocalSwitchFilesDB
-	window   *astilectron.Window
+	switchDB       *db.SwitchTitlesDB
+	localDB        *db.LocalSwitchFilesDB
+	window         *astilectron.Window
+	missingUpdates map[string]process.IncompleteTitle
+	missingDLC     map[string]process.IncompleteTitle
+	plan           *process.Plan // last organize plan, executed as previewed
 }
 
 type Message struct {
@@ -76,6 +116,7 @@ type GUI struct {
 	baseFolder     string
 	localDbManager *db.LocalSwitchDBManager
 	sugarLogger    *zap.SugaredLogger
+	watcher        *db.FolderWatcher
 }
 
 func CreateGUI(baseFolder string, sugarLogger *zap.SugaredLogger) *GUI {
@@ -129,6 +170,10 @@ func (g *GUI) Start() {
 		g.sugarLogger.Error(fmt.Errorf("running bootstrap failed: %w", err))
 		log.Fatal(err)
 	}
+
+	g.state.Lock()
+	g.stopWatcher()
+	g.state.Unlock()
 }
 
 func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
@@ -146,11 +191,68 @@ func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
 	g.sugarLogger.Debugf("Received message from client [%v]", msg)
 
 	switch msg.Name {
-	case "organize":
-		g.organizeLibrary()
+	case "organizePlan":
+		plan, err := g.planOrganizeLibrary()
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		g.state.plan = plan
+		msg, _ := json.Marshal(newPlanResponse(plan))
+		retValue = string(msg)
+	case "resolveCollisions":
+		if g.state.plan == nil {
+			return ""
+		}
+		var resolutions []CollisionResolution
+		err := json.Unmarshal([]byte(msg.Payload), &resolutions)
+		for _, resolution := range resolutions {
+			if err != nil {
+				break
+			}
+			err = g.state.plan.Resolve(resolution.Index, resolution.Resolution)
+		}
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		msg, _ := json.Marshal(newPlanResponse(g.state.plan))
+		retValue = string(msg)
+	case "exportPlan":
+		if g.state.plan == nil {
+			return ""
+		}
+		err := g.state.plan.ExportPlan(msg.Payload)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		retValue = msg.Payload
+	case "executePlan":
+		if g.state.plan == nil {
+			return ""
+		}
+		summary := g.state.plan.Summary()
+		err := g.state.plan.Execute(g.baseFolder, g)
+		g.state.plan = nil
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		retValue = summary
 	case "isKeysFileAvailable":
 		keys, _ := settings.SwitchKeys()
 		retValue = strconv.FormatBool(keys != nil && keys.GetKey("header_key") != "")
+	case "keysReport":
+		keys, _ := settings.SwitchKeys()
+		if keys != nil {
+			report, _ := json.Marshal(keys.Report())
+			retValue = string(report)
+		}
 	case "loadSettings":
 		retValue = g.loadSettings()
 
@@ -162,6 +264,9 @@ func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
 			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
 			return ""
 		}
+		g.state.missingUpdates = nil
+		g.state.missingDLC = nil
+		g.updateWatcher()
 	case "missingGames":
 		missingGames := g.getMissingGames()
 		msg, _ := json.Marshal(missingGames)
@@ -177,71 +282,8 @@ func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
 			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
 			return ""
 		}
-		response := LocalLibraryData{}
-		libraryData := []LibraryTemplateData{}
-		issues := []Pair{}
-		for k, v := range localDB.TitlesMap {
-			if v.BaseExist {
-				version := ""
-				name := ""
-				if v.File.Metadata.Ncap != nil {
-					version = v.File.Metadata.Ncap.DisplayVersion
-					name = v.File.Metadata.Ncap.TitleName["AmericanEnglish"].Title
-				}
-
-				if v.Updates != nil && len(v.Updates) != 0 {
-					if v.Updates[v.LatestUpdate].Metadata.Ncap != nil {
-						version = v.Updates[v.LatestUpdate].Metadata.Ncap.DisplayVersion
-					} else {
-						version = ""
-					}
-				}
-				if title, ok := g.state.switchDB.TitlesMap[k]; ok {
-					if title.Attributes.Name != "" {
-						name = title.Attributes.Name
-					}
-					libraryData = append(libraryData,
-						LibraryTemplateData{
-							Icon:    title.Attributes.IconUrl,
-							Name:    name,
-							TitleId: title.Attributes.Id,
-							Update:  v.LatestUpdate,
-							Version: version,
-							Region:  title.Attributes.Region,
-							Type:    getType(v),
-							Path:    filepath.Join(v.File.ExtendedInfo.BaseFolder, v.File.ExtendedInfo.FileName),
-						})
-				} else {
-					if name == "" {
-						name = db.ParseTitleNameFromFileName(v.File.ExtendedInfo.FileName)
-					}
-					libraryData = append(libraryData,
-						LibraryTemplateData{
-							Name:    name,
-							Update:  v.LatestUpdate,
-							Version: version,
-							Type:    getType(v),
-							TitleId: v.File.Metadata.TitleId,
-							Path:    v.File.ExtendedInfo.FileName,
-						})
-				}
-
-			} else {
-				for _, update := range v.Updates {
-					issues = append(issues, Pair{Key: filepath.Join(update.ExtendedInfo.BaseFolder, update.ExtendedInfo.FileName), Value: "Base file is missing", Type: db.REASON_MISSING_BASE})
-				}
-				for _, dlc := range v.Dlc {
-					issues = append(issues, Pair{Key: filepath.Join(dlc.ExtendedInfo.BaseFolder, dlc.ExtendedInfo.FileName), Value: "Base file is missing", Type: db.REASON_MISSING_BASE})
-				}
-			}
-		}
-		for k, v := range localDB.Skipped {
-			issues = append(issues, Pair{Key: filepath.Join(k.BaseFolder, k.FileName), Value: v.ReasonText, Type: v.ReasonCode})
-		}
-
-		response.LibraryData = libraryData
-		response.NumFiles = localDB.NumFiles
-		response.Issues = issues
+		g.updateWatcher()
+		response := g.buildLibraryResponse(localDB)
 		msg, _ := json.Marshal(response)
 		g.state.window.SendMessage(Message{Name: "libraryLoaded", Payload: string(msg)}, func(m *astilectron.EventMessage) {})
 	case "updateDB":
@@ -254,6 +296,93 @@ func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
 			}
 			g.state.switchDB = switchDb
 		}
+	case "verify":
+		_, err := process.VerifyLibrary(g.localDbManager, g.state.localDB, g)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		msg, _ := json.Marshal(g.buildLibraryResponse(g.state.localDB))
+		g.state.window.SendMessage(Message{Name: "libraryLoaded", Payload: string(msg)}, func(m *astilectron.EventMessage) {})
+	case "decompress":
+		_, err := process.DecompressTitle(g.state.localDB, msg.Payload, g)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+		}
+	case "trim":
+		_, _, err := process.TrimTitle(g.state.localDB, msg.Payload, g)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+		}
+	case "untrim":
+		_, err := process.UntrimTitle(g.state.localDB, msg.Payload, g)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+		}
+	case "merge":
+		request := MergeRequest{}
+		err := json.Unmarshal([]byte(msg.Payload), &request)
+		if err == nil {
+			dlcIds := []string{}
+			if request.IncludeDlc {
+				dlcIds = nil
+			}
+			_, err = process.MergeTitle(g.state.localDB, request.TitleId, dlcIds, g)
+		}
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+		}
+	case "split":
+		request := SplitRequest{}
+		err := json.Unmarshal([]byte(msg.Payload), &request)
+		if err == nil {
+			_, err = process.SplitTitle(g.baseFolder, g.state.localDB, g.state.switchDB, request.TitleId, request.DeleteSource, g)
+		}
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+		}
+	case "lastRun":
+		info, err := process.LastJournal(g.baseFolder)
+		if err != nil {
+			g.sugarLogger.Error(err)
+		}
+		if info != nil {
+			msg, _ := json.Marshal(info)
+			retValue = string(msg)
+		}
+	case "undoLastRun":
+		info, err := process.UndoLastRun(g.baseFolder, g)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		msg, _ := json.Marshal(info)
+		retValue = string(msg)
+	case "trashInfo":
+		info, err := process.ReadTrashInfo(g.baseFolder)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			return ""
+		}
+		msg, _ := json.Marshal(info)
+		retValue = string(msg)
+	case "purgeTrash":
+		_, _, err := process.PurgeTrash(g.baseFolder, msg.Payload)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		info, _ := process.ReadTrashInfo(g.baseFolder)
+		msg, _ := json.Marshal(info)
+		retValue = string(msg)
 	case "hardRescan":
 		_ = g.localDbManager.ClearScanData()
 		g.state.window.SendMessage(Message{Name: "rescan", Payload: ""}, func(m *astilectron.EventMessage) {})
@@ -261,12 +390,42 @@ func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
 		retValue = g.getMissingUpdates()
 	case "missingDlc":
 		retValue = g.getMissingDLC()
-	case "checkUpdate":
-		newUpdate, err := settings.CheckForUpdates()
+	case "titlesSnapshot":
+		if info := db.ReadTitlesSnapshotInfo(g.baseFolder); info != nil {
+			msg, _ := json.Marshal(info)
+			retValue = string(msg)
+		}
+	case "exportSnapshot":
+		err := db.ExportTitlesSnapshot(g.baseFolder, msg.Payload)
 		if err != nil {
 			g.sugarLogger.Error(err)
-			if !strings.Contains(err.Error(), "dial tcp") {
-				g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		retValue = msg.Payload
+	case "importSnapshot":
+		info, err := db.ImportTitlesSnapshot(g.baseFolder, msg.Payload)
+		if err != nil {
+			g.sugarLogger.Error(err)
+			g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+			return ""
+		}
+		g.state.switchDB = nil
+		g.state.missingUpdates = nil
+		g.state.missingDLC = nil
+		msg, _ := json.Marshal(info)
+		retValue = string(msg)
+	case "checkUpdate":
+		// no update check in offline mode
+		newUpdate := false
+		if !settings.ReadSettings(g.baseFolder).OfflineMode {
+			var err error
+			newUpdate, err = settings.CheckForUpdates()
+			if err != nil {
+				g.sugarLogger.Error(err)
+				if !strings.Contains(err.Error(), "dial tcp") {
+					g.state.window.SendMessage(Message{Name: "error", Payload: err.Error()}, func(m *astilectron.EventMessage) {})
+				}
 			}
 		}
 		retValue = strconv.FormatBool(newUpdate)
@@ -277,6 +436,102 @@ func (g *GUI) handleMessage(m *astilectron.EventMessage) interface{} {
 	return retValue
 }
 
+func (g *GUI) buildLibraryResponse(localDB *db.LocalSwitchFilesDB) LocalLibraryData {
+	response := LocalLibraryData{}
+	libraryData := []LibraryTemplateData{}
+	issues := []Pair{}
+	settingsObj := settings.ReadSettings(g.baseFolder)
+	for k, v := range localDB.TitlesMap {
+		if v.BaseExist {
+			firmware := process.GetTitleFirmware(v)
+			needsNewerFirmware := process.NeedsNewerFirmware(firmware, settingsObj.TargetFirmware)
+			version := ""
+			if v.File.Metadata.Ncap != nil {
+				version = v.File.Metadata.Ncap.DisplayVersion
+			}
+			name := process.GetTitleName(g.state.switchDB.TitlesMap[k], v, settingsObj.PreferredLanguages)
+
+			if v.Updates != nil && len(v.Updates) != 0 {
+				if v.Updates[v.LatestUpdate].Metadata.Ncap != nil {
+					version = v.Updates[v.LatestUpdate].Metadata.Ncap.DisplayVersion
+				} else {
+					version = ""
+				}
+			}
+			if title, ok := g.state.switchDB.TitlesMap[k]; ok {
+				libraryData = append(libraryData,
+					LibraryTemplateData{
+						Icon:       g.getIcon(title.Attributes.IconUrl, v.File.Metadata.TitleId),
+						Name:       name,
+						TitleId:    title.Attributes.Id,
+						Update:     v.LatestUpdate,
+						Version:    version,
+						Region:     title.Attributes.Region,
+						Type:       getType(v),
+						Path:       filepath.Join(v.File.ExtendedInfo.BaseFolder, v.File.ExtendedInfo.FileName),
+						Compressed: hasCompressedFiles(v),
+						CanMerge:   canMerge(v),
+						Trimmed:    isTrimmed(v),
+
+						RequiredFirmware:   firmware.RequiredFirmware(),
+						KeyGeneration:      firmware.KeyGeneration,
+						NeedsNewerFirmware: needsNewerFirmware,
+						SaveDataSize:       process.GetTitleSaveDataSize(v),
+					})
+			} else {
+				libraryData = append(libraryData,
+					LibraryTemplateData{
+						Icon:       g.getIcon("", v.File.Metadata.TitleId),
+						Name:       name,
+						Update:     v.LatestUpdate,
+						Version:    version,
+						Type:       getType(v),
+						TitleId:    v.File.Metadata.TitleId,
+						Path:       v.File.ExtendedInfo.FileName,
+						Compressed: hasCompressedFiles(v),
+						CanMerge:   canMerge(v),
+						Trimmed:    isTrimmed(v),
+
+						RequiredFirmware:   firmware.RequiredFirmware(),
+						KeyGeneration:      firmware.KeyGeneration,
+						NeedsNewerFirmware: needsNewerFirmware,
+						SaveDataSize:       process.GetTitleSaveDataSize(v),
+					})
+			}
+
+		} else {
+			for _, update := range v.Updates {
+				issues = append(issues, Pair{Key: filepath.Join(update.ExtendedInfo.BaseFolder, update.ExtendedInfo.FileName), Value: "Base file is missing", Type: db.REASON_MISSING_BASE})
+			}
+			for _, dlc := range v.Dlc {
+				issues = append(issues, Pair{Key: filepath.Join(dlc.ExtendedInfo.BaseFolder, dlc.ExtendedInfo.FileName), Value: "Base file is missing", Type: db.REASON_MISSING_BASE})
+			}
+		}
+	}
+	for k, v := range localDB.Skipped {
+		issues = append(issues, Pair{Key: filepath.Join(k.BaseFolder, k.FileName), Value: v.ReasonText, Type: v.ReasonCode})
+	}
+
+	response.LibraryData = libraryData
+	response.NumFiles = localDB.NumFiles
+	_, response.UntrimmedXci, response.TrimSavings = process.XciTrimSummary(localDB)
+	response.Issues = issues
+	return response
+}
+
+// getIcon returns the icon of a title, an extracted icon is returned as a file url
+func (g *GUI) getIcon(iconUrl string, titleId string) string {
+	icon, local := db.GetIcon(g.baseFolder, iconUrl, titleId, settings.ReadSettings(g.baseFolder).OfflineMode)
+	if !local {
+		return icon
+	}
+	icon = filepath.ToSlash(icon)
+	if !strings.HasPrefix(icon, "/") {
+		icon = "/" + icon
+	}
+	return (&url.URL{Scheme: "file", Path: icon}).String()
+}
+
 func getType(gameFile *db.SwitchGameFiles) string {
 	if gameFile.IsSplit {
 		return "split"
@@ -291,6 +546,51 @@ func getType(gameFile *db.SwitchGameFiles) string {
 	return ""
 }
 
+func hasCompressedFiles(gameFile *db.SwitchGameFiles) bool {
+	if db.IsCompressed(gameFile.File.ExtendedInfo.FileName) {
+		return true
+	}
+	for _, update := range gameFile.Updates {
+		if db.IsCompressed(update.ExtendedInfo.FileName) {
+			return true
+		}
+	}
+	for _, dlc := range gameFile.Dlc {
+		if db.IsCompressed(dlc.ExtendedInfo.FileName) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrimmed returns the trim status of the base file, nil when it is not a XCI
+func isTrimmed(gameFile *db.SwitchGameFiles) *bool {
+	if gameFile.File.Metadata == nil || gameFile.File.Metadata.Xci == nil ||
+		!strings.HasSuffix(strings.ToLower(gameFile.File.ExtendedInfo.FileName), ".xci") {
+		return nil
+	}
+	trimmed := gameFile.File.Metadata.Xci.IsTrimmed()
+	return &trimmed
+}
+
+// canMerge checks if a title has update or DLC files which are not already part of the base file
+func canMerge(gameFile *db.SwitchGameFiles) bool {
+	if gameFile.IsSplit {
+		return false
+	}
+	for _, update := range gameFile.Updates {
+		if update.ExtendedInfo != gameFile.File.ExtendedInfo {
+			return true
+		}
+	}
+	for _, dlc := range gameFile.Dlc {
+		if dlc.ExtendedInfo != gameFile.File.ExtendedInfo {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *GUI) saveSettings(settingsJson string) error {
 	s := settings.AppSettings{}
 	err := json.Unmarshal([]byte(settingsJson), &s)
@@ -302,12 +602,12 @@ func (g *GUI) saveSettings(settingsJson string) error {
 }
 
 func (g *GUI) getMissingDLC() string {
-	settingsObj := settings.ReadSettings(g.baseFolder)
-	ignoreIds := map[string]struct{}{}
-	for _, id := range settingsObj.IgnoreDLCTitleIds {
-		ignoreIds[strings.ToLower(id)] = struct{}{}
+	if g.state.missingDLC == nil {
+		settingsObj := settings.ReadSettings(g.baseFolder)
+		g.state.missingDLC = process.ScanForMissingDLC(g.state.localDB.TitlesMap, g.state.switchDB.TitlesMap, toIgnoreMap(settingsObj.IgnoreDLCTitleIds),
+			settingsObj.PreferredLanguages)
 	}
-	missingDLC := process.ScanForMissingDLC(g.state.localDB.TitlesMap, g.state.switchDB.TitlesMap, ignoreIds)
+	missingDLC := g.state.missingDLC
 	values := make([]process.IncompleteTitle, len(missingDLC))
 	i := 0
 	for _, missingUpdate := range missingDLC {
@@ -320,12 +620,12 @@ func (g *GUI) getMissingDLC() string {
 }
 
 func (g *GUI) getMissingUpdates() string {
-	settingsObj := settings.ReadSettings(g.baseFolder)
-	ignoreIds := map[string]struct{}{}
-	for _, id := range settingsObj.IgnoreUpdateTitleIds {
-		ignoreIds[strings.ToLower(id)] = struct{}{}
+	if g.state.missingUpdates == nil {
+		settingsObj := settings.ReadSettings(g.baseFolder)
+		g.state.missingUpdates = process.ScanForMissingUpdates(g.state.localDB.TitlesMap, g.state.switchDB.TitlesMap,
+			toIgnoreMap(settingsObj.IgnoreUpdateTitleIds), settingsObj.IgnoreDLCUpdates, settingsObj.PreferredLanguages)
 	}
-	missingUpdates := process.ScanForMissingUpdates(g.state.localDB.TitlesMap, g.state.switchDB.TitlesMap, ignoreIds, settingsObj.IgnoreDLCUpdates)
+	missingUpdates := g.state.missingUpdates
 	values := make([]process.IncompleteTitle, len(missingUpdates))
 	i := 0
 	for _, missingUpdate := range missingUpdates {
@@ -342,55 +642,105 @@ func (g *GUI) loadSettings() string {
 }
 
 func (g *GUI) buildSwitchDb() (*db.SwitchTitlesDB, error) {
-	settingsObj := settings.ReadSettings(g.baseFolder)
-	//1. load the titles JSON object
-	g.UpdateProgress(1, 4, "Downloading titles.json")
-	filename := filepath.Join(g.baseFolder, settings.TITLE_JSON_FILENAME)
-	titleFile, titlesEtag, err := db.LoadAndUpdateFile(settingsObj.TitlesJsonUrl, filename, settingsObj.TitlesEtag)
-	if err != nil {
-		return nil, errors.New("failed to download switch titles [reason:" + err.Error() + "]")
-	}
-	settingsObj.TitlesEtag = titlesEtag
-
-	g.UpdateProgress(2, 4, "Downloading versions.json")
-	filename = filepath.Join(g.baseFolder, settings.VERSIONS_JSON_FILENAME)
-	versionsFile, versionsEtag, err := db.LoadAndUpdateFile(settingsObj.VersionsJsonUrl, filename, settingsObj.VersionsEtag)
-	if err != nil {
-		return nil, errors.New("failed to download switch updates [reason:" + err.Error() + "]")
-	}
-	settingsObj.VersionsEtag = versionsEtag
-
-	settings.SaveSettings(settingsObj, g.baseFolder)
-
-	g.UpdateProgress(3, 4, "Processing switch titles and updates")
-	switchTitleDB, err := db.CreateSwitchTitleDB(titleFile, versionsFile)
+	switchTitleDB, err := db.LoadSwitchTitlesDB(g.baseFolder, settings.ReadSettings(g.baseFolder).OfflineMode, g.localDbManager, g)
 	g.UpdateProgress(4, 4, "Finishing up...")
 	return switchTitleDB, err
 }
 
 func (g *GUI) buildLocalDB(localDbManager *db.LocalSwitchDBManager, ignoreCache bool) (*db.LocalSwitchFilesDB, error) {
-	folderToScan := settings.ReadSettings(g.baseFolder).Folder
 	recursiveMode := settings.ReadSettings(g.baseFolder).ScanRecursively
 
-	scanFolders := settings.ReadSettings(g.baseFolder).ScanFolders
-	scanFolders = append(scanFolders, folderToScan)
-	localDB, err := localDbManager.CreateLocalSwitchFilesDB(scanFolders, g, recursiveMode, ignoreCache)
+	localDB, err := localDbManager.CreateLocalSwitchFilesDB(g.scanFolders(), g, recursiveMode, ignoreCache)
 	g.state.localDB = localDB
+	g.state.missingUpdates = nil
+	g.state.missingDLC = nil
 	return localDB, err
 }
 
-func (g *GUI) organizeLibrary() {
-	folderToScan := settings.ReadSettings(g.baseFolder).Folder
-	options := settings.ReadSettings(g.baseFolder).OrganizeOptions
-	if !process.IsOptionsValid(options) {
-		zap.S().Error("the organize options in settings.json are not valid, please check that the template contains file/folder name")
-		g.state.window.SendMessage(Message{Name: "error", Payload: "the organize options in settings.json are not valid, please check that the template contains file/folder name"}, func(m *astilectron.EventMessage) {})
+func (g *GUI) scanFolders() []string {
+	settingsObj := settings.ReadSettings(g.baseFolder)
+	scanFolders := append([]string{}, settingsObj.ScanFolders...)
+	return append(scanFolders, settingsObj.Folder)
+}
+
+// updateWatcher (re)starts the folder watcher when watching is enabled, so it follows
+// the current scan folders. Must be called with the state locked.
+func (g *GUI) updateWatcher() {
+	g.stopWatcher()
+
+	settingsObj := settings.ReadSettings(g.baseFolder)
+	if !settingsObj.WatchFolders || g.state.localDB == nil {
 		return
 	}
-	if settings.ReadSettings(g.baseFolder).OrganizeOptions.DeleteOldUpdateFiles {
-		process.DeleteOldUpdates(g.baseFolder, g.state.localDB, g)
+	watcher, err := db.WatchFolders(g.scanFolders(), settingsObj.ScanRecursively, g.onLibraryChanged)
+	if err != nil {
+		g.sugarLogger.Errorf("Failed to watch library folders - %v", err)
+		return
+	}
+	g.watcher = watcher
+}
+
+func (g *GUI) stopWatcher() {
+	if g.watcher != nil {
+		g.watcher.Close()
+		g.watcher = nil
+	}
+}
+
+func (g *GUI) onLibraryChanged() {
+	g.state.Lock()
+	defer g.state.Unlock()
+	if g.state.localDB == nil || g.state.switchDB == nil {
+		return
+	}
+
+	settingsObj := settings.ReadSettings(g.baseFolder)
+	affected, err := g.localDbManager.UpdateLocalSwitchFilesDB(g.state.localDB, g.scanFolders(), nil, settingsObj.ScanRecursively)
+	if err != nil {
+		g.sugarLogger.Errorf("Failed to update local library - %v", err)
+		return
+	}
+	if len(affected) == 0 {
+		return
+	}
+	g.sugarLogger.Infof("Local library changed, %v titles affected", len(affected))
+
+	if g.state.missingUpdates != nil {
+		process.UpdateMissingUpdates(g.state.missingUpdates, g.state.localDB.TitlesMap, g.state.switchDB.TitlesMap,
+			toIgnoreMap(settingsObj.IgnoreUpdateTitleIds), settingsObj.IgnoreDLCUpdates, settingsObj.PreferredLanguages, affected)
+	}
+	if g.state.missingDLC != nil {
+		process.UpdateMissingDLC(g.state.missingDLC, g.state.localDB.TitlesMap, g.state.switchDB.TitlesMap,
+			toIgnoreMap(settingsObj.IgnoreDLCTitleIds), settingsObj.PreferredLanguages, affected)
+	}
+
+	msg, _ := json.Marshal(g.buildLibraryResponse(g.state.localDB))
+	g.state.window.SendMessage(Message{Name: "libraryChanged", Payload: string(msg)}, func(m *astilectron.EventMessage) {})
+}
+
+// planOrganizeLibrary returns the deletion of old updates (when enabled) followed by the organization of the library
+func (g *GUI) planOrganizeLibrary() (*process.Plan, error) {
+	settingsObj := settings.ReadSettings(g.baseFolder)
+	plan := process.NewPlan()
+	if settingsObj.OrganizeOptions.DeleteOldUpdateFiles {
+		plan.Append(process.PlanDeleteOldUpdates(g.baseFolder, g.state.localDB))
+	}
+	// built on top of the deletions, the deleted files do not take the destination of the organized ones
+	return process.PlanOrganizeByFolders(settingsObj.Folder, g.state.localDB, g.state.switchDB, plan, g)
+}
+
+func newPlanResponse(plan *process.Plan) PlanResponse {
+	response := PlanResponse{Summary: plan.Summary(), Actions: []string{}, Pending: []PlanCollision{}}
+	for _, action := range plan.Actions {
+		response.Actions = append(response.Actions, action.String())
+		if action.IsSkipped() {
+			response.Collisions++
+		}
+	}
+	for _, index := range plan.PendingCollisions() {
+		response.Pending = append(response.Pending, PlanCollision{Index: index, Action: plan.Actions[index].String()})
 	}
-	process.OrganizeByFolders(folderToScan, g.state.localDB, g.state.switchDB, g)
+	return response
 }
 
 func (g *GUI) UpdateProgress(curr int, total int, message string) {
@@ -422,7 +772,7 @@ func (g *GUI) getMissingGames() []SwitchTitle {
 
 		result = append(result, SwitchTitle{
 			TitleId:     v.Attributes.Id,
-			Name:        v.Attributes.Name,
+			Name:        process.GetTitleName(v, nil, options.PreferredLanguages),
 			Icon:        v.Attributes.BannerUrl,
 			Region:      v.Attributes.Region,
 			ReleaseDate: v.Attributes.ParsedReleaseDate,
@@ -0,0 +1,150 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/switchfs"
+	"go.uber.org/zap"
+)
+
+// DecompressTitle converts the NSZ/XCZ files of a title (base, updates and DLC) into NSP/XCI files
+// next to the originals, an empty titleId decompresses every compressed file in the library.
+// The paths of the created files are returned.
+func DecompressTitle(localDB *db.LocalSwitchFilesDB, titleId string, updateProgress db.ProgressUpdater) ([]string, error) {
+	files, err := getTitleFiles(localDB, titleId)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed []string
+	for file := range files {
+		if db.IsCompressed(file.FileName) {
+			compressed = append(compressed, filepath.Join(file.BaseFolder, file.FileName))
+		}
+	}
+	if len(compressed) == 0 {
+		return nil, errors.New("no NSZ/XCZ files found")
+	}
+
+	var created []string
+	var failed []string
+	for i, filePath := range compressed {
+		if updateProgress != nil {
+			updateProgress.UpdateProgress(i, len(compressed), "Decompressing "+filepath.Base(filePath))
+		}
+		outputPath, err := DecompressFile(filePath)
+		if err != nil {
+			zap.S().Errorf("Failed to decompress %v [%v]", filePath, err)
+			failed = append(failed, filepath.Base(filePath)+" - "+err.Error())
+			continue
+		}
+		created = append(created, outputPath)
+	}
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(len(compressed), len(compressed), "Decompression completed")
+	}
+
+	if len(failed) != 0 {
+		return created, errors.New("failed to decompress:\n" + strings.Join(failed, "\n"))
+	}
+	return created, nil
+}
+
+// getTitleFiles returns the files of a title (base, updates and DLC), every file of the library for an empty titleId
+func getTitleFiles(localDB *db.LocalSwitchFilesDB, titleId string) (map[db.ExtendedFileInfo]db.SwitchFileInfo, error) {
+	idPrefix := ""
+	if titleId != "" {
+		if len(titleId) != 16 {
+			return nil, errors.New("invalid title id " + titleId)
+		}
+		idPrefix = db.GetTitlePrefix(strings.ToLower(titleId))
+	}
+
+	files := map[db.ExtendedFileInfo]db.SwitchFileInfo{}
+	for k, v := range localDB.TitlesMap {
+		if idPrefix != "" && !strings.EqualFold(k, idPrefix) {
+			continue
+		}
+		if v.BaseExist {
+			files[v.File.ExtendedInfo] = v.File
+		}
+		for _, update := range v.Updates {
+			files[update.ExtendedInfo] = update
+		}
+		for _, dlc := range v.Dlc {
+			files[dlc.ExtendedInfo] = dlc
+		}
+	}
+	return files, nil
+}
+
+// DecompressFile converts a single NSZ/XCZ file into a NSP/XCI with the same name, the original is kept
+func DecompressFile(filePath string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	outputExt := ""
+	switch ext {
+	case ".nsz":
+		outputExt = ".nsp"
+	case ".xcz":
+		outputExt = ".xci"
+	default:
+		return "", errors.New("not a NSZ/XCZ file")
+	}
+
+	outputPath := filePath[:len(filePath)-len(ext)] + outputExt
+	if _, err := os.Stat(outputPath); err == nil {
+		return "", errors.New("file already exists " + outputPath)
+	}
+
+	zap.S().Infof("Decompressing %v to %v", filePath, outputPath)
+	err := switchfs.DecompressFile(filePath, outputPath)
+	if err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// CompressFile converts a NSP/XCI file into the NSZ/XCZ at outputPath, the compressed file is verified
+// before returning. The source file is kept, a plan moves it to the trash afterwards.
+func CompressFile(filePath string, outputPath string) error {
+	if _, err := os.Stat(outputPath); err == nil {
+		return errors.New("file already exists " + outputPath)
+	}
+
+	zap.S().Infof("Compressing %v to %v", filePath, outputPath)
+	return switchfs.CompressFile(filePath, outputPath)
+}
+
+func compressedFileName(fileName string) (string, bool) {
+	ext := filepath.Ext(fileName)
+	switch strings.ToLower(ext) {
+	case ".nsp":
+		return fileName[:len(fileName)-len(ext)] + ".nsz", true
+	case ".xci":
+		return fileName[:len(fileName)-len(ext)] + ".xcz", true
+	}
+	return "", false
+}
+
+func uncompressedFileName(fileName string) (string, bool) {
+	ext := filepath.Ext(fileName)
+	switch strings.ToLower(ext) {
+	case ".nsz":
+		return fileName[:len(fileName)-len(ext)] + ".nsp", true
+	case ".xcz":
+		return fileName[:len(fileName)-len(ext)] + ".xci", true
+	}
+	return "", false
+}
+
+// otherFormatFileName returns the compressed name of an uncompressed file, and the other way around
+func otherFormatFileName(fileName string) (string, bool) {
+	if name, ok := compressedFileName(fileName); ok {
+		return name, true
+	}
+	return uncompressedFileName(fileName)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package process
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDeviceError checks if a rename failed because the destination is on another file system
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package process
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ERROR_NOT_SAME_DEVICE, returned by MoveFileEx when the destination is on another volume
+const errorNotSameDevice = syscall.Errno(17)
+
+// isCrossDeviceError checks if a rename failed because the destination is on another volume
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, errorNotSameDevice) || errors.Is(err, syscall.EXDEV)
+}
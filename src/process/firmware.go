@@ -0,0 +1,53 @@
+package process
+
+import (
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+// TitleFirmware holds the firmware requirements of the local files of a title
+type TitleFirmware struct {
+	RequiredSystemVersion uint32 // highest system version needed by the base, latest update and DLC
+	KeyGeneration         int    // highest key generation of the base, latest update and DLC
+}
+
+// RequiredFirmware returns the firmware version needed to run every local file of the title
+func (f TitleFirmware) RequiredFirmware() string {
+	return settings.FormatSystemVersion(f.RequiredSystemVersion)
+}
+
+// GetTitleFirmware returns the firmware requirements of the base, latest update and DLC of a title
+func GetTitleFirmware(gameFiles *db.SwitchGameFiles) TitleFirmware {
+	result := TitleFirmware{}
+	add := func(file db.SwitchFileInfo) {
+		if file.Metadata == nil {
+			return
+		}
+		result.RequiredSystemVersion = max(result.RequiredSystemVersion, file.Metadata.RequiredFirmware())
+		result.KeyGeneration = max(result.KeyGeneration, file.Metadata.KeyGeneration)
+	}
+	if gameFiles.BaseExist {
+		add(gameFiles.File)
+	}
+	if update, ok := gameFiles.Updates[gameFiles.LatestUpdate]; ok {
+		add(update)
+	}
+	for _, dlc := range gameFiles.Dlc {
+		add(dlc)
+	}
+	return result
+}
+
+// NeedsNewerFirmware checks if a title needs a newer firmware than the target firmware of the settings,
+// always false when no target firmware is set
+func NeedsNewerFirmware(firmware TitleFirmware, targetFirmware string) bool {
+	if targetFirmware == "" {
+		return false
+	}
+	target, err := settings.ParseSystemVersion(targetFirmware)
+	if err != nil {
+		return false
+	}
+	// the lower bits are the build number, which is not part of the firmware version
+	return firmware.RequiredSystemVersion&^0xFFFF > target
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/trembon/switch-library-manager/db"
 	"github.com/trembon/switch-library-manager/switchfs"
@@ -19,10 +20,13 @@ type IncompleteTitle struct {
 	MissingDLC       []string `json:"missing_dlc"`
 }
 
+// ScanForMissingUpdates returns the titles and DLC of the local library with a newer version in the titles db,
+// the titles are named with GetTitleName
 func ScanForMissingUpdates(localDB map[string]*db.SwitchGameFiles,
 	switchDB map[string]*db.SwitchTitle,
 	ignoreTitleIds map[string]struct{},
-	ignoreDLCupdates bool) map[string]IncompleteTitle {
+	ignoreDLCupdates bool,
+	preferredLanguages []string) map[string]IncompleteTitle {
 
 	result := map[string]IncompleteTitle{}
 
@@ -43,6 +47,7 @@ func ScanForMissingUpdates(localDB map[string]*db.SwitchGameFiles,
 		}
 
 		switchTitle := IncompleteTitle{Attributes: switchDB[idPrefix].Attributes, Meta: switchFile.File.Metadata}
+		switchTitle.Attributes.Name = GetTitleName(switchDB[idPrefix], switchFile, preferredLanguages)
 
 		//sort the available local versions
 		localVersions := make([]int, len(switchFile.Updates))
@@ -122,8 +127,10 @@ func ScanForMissingUpdates(localDB map[string]*db.SwitchGameFiles,
 	return result
 }
 
+// ScanForMissingDLC returns the titles of the local library with DLC missing from the library, the titles are
+// named with GetTitleName
 func ScanForMissingDLC(localDB map[string]*db.SwitchGameFiles,
-	switchDB map[string]*db.SwitchTitle, ignoreTitleIds map[string]struct{}) map[string]IncompleteTitle {
+	switchDB map[string]*db.SwitchTitle, ignoreTitleIds map[string]struct{}, preferredLanguages []string) map[string]IncompleteTitle {
 	result := map[string]IncompleteTitle{}
 
 	//iterate over local files, and compare to remote versions
@@ -137,6 +144,7 @@ func ScanForMissingDLC(localDB map[string]*db.SwitchGameFiles,
 			continue
 		}
 		switchTitle := IncompleteTitle{Attributes: switchDB[idPrefix].Attributes}
+		switchTitle.Attributes.Name = GetTitleName(switchDB[idPrefix], switchFile, preferredLanguages)
 
 		//process dlc
 		if len(switchDB[idPrefix].Dlc) != 0 {
@@ -157,6 +165,58 @@ func ScanForMissingDLC(localDB map[string]*db.SwitchGameFiles,
 	return result
 }
 
+// UpdateMissingUpdates recomputes the missing updates for the given title id prefixes only,
+// replacing their previous entries in result.
+func UpdateMissingUpdates(result map[string]IncompleteTitle,
+	localDB map[string]*db.SwitchGameFiles,
+	switchDB map[string]*db.SwitchTitle,
+	ignoreTitleIds map[string]struct{},
+	ignoreDLCupdates bool,
+	preferredLanguages []string,
+	idPrefixes map[string]struct{}) {
+
+	removeTitlePrefixes(result, idPrefixes)
+	for k, v := range ScanForMissingUpdates(filterTitlePrefixes(localDB, idPrefixes), switchDB, ignoreTitleIds, ignoreDLCupdates, preferredLanguages) {
+		result[k] = v
+	}
+}
+
+// UpdateMissingDLC recomputes the missing DLC for the given title id prefixes only,
+// replacing their previous entries in result.
+func UpdateMissingDLC(result map[string]IncompleteTitle,
+	localDB map[string]*db.SwitchGameFiles,
+	switchDB map[string]*db.SwitchTitle,
+	ignoreTitleIds map[string]struct{},
+	preferredLanguages []string,
+	idPrefixes map[string]struct{}) {
+
+	removeTitlePrefixes(result, idPrefixes)
+	for k, v := range ScanForMissingDLC(filterTitlePrefixes(localDB, idPrefixes), switchDB, ignoreTitleIds, preferredLanguages) {
+		result[k] = v
+	}
+}
+
+func filterTitlePrefixes(localDB map[string]*db.SwitchGameFiles, idPrefixes map[string]struct{}) map[string]*db.SwitchGameFiles {
+	filtered := map[string]*db.SwitchGameFiles{}
+	for idPrefix := range idPrefixes {
+		if switchFile, ok := localDB[idPrefix]; ok {
+			filtered[idPrefix] = switchFile
+		}
+	}
+	return filtered
+}
+
+func removeTitlePrefixes(result map[string]IncompleteTitle, idPrefixes map[string]struct{}) {
+	for id := range result {
+		if len(id) != 16 {
+			continue
+		}
+		if _, ok := idPrefixes[db.GetTitlePrefix(strings.ToLower(id))]; ok {
+			delete(result, id)
+		}
+	}
+}
+
 func ScanForBrokenFiles(localDB map[string]*db.SwitchGameFiles) []db.SwitchFileInfo {
 	var result []db.SwitchFileInfo
 
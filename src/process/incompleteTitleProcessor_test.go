@@ -0,0 +1,41 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/switchfs"
+)
+
+func TestUpdateMissingDLC(t *testing.T) {
+	newTitle := func(id string, dlcId string) *db.SwitchTitle {
+		return &db.SwitchTitle{Attributes: db.TitleAttributes{Id: id, Name: id},
+			Dlc: map[string]db.TitleAttributes{dlcId: {Id: dlcId, Name: "DLC"}}}
+	}
+	switchDB := map[string]*db.SwitchTitle{
+		"0100000000010": newTitle("0100000000010000", "0100000000011001"),
+		"0100000000020": newTitle("0100000000020000", "0100000000021001"),
+	}
+	newGame := func(id string) *db.SwitchGameFiles {
+		return &db.SwitchGameFiles{BaseExist: true, Dlc: map[string]db.SwitchFileInfo{},
+			File: db.SwitchFileInfo{Metadata: &switchfs.ContentMetaAttributes{TitleId: id}}}
+	}
+	localDB := map[string]*db.SwitchGameFiles{
+		"0100000000010": newGame("0100000000010000"),
+		"0100000000020": newGame("0100000000020000"),
+	}
+	missing := ScanForMissingDLC(localDB, switchDB, nil, nil)
+	if len(missing) != 2 {
+		t.Fatalf("expected 2 titles with missing DLC, got %v", missing)
+	}
+
+	// the DLC of the first title was added
+	localDB["0100000000010"].Dlc["0100000000011001"] = db.SwitchFileInfo{}
+	UpdateMissingDLC(missing, localDB, switchDB, nil, nil, map[string]struct{}{"0100000000010": {}})
+	if _, ok := missing["0100000000010000"]; ok || len(missing) != 1 {
+		t.Fatalf("expected only the second title to miss DLC, got %v", missing)
+	}
+	if _, ok := missing["0100000000020000"]; !ok {
+		t.Fatalf("expected the unaffected title to be kept, got %v", missing)
+	}
+}
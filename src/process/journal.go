@@ -0,0 +1,212 @@
+package process
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/trembon/switch-library-manager/db"
+	"go.uber.org/zap"
+)
+
+const (
+	JOURNAL_FOLDER = "journal"
+	TRASH_FOLDER   = "trash"
+
+	journalExtension       = ".jsonl"
+	undoneJournalExtension = ".undone.jsonl"
+	operationIdLayout      = "20060102-150405.000000"
+)
+
+// JournalEntry is a change made to the local library by executing a plan, the actions are the ones of
+// the plan, except for PLAN_ACTION_DELETE_EMPTY_FOLDERS which is recorded once per deleted folder
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	From   string    `json:"from,omitempty"`
+	// To is the new location of the file, the location in the trash for a deleted file
+	To string `json:"to,omitempty"`
+}
+
+// Journal records the changes of a single run (operation), so the run can be undone
+type Journal struct {
+	OperationId string
+	trashFolder string
+	file        *os.File
+	entries     int
+}
+
+// JournalInfo describes a recorded run
+type JournalInfo struct {
+	OperationId string    `json:"operation_id"`
+	Created     time.Time `json:"created"`
+	Entries     int       `json:"entries"`
+}
+
+func newJournal(baseFolder string) (*Journal, error) {
+	folder := filepath.Join(baseFolder, JOURNAL_FOLDER)
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return nil, err
+	}
+	operationId := time.Now().Format(operationIdLayout)
+	file, err := os.OpenFile(filepath.Join(folder, operationId+journalExtension), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{OperationId: operationId, trashFolder: TrashFolder(baseFolder), file: file}, nil
+}
+
+// record appends an entry, synced to disk so the changes made before a crash can still be undone
+func (j *Journal) record(action string, from string, to string) error {
+	data, err := json.Marshal(JournalEntry{Time: time.Now(), Action: action, From: from, To: to})
+	if err != nil {
+		return err
+	}
+	if _, err = j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	j.entries++
+	return j.file.Sync()
+}
+
+// trashPath returns where a deleted file is kept until the run is undone or purged
+func (j *Journal) trashPath(filePath string) string {
+	return filepath.Join(j.trashFolder, j.OperationId, trashRelativePath(filePath))
+}
+
+// close removes the journal of a run which did not change anything
+func (j *Journal) close() {
+	_ = j.file.Close()
+	if j.entries == 0 {
+		_ = os.Remove(j.file.Name())
+	}
+}
+
+// LastJournal returns the most recent run which was not undone yet, nil when there is none
+func LastJournal(baseFolder string) (*JournalInfo, error) {
+	files, err := os.ReadDir(filepath.Join(baseFolder, JOURNAL_FOLDER))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var operations []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), journalExtension) && !strings.HasSuffix(file.Name(), undoneJournalExtension) {
+			operations = append(operations, strings.TrimSuffix(file.Name(), journalExtension))
+		}
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	// the operation ids sort by date
+	operationId := slices.Max(operations)
+	entries, err := readJournal(baseFolder, operationId)
+	if err != nil {
+		return nil, err
+	}
+	info := &JournalInfo{OperationId: operationId, Entries: len(entries)}
+	if len(entries) != 0 {
+		info.Created = entries[0].Time
+	}
+	return info, nil
+}
+
+func readJournal(baseFolder string, operationId string) ([]JournalEntry, error) {
+	file, err := os.Open(filepath.Join(baseFolder, JOURNAL_FOLDER, operationId+journalExtension))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := JournalEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last line of a run interrupted while writing it
+			zap.S().Warnf("Ignoring malformed journal entry in %v - %v", operationId, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// UndoLastRun reverts the changes of the most recent run, in reverse order: files are moved back to their
+// original location, deleted files are restored from the trash, created folders are removed when empty and
+// deleted folders are created again. The journal is kept, marked as undone.
+func UndoLastRun(baseFolder string, updateProgress db.ProgressUpdater) (*JournalInfo, error) {
+	info, err := LastJournal(baseFolder)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errors.New("there is nothing to undo")
+	}
+	entries, err := readJournal(baseFolder, info.OperationId)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if updateProgress != nil {
+			updateProgress.UpdateProgress(len(entries)-i, len(entries), "Undoing "+entry.Action+" "+entry.From)
+		}
+		if err = undoJournalEntry(entry, updateProgress); err != nil {
+			zap.S().Errorf("Failed to undo %v %v -> %v [%v]", entry.Action, entry.From, entry.To, err)
+			failed = append(failed, entry.Action+" "+entry.From+" - "+err.Error())
+		}
+	}
+
+	// mark the run as undone even when some entries failed, the other ones must not be undone twice
+	journalPath := filepath.Join(baseFolder, JOURNAL_FOLDER, info.OperationId)
+	if err = os.Rename(journalPath+journalExtension, journalPath+undoneJournalExtension); err != nil {
+		return info, err
+	}
+	removeEmptyTrashRun(filepath.Join(TrashFolder(baseFolder), info.OperationId))
+	if len(failed) != 0 {
+		return info, errors.New("failed to undo:\n" + strings.Join(failed, "\n"))
+	}
+	return info, nil
+}
+
+func undoJournalEntry(entry JournalEntry, updateProgress db.ProgressUpdater) error {
+	switch entry.Action {
+	case PLAN_ACTION_CREATE_FOLDER:
+		// only when empty, the folder can hold files which were not moved by the run
+		if err := os.Remove(entry.To); err != nil && !os.IsNotExist(err) {
+			zap.S().Infof("Keeping folder %v - %v", entry.To, err)
+		}
+		return nil
+	case PLAN_ACTION_MOVE, PLAN_ACTION_DELETE:
+		return restoreFile(entry.To, entry.From, updateProgress)
+	case PLAN_ACTION_COMPRESS:
+		// the original file was restored from the trash by undoing its deletion, recorded after this entry
+		if _, err := os.Stat(entry.From); err != nil {
+			return errors.New("the original file " + entry.From + " was not restored from the trash, keeping " + entry.To)
+		}
+		return os.Remove(entry.To)
+	case PLAN_ACTION_DELETE_EMPTY_FOLDERS:
+		return os.MkdirAll(entry.From, os.ModePerm)
+	}
+	return errors.New("unsupported action [" + entry.Action + "]")
+}
+
+// restoreFile moves a file back to its original location, without replacing a file which is there now
+func restoreFile(from string, to string, updateProgress db.ProgressUpdater) error {
+	if from != to && existsAsOtherFile(to, from) {
+		return errors.New(to + " already exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+		return err
+	}
+	return moveFile(from, to, updateProgress)
+}
@@ -0,0 +1,100 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUndoLastRun(t *testing.T) {
+	appFolder := t.TempDir()
+	folder := t.TempDir()
+	oldFolder := filepath.Join(folder, "Old")
+	_ = os.Mkdir(oldFolder, os.ModePerm)
+	_ = os.Mkdir(filepath.Join(folder, "Empty"), os.ModePerm)
+	for _, name := range []string{filepath.Join(oldFolder, "game.nsp"), filepath.Join(folder, "update.nsp")} {
+		_ = os.WriteFile(name, []byte(filepath.Base(name)), 0644)
+	}
+	gameFolder := filepath.Join(folder, "Game")
+
+	if _, err := UndoLastRun(appFolder, nil); err == nil {
+		t.Fatalf("expected an error when there is nothing to undo")
+	}
+
+	plan := NewPlan()
+	plan.delete(filepath.Join(folder, "update.nsp"), "old update")
+	plan.createFolder(gameFolder)
+	plan.move(filepath.Join(oldFolder, "game.nsp"), filepath.Join(gameFolder, "Game [0100000000010000].nsp"), false)
+	plan.deleteEmptyFolders(folder)
+	if err := plan.Execute(appFolder, nil); err != nil {
+		t.Fatalf("failed to execute plan: %v", err)
+	}
+	if _, err := os.Stat(oldFolder); err == nil {
+		t.Fatalf("expected the old folder to be deleted once empty")
+	}
+
+	info, err := LastJournal(appFolder)
+	if err != nil || info == nil {
+		t.Fatalf("expected the run to be journaled, got %v (%v)", info, err)
+	}
+	// the deleted file, the created folder, the moved file and the 2 empty folders
+	if info.Entries != 5 {
+		t.Errorf("expected 5 journal entries, got %v", info.Entries)
+	}
+
+	undone, err := UndoLastRun(appFolder, nil)
+	if err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if undone.OperationId != info.OperationId {
+		t.Errorf("expected operation %v to be undone, got %v", info.OperationId, undone.OperationId)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(oldFolder, "game.nsp"): "game.nsp",
+		filepath.Join(folder, "update.nsp"):  "update.nsp",
+	} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != content {
+			t.Errorf("expected %v to be restored, got %v (%v)", name, string(data), err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(folder, "Empty")); err != nil {
+		t.Errorf("expected the deleted empty folder to be created again")
+	}
+	if _, err = os.Stat(gameFolder); err == nil {
+		t.Errorf("expected the created folder to be removed")
+	}
+	if info, _ = LastJournal(appFolder); info != nil {
+		t.Errorf("expected no run left to undo, got %+v", info)
+	}
+}
+
+func TestUndoCompress(t *testing.T) {
+	appFolder := t.TempDir()
+	folder := t.TempDir()
+	original, compressed := filepath.Join(folder, "game.nsp"), filepath.Join(folder, "game.nsz")
+	_ = os.WriteFile(original, []byte("game"), 0644)
+
+	// as recorded by a compress action, without compressing the file which needs prod.keys
+	journal, err := newJournal(appFolder)
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	_ = os.WriteFile(compressed, []byte("compressed"), 0644)
+	if err = journal.record(PLAN_ACTION_COMPRESS, original, compressed); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if err = trashFile(journal, original, nil); err != nil {
+		t.Fatalf("failed to move the original file to the trash: %v", err)
+	}
+	journal.close()
+
+	if _, err = UndoLastRun(appFolder, nil); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if data, err := os.ReadFile(original); err != nil || string(data) != "game" {
+		t.Fatalf("expected the original file to be restored, got %v (%v)", string(data), err)
+	}
+	if _, err = os.Stat(compressed); !os.IsNotExist(err) {
+		t.Fatalf("expected the compressed file to be removed")
+	}
+}
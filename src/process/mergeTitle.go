@@ -0,0 +1,84 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/switchfs"
+	"go.uber.org/zap"
+)
+
+// MergeTitle builds a single multi-content file with the base, the latest update and the given DLC
+// (all DLC when dlcIds is nil) of a title next to the base file. The output is a XCI when the base
+// is a XCI, otherwise a NSP, compressed when any of the merged files is compressed.
+// The original files are kept, the path of the merged file is returned.
+func MergeTitle(localDB *db.LocalSwitchFilesDB, titleId string, dlcIds []string, updateProgress db.ProgressUpdater) (string, error) {
+	if len(titleId) != 16 {
+		return "", errors.New("invalid title id " + titleId)
+	}
+	title, ok := localDB.TitlesMap[db.GetTitlePrefix(strings.ToLower(titleId))]
+	if !ok || !title.BaseExist {
+		return "", errors.New("base game of " + titleId + " was not found in the local library")
+	}
+	if title.IsSplit {
+		return "", errors.New("split files cannot be merged")
+	}
+
+	files := []db.ExtendedFileInfo{title.File.ExtendedInfo}
+	if update, ok := title.Updates[title.LatestUpdate]; ok && !slices.Contains(files, update.ExtendedInfo) {
+		files = append(files, update.ExtendedInfo)
+	}
+	for id, dlc := range title.Dlc {
+		if dlcIds != nil && !slices.ContainsFunc(dlcIds, func(dlcId string) bool { return strings.EqualFold(dlcId, id) }) {
+			continue
+		}
+		if !slices.Contains(files, dlc.ExtendedInfo) {
+			files = append(files, dlc.ExtendedInfo)
+		}
+	}
+	if len(files) == 1 {
+		return "", errors.New("nothing to merge, the title has no update or DLC files")
+	}
+	// keep the DLC order stable between runs
+	slices.SortFunc(files[1:], func(a, b db.ExtendedFileInfo) int {
+		return strings.Compare(a.FileName, b.FileName)
+	})
+
+	var filePaths []string
+	compressed := false
+	for _, file := range files {
+		filePaths = append(filePaths, filepath.Join(file.BaseFolder, file.FileName))
+		compressed = compressed || db.IsCompressed(file.FileName)
+	}
+
+	baseName := title.File.ExtendedInfo.FileName
+	ext := strings.ToLower(filepath.Ext(baseName))
+	outputExt := ".nsp"
+	if ext == ".xci" || ext == ".xcz" {
+		outputExt = ".xci"
+	}
+	if compressed {
+		outputExt = outputExt[:3] + "z"
+	}
+	outputPath := filepath.Join(title.File.ExtendedInfo.BaseFolder, baseName[:len(baseName)-len(ext)]+" [merged]"+outputExt)
+	if _, err := os.Stat(outputPath); err == nil {
+		return "", errors.New("file already exists " + outputPath)
+	}
+
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(0, 1, "Merging "+strings.Join(filePaths, ", "))
+	}
+	zap.S().Infof("Merging %v into %v", filePaths, outputPath)
+	err := switchfs.MergeFiles(filePaths, outputPath)
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(1, 1, "Merge completed")
+	}
+	if err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
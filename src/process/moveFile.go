@@ -0,0 +1,176 @@
+package process
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/trembon/switch-library-manager/db"
+	"go.uber.org/zap"
+)
+
+const (
+	// partial copy of a file moved to another drive, kept when interrupted so the copy can be resumed
+	partialCopyExtension = ".slm-part"
+	copyBufferSize       = 4 * 1024 * 1024
+)
+
+// moveFile moves a file, by renaming it or, when the destination is on another drive (like a NAS share),
+// by copying it to the destination, verifying the copy and then removing the original file
+func moveFile(from string, to string, updateProgress db.ProgressUpdater) error {
+	if from == to {
+		return nil
+	}
+	err := os.Rename(from, to)
+	if err == nil || !isCrossDeviceError(err) {
+		return err
+	}
+	zap.S().Infof("%v is on another drive than %v, copying it", to, from)
+	return copyAndRemoveFile(from, to, updateProgress)
+}
+
+// copyAndRemoveFile copies a file to a partial file next to the destination, compares the hash of the copy
+// with the one of the original file, syncs the copy to disk and renames it to the destination before removing
+// the original file. An interrupted copy is resumed from its partial file, and an interrupted move which
+// already has an identical destination file only removes the original file.
+func copyAndRemoveFile(from string, to string, updateProgress db.ProgressUpdater) error {
+	fromInfo, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(to); err == nil {
+		if !isSameContent(from, to) {
+			return errors.New("file already exists " + to)
+		}
+		zap.S().Infof("%v was already copied to %v", from, to)
+		return os.Remove(from)
+	}
+
+	partialPath := to + partialCopyExtension
+	fromHash, err := copyToPartialFile(from, partialPath, fromInfo, updateProgress)
+	if err != nil {
+		return err
+	}
+	partialHash, err := hashFile(partialPath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(fromHash, partialHash) {
+		// start over on the next attempt, the partial file can not be trusted
+		_ = os.Remove(partialPath)
+		return errors.New("the copy of " + from + " does not match the original file")
+	}
+	_ = os.Chtimes(partialPath, fromInfo.ModTime(), fromInfo.ModTime())
+	if err = os.Rename(partialPath, to); err != nil {
+		return err
+	}
+	syncFolder(filepath.Dir(to))
+	return os.Remove(from)
+}
+
+// copyToPartialFile copies a file, appending to the partial file left by an interrupted copy, and returns
+// the hash of the original file. The partial file is synced to disk before returning.
+func copyToPartialFile(from string, partialPath string, fromInfo os.FileInfo, updateProgress db.ProgressUpdater) ([]byte, error) {
+	source, err := os.Open(from)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	var offset int64
+	if partialInfo, err := os.Stat(partialPath); err == nil && partialInfo.Size() <= fromInfo.Size() {
+		offset = partialInfo.Size()
+	} else if err == nil {
+		// larger than the original file, which changed since
+		_ = os.Remove(partialPath)
+	}
+	partial, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fromInfo.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+	defer partial.Close()
+
+	hash := sha256.New()
+	if offset != 0 {
+		zap.S().Infof("Resuming the copy of %v at %v bytes", from, offset)
+		// the part which was already copied is hashed from the original file, and verified with the full copy
+		if _, err = io.CopyN(hash, source, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	writer := &progressWriter{writer: partial, written: offset, total: fromInfo.Size(),
+		message: "Copying " + filepath.Base(from), updateProgress: updateProgress}
+	buffer := make([]byte, copyBufferSize)
+	if _, err = io.CopyBuffer(io.MultiWriter(writer, hash), source, buffer); err != nil {
+		return nil, err
+	}
+	if err = partial.Sync(); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), partial.Close()
+}
+
+func hashFile(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.CopyBuffer(hash, file, make([]byte, copyBufferSize)); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// isSameContent checks if two files have the same size and hash, a file which can not be read is never the same
+func isSameContent(file string, otherFile string) bool {
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	otherInfo, err := os.Stat(otherFile)
+	if err != nil || fileInfo.Size() != otherInfo.Size() {
+		return false
+	}
+	fileHash, err := hashFile(file)
+	if err != nil {
+		return false
+	}
+	otherHash, err := hashFile(otherFile)
+	return err == nil && bytes.Equal(fileHash, otherHash)
+}
+
+// syncFolder syncs a renamed entry of a folder to disk, not supported on every platform and file system
+func syncFolder(folder string) {
+	file, err := os.Open(folder)
+	if err != nil {
+		return
+	}
+	_ = file.Sync()
+	_ = file.Close()
+}
+
+// progressWriter reports the progress of a copy in megabytes, once per percent copied
+type progressWriter struct {
+	writer         io.Writer
+	written        int64
+	total          int64
+	reported       int64
+	message        string
+	updateProgress db.ProgressUpdater
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	if w.updateProgress != nil && (w.written-w.reported)*100 >= w.total {
+		w.reported = w.written
+		w.updateProgress.UpdateProgress(int(w.written/(1024*1024)), int(w.total/(1024*1024)), w.message)
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyAndRemoveFile(t *testing.T) {
+	folder := t.TempDir()
+	content := strings.Repeat("update", 1000)
+	from := filepath.Join(folder, "update.nsp")
+	to := filepath.Join(folder, "nas", "update.nsp")
+	_ = os.MkdirAll(filepath.Dir(to), os.ModePerm)
+
+	// interrupted copy
+	_ = os.WriteFile(from, []byte(content), 0644)
+	_ = os.WriteFile(to+partialCopyExtension, []byte(content[:1000]), 0644)
+	if err := copyAndRemoveFile(from, to, nil); err != nil {
+		t.Fatalf("failed to resume the copy: %v", err)
+	}
+	if data, err := os.ReadFile(to); err != nil || string(data) != content {
+		t.Fatalf("expected the copy to match the original file, got %v bytes (%v)", len(data), err)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Fatalf("expected the original file to be removed")
+	}
+	if _, err := os.Stat(to + partialCopyExtension); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial file to be renamed")
+	}
+
+	// interrupted before removing the original file
+	_ = os.WriteFile(from, []byte(content), 0644)
+	if err := copyAndRemoveFile(from, to, nil); err != nil {
+		t.Fatalf("failed to complete the move: %v", err)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Fatalf("expected the original file to be removed")
+	}
+
+	// another file at the destination
+	_ = os.WriteFile(from, []byte(strings.ToUpper(content)), 0644)
+	if err := copyAndRemoveFile(from, to, nil); err == nil {
+		t.Fatalf("expected an error when the destination is another file")
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Fatalf("expected the original file to be kept")
+	}
+}
+
+func TestCopyAndRemoveFileCorruptedPartial(t *testing.T) {
+	folder := t.TempDir()
+	from := filepath.Join(folder, "update.nsp")
+	to := filepath.Join(folder, "nas", "update.nsp")
+	_ = os.MkdirAll(filepath.Dir(to), os.ModePerm)
+	_ = os.WriteFile(from, []byte("update"), 0644)
+	_ = os.WriteFile(to+partialCopyExtension, []byte("xx"), 0644)
+
+	if err := copyAndRemoveFile(from, to, nil); err == nil {
+		t.Fatalf("expected an error when the copy does not match the original file")
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Fatalf("expected the original file to be kept")
+	}
+	if _, err := os.Stat(to + partialCopyExtension); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial file to be removed")
+	}
+	// the next attempt starts over
+	if err := copyAndRemoveFile(from, to, nil); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if data, _ := os.ReadFile(to); string(data) != "update" {
+		t.Fatalf("expected the copy to match the original file, got %v", string(data))
+	}
+}
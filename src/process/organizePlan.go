@@ -0,0 +1,560 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/settings"
+	"go.uber.org/zap"
+)
+
+const (
+	PLAN_ACTION_CREATE_FOLDER        = "create_folder"
+	PLAN_ACTION_MOVE                 = "move"     // move and/or rename a file
+	PLAN_ACTION_COMPRESS             = "compress" // compress a file to its destination, moved as is when it can not be compressed
+	PLAN_ACTION_DELETE               = "delete"
+	PLAN_ACTION_DELETE_EMPTY_FOLDERS = "delete_empty_folders"
+)
+
+// how a collision is resolved, by the collision policy of the settings or by the user
+const (
+	COLLISION_SKIP      = "skip"
+	COLLISION_SUFFIX    = "suffix"    // moved to a free name, with a (1), (2)... suffix
+	COLLISION_OVERWRITE = "overwrite" // the file taking the destination is moved to the trash first
+)
+
+// PlanAction is a single change to the local library
+type PlanAction struct {
+	Action string `json:"action"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Collision is set when the destination is already taken, the action is skipped when the plan is executed
+	// unless the collision is resolved by adding a suffix or by overwriting the file taking the destination
+	Collision  string `json:"collision,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	Replaces   string `json:"replaces,omitempty"` // the file taking the destination
+}
+
+// IsSkipped checks if the action has a collision which is not resolved by adding a suffix or overwriting
+func (a PlanAction) IsSkipped() bool {
+	return a.Collision != "" && a.Resolution != COLLISION_SUFFIX && a.Resolution != COLLISION_OVERWRITE
+}
+
+func (a PlanAction) String() string {
+	var result string
+	switch a.Action {
+	case PLAN_ACTION_CREATE_FOLDER:
+		result = "create folder " + a.To
+	case PLAN_ACTION_MOVE:
+		if filepath.Dir(a.From) == filepath.Dir(a.To) {
+			result = "rename " + a.From + " -> " + filepath.Base(a.To)
+		} else {
+			result = "move " + a.From + " -> " + a.To
+		}
+	case PLAN_ACTION_COMPRESS:
+		result = "compress " + a.From + " -> " + a.To
+	case PLAN_ACTION_DELETE:
+		result = "delete " + a.From
+	case PLAN_ACTION_DELETE_EMPTY_FOLDERS:
+		result = "delete empty folders in " + a.From
+	default:
+		result = a.Action + " " + a.From + " " + a.To
+	}
+	if a.Reason != "" {
+		result += " (" + a.Reason + ")"
+	}
+	switch {
+	case a.Collision == "":
+	case a.Resolution == COLLISION_SUFFIX:
+		result += " [renamed, " + a.Collision + "]"
+	case a.Resolution == COLLISION_OVERWRITE:
+		result += " [replaces " + a.Replaces + ", " + a.Collision + "]"
+	case a.Resolution == COLLISION_SKIP:
+		result += " [skipped, " + a.Collision + "]"
+	default:
+		result += " [unresolved, skipped, " + a.Collision + "]"
+	}
+	return result
+}
+
+// Plan is the list of changes made to the local library by organizing it and deleting old updates,
+// the actions are executed in order
+type Plan struct {
+	Created time.Time    `json:"created"`
+	Actions []PlanAction `json:"actions"`
+
+	collisionPolicy string
+	versions        map[string]int // the version of the files of the library, by path
+	folders         map[string]bool
+	sources         map[string]bool
+	deleted         map[string]bool        // files deleted by the plan, their destination is free
+	destinations    map[string]plannedFile // by destinationKey
+}
+
+// plannedFile is a file which is at (or moved to) a destination of the plan
+type plannedFile struct {
+	from string
+	to   string
+}
+
+func NewPlan() *Plan {
+	return &Plan{Created: time.Now(), Actions: []PlanAction{}}
+}
+
+// Append adds the actions of another plan, to be executed after the ones of this plan
+func (p *Plan) Append(other *Plan) {
+	p.Actions = append(p.Actions, other.Actions...)
+	// keep the destinations taken by the other plan, for the collisions resolved later on
+	for key, file := range other.destinations {
+		if p.destinations == nil {
+			p.sources = map[string]bool{}
+			p.destinations = map[string]plannedFile{}
+		}
+		p.destinations[key] = file
+		p.sources[file.from] = true
+	}
+	for path := range other.deleted {
+		if p.deleted == nil {
+			p.deleted = map[string]bool{}
+		}
+		p.deleted[path] = true
+	}
+	for path, version := range other.versions {
+		if p.versions == nil {
+			p.versions = map[string]int{}
+		}
+		p.versions[path] = version
+	}
+}
+
+// Collisions returns the actions whose destination is already taken, resolved or skipped
+func (p *Plan) Collisions() []PlanAction {
+	var result []PlanAction
+	for _, action := range p.Actions {
+		if action.Collision != "" {
+			result = append(result, action)
+		}
+	}
+	return result
+}
+
+// PendingCollisions returns the index of the actions whose collision is left to the user by the ask policy
+func (p *Plan) PendingCollisions() []int {
+	var result []int
+	for i, action := range p.Actions {
+		if action.Collision != "" && action.Resolution == "" {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// Resolve sets how the collision of an action is resolved, COLLISION_SKIP, COLLISION_SUFFIX or COLLISION_OVERWRITE
+func (p *Plan) Resolve(index int, resolution string) error {
+	if index < 0 || index >= len(p.Actions) || p.Actions[index].Collision == "" {
+		return errors.New("there is no collision to resolve for action " + strconv.Itoa(index))
+	}
+	action := &p.Actions[index]
+	switch resolution {
+	case COLLISION_SKIP:
+		action.Resolution = resolution
+		return nil
+	case COLLISION_SUFFIX:
+		p.renameWithSuffix(action)
+	case COLLISION_OVERWRITE:
+		action.Resolution = resolution
+	default:
+		return errors.New("unsupported collision resolution [" + resolution + "]")
+	}
+	p.addDestination(*action)
+	return nil
+}
+
+// Summary returns the number of actions of each kind, like "2 folders to create, 10 files to move"
+func (p *Plan) Summary() string {
+	counts := map[string]int{}
+	collisions := map[string]int{}
+	for _, action := range p.Actions {
+		if action.Collision != "" {
+			collisions[action.Resolution]++
+		}
+		if !action.IsSkipped() {
+			counts[action.Action]++
+		}
+	}
+	var parts []string
+	add := func(count int, text string) {
+		if count != 0 {
+			parts = append(parts, fmt.Sprintf("%v %v", count, text))
+		}
+	}
+	add(counts[PLAN_ACTION_CREATE_FOLDER], "folders to create")
+	add(counts[PLAN_ACTION_MOVE], "files to move/rename")
+	add(counts[PLAN_ACTION_COMPRESS], "files to compress")
+	add(counts[PLAN_ACTION_DELETE], "files to delete")
+	add(counts[PLAN_ACTION_DELETE_EMPTY_FOLDERS], "folders to clean up")
+	add(collisions[COLLISION_SUFFIX], "collisions renamed")
+	add(collisions[COLLISION_OVERWRITE], "files replaced (moved to the trash)")
+	add(collisions[COLLISION_SKIP]+collisions[""], "collisions (skipped)")
+	if len(parts) == 0 {
+		return "nothing to do"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p *Plan) String() string {
+	var builder strings.Builder
+	for _, action := range p.Actions {
+		builder.WriteString(action.String())
+		builder.WriteString("\n")
+	}
+	builder.WriteString(p.Summary())
+	return builder.String()
+}
+
+// ExportPlan writes the plan as json, to be reviewed and executed later with ReadPlan
+func (p *Plan) ExportPlan(filePath string) error {
+	data, err := json.MarshalIndent(p, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// ReadPlan reads a plan exported with ExportPlan
+func ReadPlan(filePath string) (*Plan, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	if err = json.Unmarshal(data, plan); err != nil {
+		return nil, errors.New("invalid plan file - " + err.Error())
+	}
+	for _, action := range plan.Actions {
+		switch action.Action {
+		case PLAN_ACTION_CREATE_FOLDER, PLAN_ACTION_MOVE, PLAN_ACTION_COMPRESS, PLAN_ACTION_DELETE, PLAN_ACTION_DELETE_EMPTY_FOLDERS:
+		default:
+			return nil, errors.New("invalid plan file, unsupported action [" + action.Action + "]")
+		}
+		switch action.Resolution {
+		case "", COLLISION_SKIP, COLLISION_SUFFIX, COLLISION_OVERWRITE:
+		default:
+			return nil, errors.New("invalid plan file, unsupported collision resolution [" + action.Resolution + "]")
+		}
+	}
+	return plan, nil
+}
+
+// createFolder adds the creation of a folder, unless it already exists or is already in the plan
+func (p *Plan) createFolder(path string) {
+	if p.folders == nil {
+		p.folders = map[string]bool{}
+	}
+	if p.folders[path] {
+		return
+	}
+	p.folders[path] = true
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	p.Actions = append(p.Actions, PlanAction{Action: PLAN_ACTION_CREATE_FOLDER, To: path})
+}
+
+// addFileVersions adds the version of the files of the library, used by the overwrite_newer_version collision policy
+func (p *Plan) addFileVersions(localDB *db.LocalSwitchFilesDB) {
+	if p.versions == nil {
+		p.versions = map[string]int{}
+	}
+	add := func(file db.SwitchFileInfo, version int) {
+		if file.ExtendedInfo.FileName == "" {
+			return
+		}
+		// a multi content file holds several versions
+		path := filepath.Join(file.ExtendedInfo.BaseFolder, file.ExtendedInfo.FileName)
+		if current, ok := p.versions[path]; !ok || version > current {
+			p.versions[path] = version
+		}
+	}
+	for _, v := range localDB.TitlesMap {
+		if v.File.Metadata != nil {
+			add(v.File, v.File.Metadata.Version)
+		}
+		for version, update := range v.Updates {
+			add(update, version)
+		}
+		for _, dlc := range v.Dlc {
+			if dlc.Metadata != nil {
+				add(dlc, dlc.Metadata.Version)
+			}
+		}
+	}
+}
+
+// move adds the move of a file, with a collision when another file is already at (or moved to) its destination,
+// resolved according to the collision policy of the plan
+func (p *Plan) move(from string, to string, compress bool) {
+	if p.sources == nil {
+		p.sources = map[string]bool{}
+		p.destinations = map[string]plannedFile{}
+	}
+	action := PlanAction{Action: PLAN_ACTION_MOVE, From: from, To: to}
+	if compress && !db.IsCompressed(from) {
+		if compressedTo, ok := compressedFileName(to); ok {
+			action.Action = PLAN_ACTION_COMPRESS
+			action.To = compressedTo
+		}
+	}
+	if action.From == action.To {
+		p.destinations[destinationKey(action.To)] = plannedFile{from: from, to: from}
+		return
+	}
+
+	if replaces, collision := p.findCollision(action); collision != "" {
+		action.Collision = collision
+		action.Replaces = replaces
+		p.resolveCollision(&action)
+		zap.S().Warnf("Collision when organizing: %v", action)
+	}
+	if !action.IsSkipped() {
+		p.addDestination(action)
+	}
+	p.Actions = append(p.Actions, action)
+}
+
+// findCollision returns the file taking the destination of an action, on disk or in the plan, and why. The same
+// file in the other format (like game.nsp for game.nsz) takes the destination as well.
+func (p *Plan) findCollision(action PlanAction) (string, string) {
+	if other, ok := p.destinations[destinationKey(action.To)]; ok && other.from != action.From {
+		return other.to, "destination is also used by " + other.from
+	}
+	candidates := []string{action.To}
+	if otherFormat, ok := otherFormatFileName(action.To); ok {
+		candidates = append(candidates, otherFormat)
+	}
+	for _, candidate := range candidates {
+		if existsAsOtherFile(candidate, action.From) && !p.sources[candidate] && !p.deleted[candidate] {
+			if candidate == action.To && action.Action == PLAN_ACTION_MOVE && isSameContent(action.From, candidate) {
+				// an identical copy, like the destination of an interrupted move
+				continue
+			}
+			if candidate == action.To {
+				return candidate, "destination already exists"
+			}
+			return candidate, "destination already exists as " + filepath.Base(candidate)
+		}
+	}
+	return "", ""
+}
+
+// resolveCollision applies the collision policy, a collision is skipped when the policy does not apply to it
+func (p *Plan) resolveCollision(action *PlanAction) {
+	action.Resolution = COLLISION_SKIP
+	switch p.collisionPolicy {
+	case settings.COLLISION_POLICY_SUFFIX:
+		p.renameWithSuffix(action)
+	case settings.COLLISION_POLICY_OVERWRITE_NEWER_VERSION:
+		version, ok := p.versions[action.From]
+		otherVersion, otherOk := p.versions[action.Replaces]
+		if ok && otherOk && version > otherVersion {
+			action.Resolution = COLLISION_OVERWRITE
+		}
+	case settings.COLLISION_POLICY_OVERWRITE_COMPRESSED:
+		compressed := action.Action == PLAN_ACTION_COMPRESS || db.IsCompressed(action.From)
+		if compressed && !db.IsCompressed(action.Replaces) {
+			action.Resolution = COLLISION_OVERWRITE
+		}
+	case settings.COLLISION_POLICY_ASK:
+		action.Resolution = ""
+	}
+}
+
+// renameWithSuffix moves the file of an action to the first free name with a (1), (2)... suffix
+func (p *Plan) renameWithSuffix(action *PlanAction) {
+	ext := filepath.Ext(action.To)
+	name := strings.TrimSuffix(action.To, ext)
+	for i := 1; ; i++ {
+		to := fmt.Sprintf("%v (%v)%v", name, i, ext)
+		if _, collision := p.findCollision(PlanAction{From: action.From, To: to}); collision == "" {
+			action.To = to
+			action.Resolution = COLLISION_SUFFIX
+			return
+		}
+	}
+}
+
+// addDestination marks the destination of an action as taken by its file
+func (p *Plan) addDestination(action PlanAction) {
+	if p.sources == nil {
+		p.sources = map[string]bool{}
+		p.destinations = map[string]plannedFile{}
+	}
+	p.sources[action.From] = true
+	p.destinations[destinationKey(action.To)] = plannedFile{from: action.From, to: action.To}
+	if version, ok := p.versions[action.From]; ok {
+		p.versions[action.To] = version
+	}
+}
+
+// destinationKey is the same for a file and its other format, like game.nsp and game.nsz
+func destinationKey(path string) string {
+	if uncompressed, ok := uncompressedFileName(path); ok {
+		return uncompressed
+	}
+	return path
+}
+
+func (p *Plan) delete(path string, reason string) {
+	if p.deleted == nil {
+		p.deleted = map[string]bool{}
+	}
+	p.deleted[path] = true
+	p.Actions = append(p.Actions, PlanAction{Action: PLAN_ACTION_DELETE, From: path, Reason: reason})
+}
+
+func (p *Plan) deleteEmptyFolders(path string) {
+	p.Actions = append(p.Actions, PlanAction{Action: PLAN_ACTION_DELETE_EMPTY_FOLDERS, From: path})
+}
+
+// existsAsOtherFile checks if path exists and is not the file itself (like a different case on a case insensitive file system)
+func existsAsOtherFile(path string, file string) bool {
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	fileInfo, err := os.Stat(file)
+	return err != nil || !os.SameFile(pathInfo, fileInfo)
+}
+
+// Execute applies the actions of the plan in order, actions with an unresolved collision are skipped and a failed
+// action does not stop the following ones. The changes are recorded in a journal in the base folder so the
+// run can be undone, deleted files are moved to the trash folder. The runs past the retention rules are
+// purged from the trash first, so the trash always holds the files of the last run.
+func (p *Plan) Execute(baseFolder string, updateProgress db.ProgressUpdater) error {
+	logger := zap.S()
+	if _, _, err := PurgeTrash(baseFolder, PURGE_EXPIRED); err != nil {
+		logger.Warnf("Failed to purge the trash - %v", err)
+	}
+	journal, err := newJournal(baseFolder)
+	if err != nil {
+		return errors.New("failed to create the journal of the changes - " + err.Error())
+	}
+	defer journal.close()
+
+	var failed []string
+	for i, action := range p.Actions {
+		if action.IsSkipped() {
+			logger.Infof("Skipping %v", action)
+			continue
+		}
+		if updateProgress != nil {
+			updateProgress.UpdateProgress(i, len(p.Actions), action.String())
+		}
+		logger.Infof("Executing %v", action)
+		if err := executePlanAction(journal, action, updateProgress); err != nil {
+			logger.Errorf("Failed to %v [%v]\n", action, err)
+			failed = append(failed, action.String()+" - "+err.Error())
+		}
+	}
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(len(p.Actions), len(p.Actions), "Done")
+	}
+	if len(failed) != 0 {
+		return errors.New("failed to:\n" + strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// executePlanAction applies an action, and records what was actually done in the journal
+func executePlanAction(journal *Journal, action PlanAction, updateProgress db.ProgressUpdater) error {
+	switch action.Action {
+	case PLAN_ACTION_CREATE_FOLDER:
+		if _, err := os.Stat(action.To); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(action.To, os.ModePerm); err != nil {
+			return err
+		}
+		return journal.record(action.Action, "", action.To)
+	case PLAN_ACTION_MOVE:
+		if completed, err := prepareDestination(journal, action, updateProgress); err != nil || completed {
+			return err
+		}
+		if err := moveFile(action.From, action.To, updateProgress); err != nil {
+			return err
+		}
+		return journal.record(action.Action, action.From, action.To)
+	case PLAN_ACTION_COMPRESS:
+		if _, err := prepareDestination(journal, action, updateProgress); err != nil {
+			return err
+		}
+		err := CompressFile(action.From, action.To)
+		if err == nil {
+			if err = journal.record(action.Action, action.From, action.To); err != nil {
+				return err
+			}
+			// recorded after the compression, so undo restores the original file before removing the compressed one
+			return trashFile(journal, action.From, updateProgress)
+		}
+		// keep the extension of the original file
+		to := strings.TrimSuffix(action.To, filepath.Ext(action.To)) + filepath.Ext(action.From)
+		zap.S().Warnf("Failed to compress %v, moving it instead - %v", action.From, err)
+		if existsAsOtherFile(to, action.From) {
+			return errors.New("file already exists " + to)
+		}
+		if err = moveFile(action.From, to, updateProgress); err != nil {
+			return err
+		}
+		return journal.record(PLAN_ACTION_MOVE, action.From, to)
+	case PLAN_ACTION_DELETE:
+		return trashFile(journal, action.From, updateProgress)
+	case PLAN_ACTION_DELETE_EMPTY_FOLDERS:
+		deleted, err := deleteEmptyFolders(action.From)
+		for _, folder := range deleted {
+			if recordErr := journal.record(action.Action, folder, ""); recordErr != nil {
+				return recordErr
+			}
+		}
+		return err
+	}
+	return errors.New("unsupported action [" + action.Action + "]")
+}
+
+// prepareDestination moves the file replaced by an action to the trash, and makes sure no other file is overwritten
+// (like a file created since the plan was made). A move to an identical copy of the file, like the destination of
+// an interrupted move, is completed by moving the original file to the trash.
+func prepareDestination(journal *Journal, action PlanAction, updateProgress db.ProgressUpdater) (bool, error) {
+	if action.Resolution == COLLISION_OVERWRITE && action.Replaces != "" && existsAsOtherFile(action.Replaces, action.From) {
+		if err := trashFile(journal, action.Replaces, updateProgress); err != nil {
+			return false, err
+		}
+	}
+	if !existsAsOtherFile(action.To, action.From) {
+		return false, nil
+	}
+	if action.Action == PLAN_ACTION_MOVE && isSameContent(action.From, action.To) {
+		zap.S().Infof("%v already exists with the same content, removing %v", action.To, action.From)
+		return true, trashFile(journal, action.From, updateProgress)
+	}
+	return false, errors.New("file already exists " + action.To)
+}
+
+// trashFile moves a file to the trash folder of the run, recorded as deleted
+func trashFile(journal *Journal, filePath string, updateProgress db.ProgressUpdater) error {
+	trashPath := journal.trashPath(filePath)
+	if err := os.MkdirAll(filepath.Dir(trashPath), os.ModePerm); err != nil {
+		return err
+	}
+	if err := moveFile(filePath, trashPath, updateProgress); err != nil {
+		return err
+	}
+	return journal.record(PLAN_ACTION_DELETE, filePath, trashPath)
+}
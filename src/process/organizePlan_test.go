@@ -0,0 +1,214 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+func TestPlanExecute(t *testing.T) {
+	folder := t.TempDir()
+	for _, name := range []string{"a.nsp", "b.nsp", "c.nsp", "taken.nsp"} {
+		_ = os.WriteFile(filepath.Join(folder, name), []byte(name), 0644)
+	}
+	gameFolder := filepath.Join(folder, "Game")
+
+	plan := NewPlan()
+	plan.createFolder(gameFolder)
+	plan.createFolder(gameFolder)
+	plan.move(filepath.Join(folder, "a.nsp"), filepath.Join(gameFolder, "Game.nsp"), false)
+	plan.move(filepath.Join(folder, "b.nsp"), filepath.Join(gameFolder, "Game.nsp"), false)
+	plan.move(filepath.Join(folder, "c.nsp"), filepath.Join(folder, "taken.nsp"), false)
+	plan.move(filepath.Join(folder, "taken.nsp"), filepath.Join(folder, "taken.nsp"), false)
+	plan.delete(filepath.Join(folder, "c.nsp"), "old update")
+
+	if len(plan.Actions) != 5 {
+		t.Fatalf("expected 5 actions, got %v", plan)
+	}
+	if collisions := plan.Collisions(); len(collisions) != 2 {
+		t.Fatalf("expected 2 collisions, got %v", collisions)
+	}
+
+	// the plan is executed as exported
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.ExportPlan(planPath); err != nil {
+		t.Fatalf("failed to export plan: %v", err)
+	}
+	plan, err := ReadPlan(planPath)
+	if err != nil {
+		t.Fatalf("failed to read plan: %v", err)
+	}
+	if err = plan.Execute(t.TempDir(), nil); err != nil {
+		t.Fatalf("failed to execute plan: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		filepath.Join(gameFolder, "Game.nsp"): "a.nsp",
+		filepath.Join(folder, "b.nsp"):        "b.nsp",
+		filepath.Join(folder, "taken.nsp"):    "taken.nsp",
+	} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != content {
+			t.Errorf("expected %v to hold %v, got %v (%v)", name, content, string(data), err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(folder, "c.nsp")); err == nil {
+		t.Errorf("expected c.nsp to be deleted")
+	}
+}
+
+func TestReadPlanInvalid(t *testing.T) {
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	_ = os.WriteFile(planPath, []byte(`{"actions":[{"action":"format","from":"/"}]}`), 0644)
+	if _, err := ReadPlan(planPath); err == nil {
+		t.Fatalf("expected an error for an unsupported action")
+	}
+}
+
+func TestPlanCollisionPolicies(t *testing.T) {
+	folder := t.TempDir()
+	for _, name := range []string{"a.nsp", "b.nsp", "c.nsz", "taken.nsp", "game.nsp"} {
+		_ = os.WriteFile(filepath.Join(folder, name), []byte(name), 0644)
+	}
+	a, b, c := filepath.Join(folder, "a.nsp"), filepath.Join(folder, "b.nsp"), filepath.Join(folder, "c.nsz")
+	taken := filepath.Join(folder, "taken.nsp")
+
+	plan := NewPlan()
+	plan.collisionPolicy = settings.COLLISION_POLICY_SUFFIX
+	plan.move(a, taken, false)
+	plan.move(b, taken, false)
+	if plan.Actions[0].To != filepath.Join(folder, "taken (1).nsp") || plan.Actions[1].To != filepath.Join(folder, "taken (2).nsp") {
+		t.Fatalf("expected the files to be renamed with a suffix, got %v", plan)
+	}
+
+	plan = NewPlan()
+	plan.collisionPolicy = settings.COLLISION_POLICY_OVERWRITE_NEWER_VERSION
+	plan.versions = map[string]int{a: 65536, b: 0, taken: 0}
+	plan.move(a, taken, false)
+	plan.move(b, taken, false)
+	if plan.Actions[0].Resolution != COLLISION_OVERWRITE || plan.Actions[0].Replaces != taken || !plan.Actions[1].IsSkipped() {
+		t.Fatalf("expected the newer version to replace the destination only, got %v", plan)
+	}
+
+	plan = NewPlan()
+	plan.collisionPolicy = settings.COLLISION_POLICY_OVERWRITE_COMPRESSED
+	plan.move(c, filepath.Join(folder, "game.nsz"), false)
+	plan.move(b, filepath.Join(folder, "game.nsp"), false)
+	if plan.Actions[0].Resolution != COLLISION_OVERWRITE || plan.Actions[0].Replaces != filepath.Join(folder, "game.nsp") {
+		t.Fatalf("expected the compressed file to replace the uncompressed one, got %v", plan)
+	}
+	if !plan.Actions[1].IsSkipped() {
+		t.Fatalf("expected the uncompressed file to be skipped, got %v", plan)
+	}
+
+	plan = NewPlan()
+	plan.collisionPolicy = settings.COLLISION_POLICY_ASK
+	plan.move(a, taken, false)
+	if pending := plan.PendingCollisions(); len(pending) != 1 || pending[0] != 0 {
+		t.Fatalf("expected a collision to resolve, got %v", pending)
+	}
+	if err := plan.Resolve(0, "rename"); err == nil {
+		t.Fatalf("expected an error for an unsupported resolution")
+	}
+	if err := plan.Resolve(0, COLLISION_OVERWRITE); err != nil {
+		t.Fatalf("failed to resolve collision: %v", err)
+	}
+
+	// the replaced file is moved to the trash, and restored by undo
+	appFolder := t.TempDir()
+	if err := plan.Execute(appFolder, nil); err != nil {
+		t.Fatalf("failed to execute plan: %v", err)
+	}
+	if data, _ := os.ReadFile(taken); string(data) != "a.nsp" {
+		t.Fatalf("expected taken.nsp to be replaced, got %v", string(data))
+	}
+	if _, err := UndoLastRun(appFolder, nil); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	for name, content := range map[string]string{a: "a.nsp", taken: "taken.nsp"} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != content {
+			t.Errorf("expected %v to hold %v, got %v (%v)", name, content, string(data), err)
+		}
+	}
+}
+
+func TestPlanExecuteDoesNotOverwrite(t *testing.T) {
+	folder := t.TempDir()
+	from, to := filepath.Join(folder, "a.nsp"), filepath.Join(folder, "b.nsp")
+	_ = os.WriteFile(from, []byte("a"), 0644)
+
+	plan := NewPlan()
+	plan.move(from, to, false)
+	// created after the plan was made
+	_ = os.WriteFile(to, []byte("b"), 0644)
+	if err := plan.Execute(t.TempDir(), nil); err == nil {
+		t.Fatalf("expected an error when the destination was created since the plan was made")
+	}
+	if data, _ := os.ReadFile(to); string(data) != "b" {
+		t.Fatalf("expected b.nsp to be kept, got %v", string(data))
+	}
+}
+
+func TestPlanMoveToDeletedFile(t *testing.T) {
+	folder := t.TempDir()
+	deleted, from := filepath.Join(folder, "Game [v0].nsp"), filepath.Join(folder, "old.nsz")
+	to := filepath.Join(folder, "Game [v0].nsz")
+	_ = os.WriteFile(deleted, []byte("duplicate"), 0644)
+	_ = os.WriteFile(from, []byte("game"), 0644)
+
+	plan := NewPlan()
+	plan.delete(deleted, "duplicate")
+	organizePlan := NewPlan()
+	organizePlan.Append(plan)
+	organizePlan.move(from, to, false)
+	if collisions := organizePlan.Collisions(); len(collisions) != 0 {
+		t.Fatalf("expected the deleted file to free its destination, got %v", collisions)
+	}
+
+	if err := organizePlan.Execute(t.TempDir(), nil); err != nil {
+		t.Fatalf("failed to execute plan: %v", err)
+	}
+	if data, err := os.ReadFile(to); err != nil || string(data) != "game" {
+		t.Fatalf("expected %v to be moved, got %v (%v)", to, string(data), err)
+	}
+	if _, err := os.Stat(deleted); !os.IsNotExist(err) {
+		t.Fatalf("expected %v to be deleted", deleted)
+	}
+}
+
+func TestPlanMoveToIdenticalFile(t *testing.T) {
+	folder := t.TempDir()
+	from, to, created := filepath.Join(folder, "a.nsp"), filepath.Join(folder, "b.nsp"), filepath.Join(folder, "c.nsp")
+	_ = os.WriteFile(from, []byte("game"), 0644)
+	_ = os.WriteFile(to, []byte("game"), 0644)
+
+	plan := NewPlan()
+	plan.move(from, to, false)
+	if collisions := plan.Collisions(); len(collisions) != 0 {
+		t.Fatalf("expected an identical destination not to collide, got %v", collisions)
+	}
+	plan.move(to, created, false)
+	// created after the plan was made, the move is still completed
+	_ = os.WriteFile(created, []byte("game"), 0644)
+
+	baseFolder := t.TempDir()
+	if err := plan.Execute(baseFolder, nil); err != nil {
+		t.Fatalf("failed to execute plan: %v", err)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Fatalf("expected %v to be removed", from)
+	}
+	if data, err := os.ReadFile(created); err != nil || string(data) != "game" {
+		t.Fatalf("expected %v to be kept, got %v (%v)", created, string(data), err)
+	}
+
+	if _, err := UndoLastRun(baseFolder, nil); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	for _, name := range []string{from, to, created} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != "game" {
+			t.Errorf("expected %v to be restored, got %v (%v)", name, string(data), err)
+		}
+	}
+}
@@ -1,15 +1,20 @@
 package process
 
 import (
+	"errors"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/trembon/switch-library-manager/db"
 	"github.com/trembon/switch-library-manager/settings"
+	"github.com/trembon/switch-library-manager/switchfs"
 	"go.uber.org/zap"
 	"robpike.io/nihongo"
 )
@@ -20,67 +25,57 @@ var (
 	cjk                     = regexp.MustCompile("[\u2f70-\u2FA1\u3040-\u30ff\u3400-\u4dbf\u4e00-\u9fff\uf900-\ufaff\uff66-\uff9f\\p{Katakana}\\p{Hiragana}\\p{Hangul}]")
 )
 
-func DeleteOldUpdates(baseFolder string, localDB *db.LocalSwitchFilesDB, updateProgress db.ProgressUpdater) {
-	i := 0
+// PlanDeleteOldUpdates returns the deletion of the duplicate files and old updates of the local library,
+// followed by the deletion of the folders left empty when enabled in the settings
+func PlanDeleteOldUpdates(baseFolder string, localDB *db.LocalSwitchFilesDB) *Plan {
+	plan := NewPlan()
+	folders := map[string]bool{}
 	for k, v := range localDB.Skipped {
 		switch v.ReasonCode {
-		case db.REASON_DUPLICATE:
-			fileToRemove := filepath.Join(k.BaseFolder, k.FileName)
-			if updateProgress != nil {
-				updateProgress.UpdateProgress(0, 0, "Deleting "+fileToRemove)
-			}
-			zap.S().Infof("Deleting file: %v \n", fileToRemove)
-			err := os.Remove(fileToRemove)
-			if err != nil {
-				zap.S().Errorf("Failed to delete file  %v  [%v]\n", fileToRemove, err)
-				continue
-			}
-			i++
-		case db.REASON_OLD_UPDATE:
-			fileToRemove := filepath.Join(k.BaseFolder, k.FileName)
-			if updateProgress != nil {
-				updateProgress.UpdateProgress(0, 0, "Deleting "+fileToRemove)
-			}
-			zap.S().Infof("Deleting file: %v \n", fileToRemove)
-			err := os.Remove(fileToRemove)
-			if err != nil {
-				zap.S().Errorf("Failed to delete file  %v  [%v]\n", fileToRemove, err)
-				continue
-			}
-			i++
+		case db.REASON_DUPLICATE, db.REASON_OLD_UPDATE:
+			plan.delete(filepath.Join(k.BaseFolder, k.FileName), strings.SplitN(v.ReasonText, "\n", 2)[0])
+			folders[k.BaseFolder] = true
 		}
-
 	}
+	sort.Slice(plan.Actions, func(i, j int) bool {
+		return plan.Actions[i].From < plan.Actions[j].From
+	})
 
-	if i != 0 && settings.ReadSettings(baseFolder).OrganizeOptions.DeleteEmptyFolders {
-		if updateProgress != nil {
-			updateProgress.UpdateProgress(i, i+1, "Deleting empty folders... (can take 1-2min)")
-		}
-		err := deleteEmptyFolders(baseFolder)
-		if err != nil {
-			zap.S().Errorf("Failed to delete empty folders [%v]\n", err)
-		}
-		if updateProgress != nil {
-			updateProgress.UpdateProgress(i+1, i+1, "Deleting empty folders... (can take 1-2min)")
+	if len(folders) != 0 && settings.ReadSettings(baseFolder).OrganizeOptions.DeleteEmptyFolders {
+		for _, folder := range slices.Sorted(maps.Keys(folders)) {
+			plan.deleteEmptyFolders(folder)
 		}
 	}
+	return plan
 }
 
-func OrganizeByFolders(baseFolder string,
+// PlanOrganizeByFolders returns the folders to create and the files to move or rename to organize the
+// local library according to the organize options, without changing anything. The actions are added after
+// the ones of plan (like the deletion of old updates, whose files no longer take their destination), nil for
+// a new plan.
+func PlanOrganizeByFolders(baseFolder string,
 	localDB *db.LocalSwitchFilesDB,
 	titlesDB *db.SwitchTitlesDB,
-	updateProgress db.ProgressUpdater) {
+	plan *Plan,
+	updateProgress db.ProgressUpdater) (*Plan, error) {
 
 	//validate template rules
 	logger := zap.S()
-	options := settings.ReadSettings(baseFolder).OrganizeOptions
+	settingsObj := settings.ReadSettings(baseFolder)
+	options := settingsObj.OrganizeOptions
 	if !IsOptionsValid(options) {
-		logger.Error("the organize options in settings.json are not valid, please check that the template contains file/folder name")
-		return
+		return nil, errors.New("the organize options in settings.json are not valid, please check that the template contains file/folder name")
+	}
+	if plan == nil {
+		plan = NewPlan()
 	}
+	plan.collisionPolicy = options.CollisionPolicy
+	plan.addFileVersions(localDB)
 	i := 0
-	tasksSize := len(localDB.TitlesMap) + 2
-	for k, v := range localDB.TitlesMap {
+	tasksSize := len(localDB.TitlesMap) + 1
+	// sorted, so the same library always gives the same plan
+	for _, k := range slices.Sorted(maps.Keys(localDB.TitlesMap)) {
+		v := localDB.TitlesMap[k]
 		i++
 		if !v.BaseExist && !options.ProcessWhenMissingBaseGame {
 			continue
@@ -91,7 +86,7 @@ func OrganizeByFolders(baseFolder string,
 		}
 
 		title, titleExist := titlesDB.TitlesMap[k]
-		titleName := getTitleName(title, v)
+		titleName := GetTitleName(title, v, settingsObj.PreferredLanguages)
 
 		templateData := map[string]string{}
 
@@ -134,9 +129,7 @@ func OrganizeByFolders(baseFolder string,
 		if options.CreateFolderPerGame {
 			folderToCreate := getFolderName(options, templateData)
 			destinationPath = filepath.Join(baseFolder, folderToCreate)
-			if err := createFolder(destinationPath, logger); err != nil {
-				continue
-			}
+			plan.createFolder(destinationPath)
 		}
 
 		if v.IsSplit {
@@ -151,11 +144,7 @@ func OrganizeByFolders(baseFolder string,
 				if _, err := strconv.Atoi(file.Name()[len(file.Name())-1:]); err == nil {
 					from := filepath.Join(v.File.ExtendedInfo.BaseFolder, file.Name())
 					to := filepath.Join(destinationPath, file.Name())
-					err := moveFile(from, to)
-					if err != nil {
-						logger.Errorf("Failed to move file [%v]\n", err)
-						continue
-					}
+					plan.move(from, to, false)
 				}
 			}
 			continue
@@ -165,7 +154,6 @@ func OrganizeByFolders(baseFolder string,
 		var (
 			from string
 			to   string
-			err  error
 		)
 
 		//process base title
@@ -173,15 +161,12 @@ func OrganizeByFolders(baseFolder string,
 			templateData[settings.TEMPLATE_TYPE] = "BASE"
 			from = filepath.Join(v.File.ExtendedInfo.BaseFolder, v.File.ExtendedInfo.FileName)
 			to = filepath.Join(destinationPath, getFileName(options, v.File.ExtendedInfo.FileName, templateData, 0))
-			err = moveFile(from, to)
-			if err != nil {
-				logger.Errorf("Failed to move file [%v]\n", err)
-				continue
-			}
+			plan.move(from, to, options.CompressFiles)
 		}
 
 		//process updates
-		for update, updateInfo := range v.Updates {
+		for _, update := range slices.Sorted(maps.Keys(v.Updates)) {
+			updateInfo := v.Updates[update]
 			// if the current title is multi content and the update is contained in the main file, skip
 			if v.MultiContent && v.BaseExist && v.File.ExtendedInfo == updateInfo.ExtendedInfo {
 				logger.Infof("Skipping organizing %v update %v, reason: Update is multi-part with main file", titleName, update)
@@ -203,7 +188,7 @@ func OrganizeByFolders(baseFolder string,
 			if options.CreateFolderPerGame {
 				if options.UpdatesFolder != "" {
 					to = filepath.Join(destinationPath, options.UpdatesFolder)
-					createFolder(to, logger)
+					plan.createFolder(to)
 					to = filepath.Join(to, getFileName(options, updateInfo.ExtendedInfo.FileName, templateData, 0))
 				} else {
 					to = filepath.Join(destinationPath, getFileName(options, updateInfo.ExtendedInfo.FileName, templateData, 0))
@@ -215,16 +200,13 @@ func OrganizeByFolders(baseFolder string,
 					to = filepath.Join(updateInfo.ExtendedInfo.BaseFolder, getFileName(options, updateInfo.ExtendedInfo.FileName, templateData, 0))
 				}
 			}
-			err := moveFile(from, to)
-			if err != nil {
-				logger.Errorf("Failed to move file [%v]\n", err)
-				continue
-			}
+			plan.move(from, to, options.CompressFiles)
 		}
 
 		//process DLC
 		existingDlcs := map[string]string{}
-		for id, dlc := range v.Dlc {
+		for _, id := range slices.Sorted(maps.Keys(v.Dlc)) {
+			dlc := v.Dlc[id]
 			// if the current title is multi content and the dlc is contained in the main file, skip
 			if v.MultiContent && v.BaseExist && v.File.ExtendedInfo == dlc.ExtendedInfo {
 				logger.Infof("Skipping organizing %v dlc %v, reason: DLC is multi-part with main file", titleName, dlc)
@@ -244,7 +226,7 @@ func OrganizeByFolders(baseFolder string,
 				if options.CreateFolderPerGame {
 					if options.DlcFolder != "" {
 						to = filepath.Join(destinationPath, options.DlcFolder)
-						createFolder(to, logger)
+						plan.createFolder(to)
 						to = filepath.Join(to, getFileName(options, dlc.ExtendedInfo.FileName, templateData, dlcNameTry))
 					} else {
 						to = filepath.Join(destinationPath, getFileName(options, dlc.ExtendedInfo.FileName, templateData, dlcNameTry))
@@ -273,33 +255,17 @@ func OrganizeByFolders(baseFolder string,
 			}
 			existingDlcs[to] = id
 
-			err = moveFile(from, to)
-			if err != nil {
-				logger.Errorf("Failed to move file [%v]\n", err)
-				continue
-			}
+			plan.move(from, to, options.CompressFiles)
 		}
 	}
 
 	if options.DeleteEmptyFolders {
-		if updateProgress != nil {
-			i += 1
-			updateProgress.UpdateProgress(i, tasksSize, "Deleting empty folders... (can take 1-2min)")
-		}
-		err := deleteEmptyFolders(baseFolder)
-		if err != nil {
-			zap.S().Errorf("Failed to delete empty folders [%v]\n", err)
-		}
-		if updateProgress != nil {
-			i += 1
-			updateProgress.UpdateProgress(i, tasksSize, "Done")
-		}
-	} else {
-		if updateProgress != nil {
-			i += 2
-			updateProgress.UpdateProgress(i, tasksSize, "Done")
-		}
+		plan.deleteEmptyFolders(baseFolder)
+	}
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(tasksSize, tasksSize, "Done")
 	}
+	return plan, nil
 }
 
 func IsOptionsValid(options settings.OrganizeOptions) bool {
@@ -342,7 +308,21 @@ func getDlcName(switchTitle *db.SwitchTitle, file db.SwitchFileInfo) string {
 	return ""
 }
 
-func getTitleName(switchTitle *db.SwitchTitle, v *db.SwitchGameFiles) string {
+// GetTitleName returns the display name of a title. The NACP name in the first of the preferred languages
+// is used when there are preferred languages, otherwise the name of the titles db is preferred over the
+// AmericanEnglish NACP name.
+func GetTitleName(switchTitle *db.SwitchTitle, v *db.SwitchGameFiles, preferredLanguages []string) string {
+	var nacp *switchfs.Nacp
+	if v != nil && v.File.Metadata != nil {
+		nacp = v.File.Metadata.Ncap
+	}
+
+	if nacp != nil && len(preferredLanguages) != 0 {
+		if name := nacp.GetTitleName(preferredLanguages); name != "" {
+			return name
+		}
+	}
+
 	// Check if switchTitle is not nil and contains a name
 	if switchTitle != nil && switchTitle.Attributes.Name != "" {
 		res := cjk.FindAllString(switchTitle.Attributes.Name, -1)
@@ -351,11 +331,9 @@ func getTitleName(switchTitle *db.SwitchTitle, v *db.SwitchGameFiles) string {
 		}
 	}
 
-	// Check if v and its Metadata are present
-	if v != nil && v.File.Metadata != nil && v.File.Metadata.Ncap != nil {
-		// Check if the title name exists
-		name := v.File.Metadata.Ncap.TitleName["AmericanEnglish"].Title
-		if name != "" {
+	// Check if the title name exists
+	if nacp != nil {
+		if name := nacp.GetTitleName([]string{"AmericanEnglish"}); name != "" {
 			return name
 		}
 	}
@@ -365,6 +343,11 @@ func getTitleName(switchTitle *db.SwitchTitle, v *db.SwitchGameFiles) string {
 		return db.ParseTitleNameFromFileName(v.File.ExtendedInfo.FileName)
 	}
 
+	// a title missing from the library, only the titles db name is known
+	if switchTitle != nil && switchTitle.Attributes.Name != "" {
+		return switchTitle.Attributes.Name
+	}
+
 	// Default return if no valid name is found
 	return "Unknown Title"
 }
@@ -383,14 +366,6 @@ func getFileName(options settings.OrganizeOptions, originalName string, template
 	return result + ext
 }
 
-func moveFile(from string, to string) error {
-	if from == to {
-		return nil
-	}
-	err := os.Rename(from, to)
-	return err
-}
-
 func applyTemplate(templateData map[string]string, useSafeNames bool, template string, nameTry int) string {
 	result := strings.Replace(template, "{"+settings.TEMPLATE_TITLE_NAME+"}", templateData[settings.TEMPLATE_TITLE_NAME], 1)
 	result = strings.Replace(result, "{"+settings.TEMPLATE_TITLE_ID+"}", strings.ToUpper(templateData[settings.TEMPLATE_TITLE_ID]), 1)
@@ -433,46 +408,38 @@ func applyTemplate(templateData map[string]string, useSafeNames bool, template s
 	return folderIllegalCharsRegex.ReplaceAllString(result, "")
 }
 
-func createFolder(path string, logger *zap.SugaredLogger) error {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err = os.Mkdir(path, os.ModePerm)
-		if err != nil {
-			logger.Errorf("Failed to create folder %v - %v\n", path, err)
-			return err
-		}
-	}
-	return nil
-}
-
-func deleteEmptyFolders(path string) error {
+// deleteEmptyFolders deletes the empty folders in path, and returns the deleted folders
+func deleteEmptyFolders(path string) ([]string, error) {
+	var deleted []string
 	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			zap.S().Error("Error while deleting empty folders", err)
 		}
 		if info != nil && info.IsDir() {
-			err = deleteEmptyFolder(path)
+			removed, err := deleteEmptyFolder(path)
 			if err != nil {
 				zap.S().Error("Error while deleting empty folders", err)
 			}
+			if removed {
+				deleted = append(deleted, path)
+			}
 		}
 
 		return nil
 	})
-	return err
+	return deleted, err
 }
 
-func deleteEmptyFolder(path string) error {
+func deleteEmptyFolder(path string) (bool, error) {
 	files, err := os.ReadDir(path)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if len(files) != 0 {
-		return nil
+		return false, nil
 	}
 
 	zap.S().Infof("\nDeleting empty folder [%v]", path)
-	_ = os.Remove(path)
-
-	return nil
+	return os.Remove(path) == nil, nil
 }
@@ -4,6 +4,9 @@ import (
 	"robpike.io/nihongo"
 	"strings"
 	"testing"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/switchfs"
 )
 
 //var folderIllegalCharsRegex = regexp.MustCompile(`[./\\?%*:;=|"<>]`)
@@ -15,3 +18,45 @@ func TestRename(t *testing.T) {
 	name = strings.Join(safe, "")
 	name = nihongo.RomajiString(name)
 }
+
+func TestGetTitleName(t *testing.T) {
+	nacp := &switchfs.Nacp{TitleName: map[string]switchfs.NacpTitle{
+		"AmericanEnglish": {Title: "Game"},
+		"German":          {Title: "Spiel"},
+		"BritishEnglish":  {Title: ""},
+	}}
+	v := &db.SwitchGameFiles{File: db.SwitchFileInfo{
+		ExtendedInfo: db.ExtendedFileInfo{FileName: "File Name [0100000000010000][v0].nsp"},
+		Metadata:     &switchfs.ContentMetaAttributes{Ncap: nacp},
+	}}
+	title := &db.SwitchTitle{Attributes: db.TitleAttributes{Name: "Titles DB name"}}
+
+	tests := []struct {
+		title     *db.SwitchTitle
+		languages []string
+		expected  string
+	}{
+		{title, nil, "Titles DB name"},
+		{nil, nil, "Game"},
+		{title, []string{"BritishEnglish", "German"}, "Spiel"},
+		{title, []string{"French"}, "Titles DB name"},
+		{nil, []string{"French"}, "Game"},
+	}
+	for _, test := range tests {
+		if name := GetTitleName(test.title, v, test.languages); name != test.expected {
+			t.Errorf("expected %v for languages %v, got %v", test.expected, test.languages, name)
+		}
+	}
+
+	// a title missing from the library
+	cjkTitle := &db.SwitchTitle{Attributes: db.TitleAttributes{Name: "ゲーム"}}
+	if name := GetTitleName(cjkTitle, nil, []string{"German"}); name != "ゲーム" {
+		t.Errorf("expected the titles DB name, got %v", name)
+	}
+
+	v.File.Metadata.Ncap = nil
+	expected := db.ParseTitleNameFromFileName(v.File.ExtendedInfo.FileName)
+	if name := GetTitleName(nil, v, []string{"German"}); name != expected {
+		t.Errorf("expected %v, got %v", expected, name)
+	}
+}
@@ -0,0 +1,22 @@
+package process
+
+import (
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/switchfs"
+)
+
+// GetTitleSaveDataSize returns the save data size of a title for a single user account,
+// the latest update is used as it can change the save data size of the base game
+func GetTitleSaveDataSize(gameFiles *db.SwitchGameFiles) int64 {
+	var nacp *switchfs.Nacp
+	if gameFiles.BaseExist && gameFiles.File.Metadata != nil {
+		nacp = gameFiles.File.Metadata.Ncap
+	}
+	if update, ok := gameFiles.Updates[gameFiles.LatestUpdate]; ok && update.Metadata != nil && update.Metadata.Ncap != nil {
+		nacp = update.Metadata.Ncap
+	}
+	if nacp == nil {
+		return 0
+	}
+	return nacp.TotalSaveDataSize()
+}
@@ -0,0 +1,139 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/settings"
+	"github.com/trembon/switch-library-manager/switchfs"
+	"go.uber.org/zap"
+)
+
+// SplitTitle extracts the base, updates and DLC stored in the multi-content file of a title into
+// standalone NSP (or NSZ) files next to it, named using the file name template of the organize options.
+// The multi-content file is moved to the trash afterwards when deleteSource is set. The created paths are returned.
+func SplitTitle(baseFolder string, localDB *db.LocalSwitchFilesDB, titlesDB *db.SwitchTitlesDB, titleId string,
+	deleteSource bool, updateProgress db.ProgressUpdater) ([]string, error) {
+	if len(titleId) != 16 {
+		return nil, errors.New("invalid title id " + titleId)
+	}
+	idPrefix := db.GetTitlePrefix(strings.ToLower(titleId))
+	v, ok := localDB.TitlesMap[idPrefix]
+	if !ok || !v.BaseExist || !v.MultiContent {
+		return nil, errors.New(titleId + " is not a multi-content file in the local library")
+	}
+	if v.IsSplit {
+		return nil, errors.New("split files cannot be extracted")
+	}
+
+	settingsObj := settings.ReadSettings(baseFolder)
+	options := settingsObj.OrganizeOptions
+	if options.FileNameTemplate == "" {
+		return nil, errors.New("file name template cannot be empty")
+	}
+
+	source := v.File.ExtendedInfo
+	ext := ".nsp"
+	if db.IsCompressed(source.FileName) {
+		ext = ".nsz"
+	}
+
+	title := titlesDB.TitlesMap[idPrefix]
+	titleName := GetTitleName(title, v, settingsObj.PreferredLanguages)
+	region := ""
+	if title != nil {
+		region = title.Attributes.Region
+	}
+
+	outputPaths := map[string]string{}
+	addOutput := func(file db.SwitchFileInfo, titleType string, version int, dlcName string) {
+		if file.ExtendedInfo != source || file.Metadata == nil {
+			return
+		}
+		templateData := map[string]string{
+			settings.TEMPLATE_TITLE_ID:    file.Metadata.TitleId,
+			settings.TEMPLATE_TITLE_NAME:  titleName,
+			settings.TEMPLATE_TYPE:        titleType,
+			settings.TEMPLATE_VERSION:     strconv.Itoa(version),
+			settings.TEMPLATE_VERSION_TXT: "",
+			settings.TEMPLATE_REGION:      region,
+			settings.TEMPLATE_DLC_NAME:    dlcName,
+		}
+		if file.Metadata.Ncap != nil {
+			templateData[settings.TEMPLATE_VERSION_TXT] = file.Metadata.Ncap.DisplayVersion
+		}
+		for nameTry := 0; ; nameTry++ {
+			outputPath := filepath.Join(source.BaseFolder, applyTemplate(templateData, options.SwitchSafeFileNames, options.FileNameTemplate, nameTry)+ext)
+			if _, err := os.Stat(outputPath); err == nil || isOutputUsed(outputPaths, outputPath) {
+				continue
+			}
+			outputPaths[file.Metadata.TitleId] = outputPath
+			return
+		}
+	}
+
+	addOutput(v.File, "BASE", 0, "")
+	for update, updateInfo := range v.Updates {
+		addOutput(updateInfo, "UPD", update, "")
+	}
+	for _, dlc := range v.Dlc {
+		version := 0
+		if dlc.Metadata != nil {
+			version = dlc.Metadata.Version
+		}
+		addOutput(dlc, "DLC", version, getDlcName(title, dlc))
+	}
+	if len(outputPaths) < 2 {
+		return nil, errors.New(titleId + " does not contain multiple titles")
+	}
+
+	filePath := filepath.Join(source.BaseFolder, source.FileName)
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(0, 1, "Splitting "+filePath)
+	}
+	zap.S().Infof("Splitting %v into %v", filePath, outputPaths)
+	err := switchfs.SplitFile(filePath, outputPaths)
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(1, 1, "Split completed")
+	}
+
+	var created []string
+	for _, outputPath := range outputPaths {
+		if _, statErr := os.Stat(outputPath); statErr == nil {
+			created = append(created, outputPath)
+		}
+	}
+	if err != nil {
+		return created, err
+	}
+	if len(created) != len(outputPaths) {
+		return created, errors.New("not all titles were found in " + filePath)
+	}
+
+	if deleteSource {
+		// moved to the trash, like the files deleted when organizing, it can be restored with undo
+		journal, err := newJournal(baseFolder)
+		if err != nil {
+			return created, err
+		}
+		defer journal.close()
+		zap.S().Infof("Deleting file: %v \n", filePath)
+		if err = trashFile(journal, filePath, updateProgress); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+func isOutputUsed(outputPaths map[string]string, outputPath string) bool {
+	for _, path := range outputPaths {
+		if path == outputPath {
+			return true
+		}
+	}
+	return false
+}
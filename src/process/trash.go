@@ -0,0 +1,162 @@
+package process
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trembon/switch-library-manager/settings"
+	"go.uber.org/zap"
+)
+
+const (
+	PURGE_EXPIRED = "expired" // runs past the retention rules of the settings
+	PURGE_ALL     = "all"
+)
+
+// TrashInfo describes the content of the trash folder, each run deleting files has its own folder
+type TrashInfo struct {
+	Folder string    `json:"folder"`
+	Runs   int       `json:"runs"`
+	Size   int64     `json:"size"`
+	Oldest time.Time `json:"oldest,omitempty"`
+}
+
+type trashRun struct {
+	path    string
+	created time.Time
+	size    int64
+}
+
+// TrashFolder returns the folder deleted files are moved to, the trash folder of the settings
+// or the trash folder next to the settings
+func TrashFolder(baseFolder string) string {
+	if folder := settings.ReadSettings(baseFolder).TrashFolder; folder != "" {
+		return folder
+	}
+	return filepath.Join(baseFolder, TRASH_FOLDER)
+}
+
+// trashRelativePath returns the path of a deleted file inside the folder of its run, the original path is
+// kept so files with the same name from different folders do not collide
+func trashRelativePath(filePath string) string {
+	volume := filepath.VolumeName(filePath)
+	rest := strings.TrimLeft(filePath[len(volume):], `\/`)
+	// like C: or \\server\share on windows
+	volume = strings.Trim(strings.NewReplacer(":", "", `\`, "_", "/", "_").Replace(volume), "_")
+	return filepath.Join(volume, rest)
+}
+
+// ReadTrashInfo returns the number of runs and the size of the files in the trash folder
+func ReadTrashInfo(baseFolder string) (*TrashInfo, error) {
+	info := &TrashInfo{Folder: TrashFolder(baseFolder)}
+	runs, err := readTrashRuns(info.Folder)
+	if err != nil {
+		return nil, err
+	}
+	info.Runs = len(runs)
+	for _, run := range runs {
+		info.Size += run.size
+	}
+	if len(runs) != 0 {
+		info.Oldest = runs[0].created
+	}
+	return info, nil
+}
+
+// readTrashRuns returns the runs of the trash folder, oldest first
+func readTrashRuns(folder string) ([]trashRun, error) {
+	entries, err := os.ReadDir(folder)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var runs []trashRun
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		run := trashRun{path: filepath.Join(folder, entry.Name())}
+		// the folder is named after the operation id of the run
+		run.created, err = time.ParseInLocation(operationIdLayout, entry.Name(), time.Local)
+		if err != nil {
+			if fileInfo, statErr := entry.Info(); statErr == nil {
+				run.created = fileInfo.ModTime()
+			}
+		}
+		_ = filepath.WalkDir(run.path, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				if fileInfo, err := d.Info(); err == nil {
+					run.size += fileInfo.Size()
+				}
+			}
+			return nil
+		})
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].created.Before(runs[j].created)
+	})
+	return runs, nil
+}
+
+// PurgeTrash permanently deletes the runs of the trash folder, all of them or the ones past the retention
+// rules of the settings: older than trash_max_age_days, and the oldest ones while the trash is larger than
+// trash_max_size_gb. The purged runs can no longer be undone, the number of purged runs and their size are returned.
+func PurgeTrash(baseFolder string, mode string) (int, int64, error) {
+	if mode != PURGE_ALL && mode != PURGE_EXPIRED {
+		return 0, 0, errors.New("unsupported purge mode [" + mode + "], use " + PURGE_EXPIRED + " or " + PURGE_ALL)
+	}
+	settingsObj := settings.ReadSettings(baseFolder)
+	runs, err := readTrashRuns(TrashFolder(baseFolder))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var totalSize int64
+	for _, run := range runs {
+		totalSize += run.size
+	}
+	maxSize := int64(settingsObj.TrashMaxSizeGB) * 1024 * 1024 * 1024
+	maxAge := time.Duration(settingsObj.TrashMaxAgeDays) * 24 * time.Hour
+
+	purged := 0
+	var purgedSize int64
+	for _, run := range runs {
+		expired := mode == PURGE_ALL ||
+			(maxAge > 0 && time.Since(run.created) > maxAge) ||
+			(maxSize > 0 && totalSize > maxSize)
+		if !expired {
+			// the following runs are more recent, and the trash is small enough
+			break
+		}
+		zap.S().Infof("Purging trash %v", run.path)
+		if err = os.RemoveAll(run.path); err != nil {
+			return purged, purgedSize, err
+		}
+		purged++
+		purgedSize += run.size
+		totalSize -= run.size
+	}
+	return purged, purgedSize, nil
+}
+
+// removeEmptyTrashRun removes the folder of a run once all of its files were restored
+func removeEmptyTrashRun(path string) {
+	hasFiles := false
+	_ = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			hasFiles = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if !hasFiles {
+		_ = os.RemoveAll(path)
+	}
+}
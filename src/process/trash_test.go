@@ -0,0 +1,77 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	appFolder := t.TempDir()
+	settingsObj := settings.ReadSettings(appFolder)
+	maxAge, maxSize := settingsObj.TrashMaxAgeDays, settingsObj.TrashMaxSizeGB
+	defer func() {
+		settingsObj.TrashMaxAgeDays, settingsObj.TrashMaxSizeGB = maxAge, maxSize
+	}()
+
+	trashFolder := TrashFolder(appFolder)
+	for _, created := range []time.Time{time.Now().AddDate(0, 0, -40), time.Now().AddDate(0, 0, -20), time.Now()} {
+		run := filepath.Join(trashFolder, created.Format(operationIdLayout), "games")
+		_ = os.MkdirAll(run, os.ModePerm)
+		_ = os.WriteFile(filepath.Join(run, "update.nsp"), []byte("update"), 0644)
+	}
+
+	if _, _, err := PurgeTrash(appFolder, "unknown"); err == nil {
+		t.Fatalf("expected an error for an unsupported purge mode")
+	}
+
+	settingsObj.TrashMaxAgeDays, settingsObj.TrashMaxSizeGB = 30, 0
+	if purged, size, err := PurgeTrash(appFolder, PURGE_EXPIRED); err != nil || purged != 1 || size != 6 {
+		t.Fatalf("expected the run older than 30 days to be purged, got %v runs of %v bytes (%v)", purged, size, err)
+	}
+	info, err := ReadTrashInfo(appFolder)
+	if err != nil || info.Runs != 2 || info.Size != 12 {
+		t.Fatalf("expected 2 runs of 12 bytes left, got %+v (%v)", info, err)
+	}
+
+	settingsObj.TrashMaxAgeDays = 0
+	if purged, _, _ := PurgeTrash(appFolder, PURGE_EXPIRED); purged != 0 {
+		t.Fatalf("expected nothing to be purged without retention rules, got %v runs", purged)
+	}
+	if purged, _, _ := PurgeTrash(appFolder, PURGE_ALL); purged != 2 {
+		t.Fatalf("expected all the runs to be purged, got %v runs", purged)
+	}
+}
+
+func TestDeleteKeepsOriginalPath(t *testing.T) {
+	appFolder := t.TempDir()
+	folder := t.TempDir()
+	filePath := filepath.Join(folder, "Game", "update.nsp")
+	_ = os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	_ = os.WriteFile(filePath, []byte("update"), 0644)
+
+	plan := NewPlan()
+	plan.delete(filePath, "old update")
+	if err := plan.Execute(appFolder, nil); err != nil {
+		t.Fatalf("failed to execute plan: %v", err)
+	}
+	info, _ := LastJournal(appFolder)
+	trashPath := filepath.Join(TrashFolder(appFolder), info.OperationId, trashRelativePath(filePath))
+	if !strings.HasSuffix(trashPath, filepath.Join("Game", "update.nsp")) {
+		t.Errorf("expected the original path to be kept, got %v", trashPath)
+	}
+	if data, err := os.ReadFile(trashPath); err != nil || string(data) != "update" {
+		t.Fatalf("expected the file to be moved to %v (%v)", trashPath, err)
+	}
+
+	if _, err := UndoLastRun(appFolder, nil); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(TrashFolder(appFolder), info.OperationId)); err == nil {
+		t.Errorf("expected the folder of the run to be removed once restored")
+	}
+}
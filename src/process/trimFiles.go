@@ -0,0 +1,113 @@
+package process
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/switchfs"
+	"go.uber.org/zap"
+)
+
+// XciTrimSummary counts the trimmed and untrimmed XCI files of the library,
+// along with the space trimming the untrimmed files would save.
+func XciTrimSummary(localDB *db.LocalSwitchFilesDB) (int, int, int64) {
+	files, _ := getTitleFiles(localDB, "")
+	trimmed, untrimmed := 0, 0
+	savings := int64(0)
+	for _, file := range files {
+		xciInfo := getXciInfo(file)
+		if xciInfo == nil {
+			continue
+		}
+		if xciInfo.IsTrimmed() {
+			trimmed++
+		} else {
+			untrimmed++
+			savings += xciInfo.TrimSavings()
+		}
+	}
+	return trimmed, untrimmed, savings
+}
+
+// TrimTitle removes the padding of the untrimmed XCI files of a title, an empty titleId
+// trims every XCI file in the library. The number of trimmed files and freed bytes are returned.
+func TrimTitle(localDB *db.LocalSwitchFilesDB, titleId string, updateProgress db.ProgressUpdater) (int, int64, error) {
+	filePaths, err := getXciFiles(localDB, titleId, func(xciInfo *switchfs.XciInfo) bool {
+		return !xciInfo.IsTrimmed()
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	savings := int64(0)
+	count, err := processXciFiles(filePaths, "Trimming ", updateProgress, func(filePath string) error {
+		saved, err := switchfs.TrimXci(filePath)
+		savings += saved
+		return err
+	})
+	return count, savings, err
+}
+
+// UntrimTitle pads the trimmed XCI files of a title back to the size of their gamecard,
+// an empty titleId untrims every XCI file in the library. The number of untrimmed files is returned.
+func UntrimTitle(localDB *db.LocalSwitchFilesDB, titleId string, updateProgress db.ProgressUpdater) (int, error) {
+	filePaths, err := getXciFiles(localDB, titleId, func(xciInfo *switchfs.XciInfo) bool {
+		return xciInfo.CartridgeSize != 0 && xciInfo.FileSize < xciInfo.CartridgeSize
+	})
+	if err != nil {
+		return 0, err
+	}
+	return processXciFiles(filePaths, "Untrimming ", updateProgress, switchfs.UntrimXci)
+}
+
+func getXciInfo(file db.SwitchFileInfo) *switchfs.XciInfo {
+	if file.Metadata == nil || file.Metadata.Xci == nil || !strings.HasSuffix(strings.ToLower(file.ExtendedInfo.FileName), ".xci") {
+		return nil
+	}
+	return file.Metadata.Xci
+}
+
+func getXciFiles(localDB *db.LocalSwitchFilesDB, titleId string, filter func(xciInfo *switchfs.XciInfo) bool) ([]string, error) {
+	files, err := getTitleFiles(localDB, titleId)
+	if err != nil {
+		return nil, err
+	}
+	var filePaths []string
+	for file, info := range files {
+		if xciInfo := getXciInfo(info); xciInfo != nil && filter(xciInfo) {
+			filePaths = append(filePaths, filepath.Join(file.BaseFolder, file.FileName))
+		}
+	}
+	if len(filePaths) == 0 {
+		return nil, errors.New("no matching XCI files found")
+	}
+	return filePaths, nil
+}
+
+func processXciFiles(filePaths []string, action string, updateProgress db.ProgressUpdater, process func(filePath string) error) (int, error) {
+	count := 0
+	var failed []string
+	for i, filePath := range filePaths {
+		if updateProgress != nil {
+			updateProgress.UpdateProgress(i, len(filePaths), action+filepath.Base(filePath))
+		}
+		zap.S().Infof("%v%v", action, filePath)
+		err := process(filePath)
+		if err != nil {
+			zap.S().Errorf("Failed %v%v [%v]", strings.ToLower(action), filePath, err)
+			failed = append(failed, filepath.Base(filePath)+" - "+err.Error())
+			continue
+		}
+		count++
+	}
+	if updateProgress != nil {
+		updateProgress.UpdateProgress(len(filePaths), len(filePaths), action+"completed")
+	}
+
+	if len(failed) != 0 {
+		return count, errors.New("failed " + strings.ToLower(action) + "files:\n" + strings.Join(failed, "\n"))
+	}
+	return count, nil
+}
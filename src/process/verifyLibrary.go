@@ -0,0 +1,78 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/db"
+	"github.com/trembon/switch-library-manager/settings"
+	"github.com/trembon/switch-library-manager/switchfs"
+	"go.uber.org/zap"
+)
+
+// VerifyLibrary checks the SHA-256 of every NCA in the local library files against their cnmt,
+// corrupted or truncated files are added to the skipped files of the local db, which are then persisted
+// so they are kept by the following (cached or incremental) scans.
+func VerifyLibrary(localDbManager *db.LocalSwitchDBManager, localDB *db.LocalSwitchFilesDB, updateProgress db.ProgressUpdater) (int, error) {
+	keys, _ := settings.SwitchKeys()
+	if keys == nil || keys.GetKey("header_key") == "" {
+		return 0, errors.New("verifying files requires prod.keys")
+	}
+
+	files := map[db.ExtendedFileInfo]struct{}{}
+	for _, v := range localDB.TitlesMap {
+		if v.BaseExist {
+			files[v.File.ExtendedInfo] = struct{}{}
+		}
+		for _, update := range v.Updates {
+			files[update.ExtendedInfo] = struct{}{}
+		}
+		for _, dlc := range v.Dlc {
+			files[dlc.ExtendedInfo] = struct{}{}
+		}
+	}
+
+	i := 0
+	corruptedFiles := 0
+	changed := false
+	for file := range files {
+		i++
+		if updateProgress != nil {
+			updateProgress.UpdateProgress(i, len(files), "Verifying "+file.FileName)
+		}
+
+		filePath := filepath.Join(file.BaseFolder, file.FileName)
+		corrupted, err := switchfs.VerifyContent(filePath)
+		if errors.Is(err, switchfs.ErrMissingKey) || os.IsNotExist(err) {
+			zap.S().Warnf("Unable to verify file %v - %v", filePath, err)
+			continue
+		} else if err != nil {
+			// the partitions or the meta NCA can not be read
+			zap.S().Errorf("Corrupted file %v [%v]", filePath, err)
+			localDB.Skipped[file] = db.SkippedFile{ReasonCode: db.REASON_CORRUPTED_FILE, ReasonText: "Corrupted or truncated file\n" + err.Error()}
+			corruptedFiles++
+			continue
+		}
+		if len(corrupted) == 0 {
+			if skipped, ok := localDB.Skipped[file]; ok && skipped.ReasonCode == db.REASON_CORRUPTED_FILE {
+				delete(localDB.Skipped, file)
+				changed = true
+			}
+			continue
+		}
+
+		details := make([]string, len(corrupted))
+		for j, c := range corrupted {
+			details[j] = c.String()
+		}
+		zap.S().Errorf("Corrupted file %v [%v]", filePath, strings.Join(details, ", "))
+		localDB.Skipped[file] = db.SkippedFile{ReasonCode: db.REASON_CORRUPTED_FILE, ReasonText: "Corrupted or truncated file\n" + strings.Join(details, "\n")}
+		corruptedFiles++
+	}
+	if corruptedFiles == 0 && !changed {
+		return 0, nil
+	}
+	return corruptedFiles, localDbManager.SaveSkippedFiles(localDB)
+}
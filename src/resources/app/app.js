@@ -88,6 +88,16 @@ $(function () {
             state.keys = message
         });
 
+        sendMessage("keysReport", "", function (message) {
+            state.keysReport = message ? JSON.parse(message) : undefined
+        });
+
+        let loadTitlesSnapshot = function () {
+            sendMessage("titlesSnapshot", "", function (message) {
+                state.titlesSnapshot = message ? JSON.parse(message) : undefined
+            });
+        };
+
         sendMessage("checkUpdate", "", function (message) {
             if (message === "false"){
                 return
@@ -103,9 +113,10 @@ $(function () {
         });
 
         $(".progress-container").show();
-        $(".progress-type").text("Downloading latest Switch titles/versions ...");
+        $(".progress-type").text(state.settings && state.settings.offline_mode ? "Loading Switch titles/versions snapshot ..." : "Downloading latest Switch titles/versions ...");
 
         sendMessage("updateDB", "", function (message) {
+            loadTitlesSnapshot();
             scanLocalFolder();
         });
 
@@ -134,6 +145,16 @@ $(function () {
                 state.library = JSON.parse(message.payload);
                 loadTab("#library")
             }
+            else if (message.name === "libraryChanged") {
+                state.library = JSON.parse(message.payload);
+                state.updates = undefined;
+                state.dlc = undefined;
+                state.missingGames = undefined;
+                let activeTab = $("#tab_btns a.active").attr('href');
+                if (activeTab !== "#settings" && activeTab !== "#organize") {
+                    loadTab(activeTab || "#library");
+                }
+            }
             else if (message.name === "missingGames") {
                 state.missingGames = JSON.parse(message.payload);
                 loadTab("#missing")
@@ -218,13 +239,25 @@ $(function () {
             if (target === "#settings") {
                 let settingsHtml = $(target + "Template").render({
                     settings: state.settings,
+                    keysReport: state.keysReport,
+                    titlesSnapshot: state.titlesSnapshot ? Object.assign({}, state.titlesSnapshot, {
+                        created_date: new Date(state.titlesSnapshot.created).toLocaleString(),
+                        age_days: Math.floor((Date.now() - new Date(state.titlesSnapshot.created)) / (24 * 60 * 60 * 1000))
+                    }) : undefined,
                     ignore_update_title_ids_str: state.settings.ignore_update_title_ids ? state.settings.ignore_update_title_ids.join('\n') : "",
                     ignore_dlc_title_ids_str: state.settings.ignore_dlc_title_ids ? state.settings.ignore_dlc_title_ids.join('\n') : ""
                 });
                 $(target).html(settingsHtml);
             } else if (target === "#organize") {
-                let html = $(target + "Template").render({folder: state.settings.folder,settings:state.settings})
-                $(target).html(html);
+                sendMessage("trashInfo", "", (r => {
+                    const trash = r ? JSON.parse(r) : undefined;
+                    let html = $(target + "Template").render({
+                        folder: state.settings.folder,
+                        settings: state.settings,
+                        trash: trash ? Object.assign({}, trash, {size_mb: Math.round(trash.size / (1024 * 1024))}) : undefined
+                    })
+                    $(target).html(html);
+                }));
             } else if (target === "#updates") {
                 if (state.settings.folder && !state.library){
                     return
@@ -349,6 +382,11 @@ $(function () {
                                         bg = isDark ? "rgba(209, 52, 56, 0.2)" : "rgba(232, 17, 35, 0.1)";
                                         color = isDark ? "#FF99A4" : "#E81123"; // Fluent Red
                                         break;
+                                    case 6:
+                                        text = "Corrupted";
+                                        bg = isDark ? "rgba(209, 52, 56, 0.2)" : "rgba(232, 17, 35, 0.1)";
+                                        color = isDark ? "#FF99A4" : "#E81123"; // Fluent Red
+                                        break;
                                 }
                                 return `<div style="background-color: ${bg}; color: ${color}; font-size: 12px; font-weight: 600; padding: 4px 8px; border-radius: 12px; display: inline-block; line-height: 1;">${text}</div>`;
                             }},
@@ -381,6 +419,10 @@ $(function () {
                         library: state.library ? state.library.library_data : [] ,
                         num_skipped:state.library ? (state.library.issues ? state.library.issues.length : 0) : 0,
                         num_files:state.library ? state.library.num_files : 0,
+                        untrimmed_xci:state.library ? state.library.untrimmed_xci : 0,
+                        trim_savings:state.library ? (state.library.trim_savings / (1024 * 1024 * 1024)).toFixed(2) : 0,
+                        needs_newer_firmware:state.library ? state.library.library_data.filter(t => t.needsNewerFirmware).length : 0,
+                        target_firmware:state.settings.target_firmware,
                         keys:state.keys,
                         scanFolders:state.settings.scan_folders
                     })
@@ -401,11 +443,42 @@ $(function () {
                             {title: "Type", headerSort:true, field: "type"},
                             {title: "Update", headerSort:false, field: "update"},
                             {title: "Version", headerSort:false, field: "version"},
+                            {title: "Min. FW", headerSort:false, field: "requiredFirmware", formatter:function(cell){
+                                    const data = cell.getData();
+                                    return data.needsNewerFirmware ? `<strong style="color:#E81123">${cell.getValue()}</strong>` : cell.getValue();
+                                }
+                            },
+                            {title: "Key gen", headerSort:true, field: "keyGeneration", hozAlign: "right", sorter: "number"},
+                            {title: "Save data", headerSort:true, field: "saveDataSize", hozAlign: "right", sorter: "number", formatter:function(cell){
+                                    const size = cell.getValue();
+                                    return size ? (size / (1024 * 1024)).toFixed(1) + " MB" : "";
+                                }
+                            },
                             {title: "File name", headerSort:false, field: "path",formatter:fluentFileFormatter,cellClick:function(e, cell){
                                     //e - the click event object
                                     //cell - cell component
                                     shell.showItemInFolder(cell.getData().path)
                                 }
+                            },
+                            {title: "", headerSort:false, formatter:function(cell){
+                                    const data = cell.getData();
+                                    let actions = "";
+                                    if (data.canMerge) {
+                                        actions += `<button class="btn btn-sm btn-outline-primary library-merge-action" data-title-id="${data.titleId}">Merge</button> `;
+                                    }
+                                    if (data.type === "multi-content") {
+                                        actions += `<button class="btn btn-sm btn-outline-primary library-split-action" data-title-id="${data.titleId}">Split</button> `;
+                                    }
+                                    if (data.trimmed === false) {
+                                        actions += `<button class="btn btn-sm btn-outline-primary library-trim-action" data-action="trim" data-title-id="${data.titleId}">Trim</button> `;
+                                    } else if (data.trimmed === true) {
+                                        actions += `<button class="btn btn-sm btn-outline-primary library-trim-action" data-action="untrim" data-title-id="${data.titleId}">Untrim</button> `;
+                                    }
+                                    if (data.compressed) {
+                                        actions += `<button class="btn btn-sm btn-outline-primary library-decompress-action" data-title-id="${data.titleId}">Decompress</button>`;
+                                    }
+                                    return actions;
+                                }
                             }
                         ],
                     });
@@ -447,6 +520,54 @@ $(function () {
             openFolderPicker(e.target.textContent.toLowerCase().trim())
         });
 
+        $("body").on("click", ".snapshot-export", e => {
+            dialog.showSaveDialog({
+                title: "Export titles database snapshot",
+                defaultPath: "titledb-snapshot.zip",
+                filters: [{name: "Zip archive", extensions: ["zip"]}]
+            }).then(result => {
+                if (result.canceled || !result.filePath) {
+                    return
+                }
+                sendMessage("exportSnapshot", result.filePath, (r => {
+                    if (!r) {
+                        return
+                    }
+                    dialog.showMessageBox(null, {
+                        type: 'info',
+                        buttons: ['Ok'],
+                        message: 'Titles database snapshot exported to ' + r
+                    });
+                }));
+            }).catch(error => console.log(error))
+        });
+
+        $("body").on("click", ".snapshot-import", e => {
+            dialog.showOpenDialog({
+                properties: ['openFile'],
+                message: "Select titles database snapshot",
+                filters: [{name: "Zip archive", extensions: ["zip"]}]
+            }).then(result => {
+                if (result.canceled || !result.filePaths || !result.filePaths.length) {
+                    return
+                }
+                sendMessage("importSnapshot", result.filePaths[0], (r => {
+                    if (!r) {
+                        return
+                    }
+                    state.titlesSnapshot = JSON.parse(r);
+                    state.library = undefined;
+                    state.updates = undefined;
+                    state.dlc = undefined;
+                    state.missingGames = undefined;
+                    sendMessage("updateDB", "", () => {
+                        loadTab("#settings");
+                        scanLocalFolder();
+                    });
+                }));
+            }).catch(error => console.log(error))
+        });
+
         $("body").on("click", ".export-btn", e => {
             currTable.download("csv", "export.csv", {}, "all");
         });
@@ -457,8 +578,12 @@ $(function () {
             const formData = new FormData(this);
             
             state.settings.prod_keys = formData.get("prod_keys");
+            const previousTargetFirmware = state.settings.target_firmware;
+            state.settings.target_firmware = (formData.get("target_firmware") || "").trim();
             state.settings.gui_page_size = parseInt(formData.get("gui_page_size"));
+            state.settings.scan_workers = parseInt(formData.get("scan_workers")) || 0;
             state.settings.scan_recursively = formData.has("scan_recursively");
+            state.settings.watch_folders = formData.has("watch_folders");
             state.settings.debug = formData.has("debug");
             
             state.settings.check_for_missing_updates = formData.has("check_for_missing_updates");
@@ -469,11 +594,14 @@ $(function () {
             
             state.settings.titles_json_url = formData.get("titles_json_url");
             state.settings.versions_json_url = formData.get("versions_json_url");
+            state.settings.offline_mode = formData.has("offline_mode");
             
             const splitComma = (val) => val ? val.split(',').map(s => s.trim()).filter(s => s) : [];
             const splitNewline = (val) => val ? val.split(/\r?\n/).map(s => s.trim()).filter(s => s) : [];
             
             state.settings.ignore_file_types = splitComma(formData.get("ignore_file_types"));
+            const previousLanguages = (state.settings.preferred_languages || []).join(",");
+            state.settings.preferred_languages = splitComma(formData.get("preferred_languages"));
             state.settings.ignore_update_title_ids = splitNewline(formData.get("ignore_update_title_ids"));
             state.settings.ignore_dlc_title_ids = splitNewline(formData.get("ignore_dlc_title_ids"));
             
@@ -492,9 +620,129 @@ $(function () {
                 } else {
                     document.getElementById("tab_btns").classList.remove("hide_missing_games");
                 }
+
+                // the firmware check and title names are done when loading the library
+                if (state.settings.target_firmware !== previousTargetFirmware || state.settings.preferred_languages.join(",") !== previousLanguages) {
+                    state.library = undefined;
+                    scanLocalFolder();
+                }
             });
         });
 
+        // Plans the library organization, and executes the plan once it was previewed
+        let organizeLibrary = function () {
+            sendMessage("organizePlan", "", (r => {
+                if (!r) {
+                    return
+                }
+                resolveCollisions(JSON.parse(r), [], 0);
+            }));
+        };
+
+        // Asks how to resolve the collisions left to the user by the "ask" collision policy, one at a time
+        let resolveCollisions = function (plan, resolutions, i) {
+            if (i >= plan.pending.length) {
+                if (resolutions.length === 0) {
+                    showOrganizePlan(plan);
+                    return
+                }
+                sendMessage("resolveCollisions", JSON.stringify(resolutions), (r => {
+                    if (!r) {
+                        return
+                    }
+                    showOrganizePlan(JSON.parse(r));
+                }));
+                return
+            }
+            dialog.showMessageBox(null, {
+                type: 'question',
+                buttons: ['Skip', 'Add a suffix', 'Overwrite', 'Skip all remaining'],
+                defaultId: 0,
+                cancelId: 3,
+                title: 'Collision ' + (i + 1) + ' of ' + plan.pending.length,
+                message: 'The destination of this file is already taken',
+                detail: plan.pending[i].action + "\n\nOverwritten files are moved to the trash."
+            }).then((r) => {
+                if (r.response === 3) {
+                    resolveCollisions(plan, resolutions, plan.pending.length);
+                    return
+                }
+                resolutions.push({index: plan.pending[i].index, resolution: ['skip', 'suffix', 'overwrite'][r.response]});
+                resolveCollisions(plan, resolutions, i + 1);
+            });
+        };
+
+        let showOrganizePlan = function (plan) {
+            if (plan.actions.length === 0) {
+                dialog.showMessageBox(null, {
+                    type: 'info',
+                    buttons: ['Ok'],
+                    defaultId: 0,
+                    title: 'Library organization',
+                    message: 'The library is already organized, there is nothing to do.'
+                });
+                return
+            }
+            const maxActions = 30;
+            let detail = plan.actions.slice(0, maxActions).join("\n");
+            if (plan.actions.length > maxActions) {
+                detail += "\n... and " + (plan.actions.length - maxActions) + " more, export the plan to review all of them";
+            }
+            if (plan.collisions > 0) {
+                detail += "\n\n" + plan.collisions + " files will not be moved, their destination is already taken.";
+            }
+            dialog.showMessageBox(null, {
+                type: 'warning',
+                buttons: ['Execute', 'Export plan', 'Cancel'],
+                defaultId: 2,
+                cancelId: 2,
+                title: 'Confirmation',
+                message: 'Library organization plan: ' + plan.summary,
+                detail: detail
+            }).then((r) => {
+                if (r.response === 1) {
+                    dialog.showSaveDialog({
+                        title: "Export organization plan",
+                        defaultPath: "organize-plan.json",
+                        filters: [{name: "JSON", extensions: ["json"]}]
+                    }).then(result => {
+                        if (!result.canceled && result.filePath) {
+                            sendMessage("exportPlan", result.filePath, () => showOrganizePlan(plan));
+                        } else {
+                            showOrganizePlan(plan);
+                        }
+                    }).catch(error => console.log(error))
+                    return
+                }
+                if (r.response !== 0) {
+                    return
+                }
+                $('.tabgroup > div').hide();
+                $(".progress-container").show();
+                $(".progress-type").text("Organizing local library...");
+
+                sendMessage("executePlan", "", (r => {
+                    $(".progress-container").hide();
+                    state.library = undefined;
+                    state.updates = undefined;
+                    state.dlc = undefined;
+                    loadTab("#library");
+                    scanLocalFolder(true);
+                    if (!r) {
+                        return
+                    }
+                    dialog.showMessageBox(null, {
+                        type: 'info',
+                        buttons: ['Ok'],
+                        defaultId: 0,
+                        title: 'Success',
+                        message: 'Operation completed successfully',
+                        detail: r
+                    });
+                }));
+            });
+        };
+
         // Organize Form Submit
         $("body").on("submit", "#organize-form", function(e) {
             e.preventDefault();
@@ -507,11 +755,16 @@ $(function () {
             state.settings.organize_options.process_when_missing_base_game = formData.has("process_when_missing_base_game");
             state.settings.organize_options.switch_safe_file_names = formData.has("switch_safe_file_names");
             state.settings.organize_options.prioritize_compressed = formData.has("prioritize_compressed");
+            state.settings.organize_options.compress_files = formData.has("compress_files");
+            state.settings.organize_options.collision_policy = formData.get("collision_policy");
             
             state.settings.organize_options.folder_name_template = formData.get("folder_name_template");
             state.settings.organize_options.file_name_template = formData.get("file_name_template");
             state.settings.organize_options.updates_folder = formData.get("updates_folder");
             state.settings.organize_options.dlc_folder = formData.get("dlc_folder");
+            state.settings.trash_folder = (formData.get("trash_folder") || "").trim();
+            state.settings.trash_max_age_days = parseInt(formData.get("trash_max_age_days")) || 0;
+            state.settings.trash_max_size_gb = parseInt(formData.get("trash_max_size_gb")) || 0;
             
             sendMessage("saveSettings", JSON.stringify(state.settings), function() {
                 if (state.settings.organize_options.create_folder_per_game === false &&
@@ -527,39 +780,70 @@ $(function () {
                     return;
                 }
 
-                const options = {
+                organizeLibrary();
+            });
+        });
+
+        // Permanently delete the files of the trash
+        $("body").on("click", ".trash-purge", e => {
+            e.preventDefault();
+            dialog.showMessageBox(null, {
+                type: 'warning',
+                buttons: ['Purge expired', 'Purge all', 'Cancel'],
+                defaultId: 2,
+                cancelId: 2,
+                title: 'Purge trash',
+                message: 'Permanently delete the files of the trash?',
+                detail: 'Expired files are the ones past the retention rules. Purged runs can no longer be undone.'
+            }).then((r) => {
+                if (r.response === 2) {
+                    return
+                }
+                sendMessage("purgeTrash", r.response === 0 ? "expired" : "all", () => loadTab("#organize"));
+            });
+        });
+
+        // Undo the moves and deletes of the last organize run
+        $("body").on("click", ".organize-undo", e => {
+            e.preventDefault();
+            sendMessage("lastRun", "", (r => {
+                if (!r) {
+                    dialog.showMessageBox(null, {
+                        type: 'info',
+                        buttons: ['Ok'],
+                        defaultId: 0,
+                        title: 'Undo last run',
+                        message: 'There is nothing to undo.'
+                    });
+                    return
+                }
+                const run = JSON.parse(r);
+                dialog.showMessageBox(null, {
                     type: 'warning',
-                    buttons: ['Yes', 'No'],
-                    defaultId: 0,
+                    buttons: ['Undo', 'Cancel'],
+                    defaultId: 1,
+                    cancelId: 1,
                     title: 'Confirmation',
-                    message: 'Are you sure you want to begin library organization?',
-                    detail: 'This action will modify your local library files based on the settings you just chose.',
-                };
-
-                dialog.showMessageBox(null, options).then( (r) => {
-                    if (r.response === 0) {
-                        $('.tabgroup > div').hide();
-                        $(".progress-container").show();
-                        $(".progress-type").text("Organizing local library...");
-
-                        sendMessage("organize", "", (r => {
-                            $(".progress-container").hide();
-                            state.library = undefined;
-                            state.updates = undefined;
-                            state.dlc = undefined;
-                            loadTab("#library");
-                            scanLocalFolder(true);
-                            dialog.showMessageBox(null, {
-                                type: 'info',
-                                buttons: ['Ok'],
-                                defaultId: 0,
-                                title: 'Success',
-                                message: 'Operation completed successfully'
-                            });
-                        }));
+                    message: 'Undo the ' + run.entries + ' changes of the run of ' + new Date(run.created).toLocaleString() + '?',
+                    detail: 'Moved and renamed files are moved back, and deleted files are restored from the trash.'
+                }).then((r) => {
+                    if (r.response !== 0) {
+                        return
                     }
+                    $('.tabgroup > div').hide();
+                    $(".progress-container").show();
+                    $(".progress-type").text("Undoing last run...");
+
+                    sendMessage("undoLastRun", "", (r => {
+                        $(".progress-container").hide();
+                        state.library = undefined;
+                        state.updates = undefined;
+                        state.dlc = undefined;
+                        loadTab("#library");
+                        scanLocalFolder(true);
+                    }));
                 });
-            });
+            }));
         });
 
         // Library & Issues Tab Organize Buttons
@@ -578,35 +862,152 @@ $(function () {
                 return;
             }
 
+            organizeLibrary();
+        });
+
+        // Verify library files
+        $("body").on("click", ".library-verify-action", e => {
+            e.preventDefault();
             const options = {
                 type: 'warning',
                 buttons: ['Yes', 'No'],
                 defaultId: 0,
                 title: 'Confirmation',
-                message: 'Are you sure you want to begin library organization?',
-                detail: 'This action will modify your local library files based on your current settings.',
+                message: 'Are you sure you want to verify all library files?',
+                detail: 'Every file will be read completely to check its content hashes. This can take a long time on large libraries.',
             };
+            dialog.showMessageBox(null, options).then( (r) => {
+                if (r.response === 0) {
+                    $(".progress-container").show();
+                    $(".progress-type").text("Verifying library files...");
+                    state.updates = undefined;
+                    state.dlc = undefined;
+                    sendMessage("verify", "", function(){});
+                }
+            });
+        });
 
+        // Decompress the NSZ/XCZ files of a title
+        $("body").on("click", ".library-decompress-action", e => {
+            e.preventDefault();
+            const titleId = $(e.currentTarget).attr("data-title-id");
+            const options = {
+                type: 'warning',
+                buttons: ['Yes', 'No'],
+                defaultId: 0,
+                title: 'Confirmation',
+                message: 'Are you sure you want to decompress the files of this title?',
+                detail: 'NSP/XCI files will be created next to the NSZ/XCZ files, the compressed files are kept.',
+            };
+            dialog.showMessageBox(null, options).then( (r) => {
+                if (r.response === 0) {
+                    $(".progress-container").show();
+                    $(".progress-type").text("Decompressing files...");
+                    sendMessage("decompress", ""+titleId, (r => {
+                        $(".progress-container").hide();
+                        state.library = undefined;
+                        state.updates = undefined;
+                        state.dlc = undefined;
+                        scanLocalFolder();
+                    }));
+                }
+            });
+        });
+
+        // Trim (or untrim) the XCI files of a title, or of the whole library when no title id is set
+        $("body").on("click", ".library-trim-action", e => {
+            e.preventDefault();
+            const action = $(e.currentTarget).attr("data-action");
+            const titleId = $(e.currentTarget).attr("data-title-id") || "";
+            const options = {
+                type: 'warning',
+                buttons: ['Yes', 'No'],
+                defaultId: 0,
+                title: 'Confirmation',
+                message: action === "trim" ?
+                    'Are you sure you want to trim the XCI files' + (titleId ? ' of this title?' : ' in the library?') :
+                    'Are you sure you want to untrim the XCI files' + (titleId ? ' of this title?' : ' in the library?'),
+                detail: action === "trim" ?
+                    'The padding after the game data will be removed from the files.' :
+                    'The files will be padded back to the size of their gamecard.',
+            };
             dialog.showMessageBox(null, options).then( (r) => {
                 if (r.response === 0) {
-                    $('.tabgroup > div').hide();
                     $(".progress-container").show();
-                    $(".progress-type").text("Organizing local library...");
+                    $(".progress-type").text(action === "trim" ? "Trimming files..." : "Untrimming files...");
+                    sendMessage(action, titleId, (r => {
+                        $(".progress-container").hide();
+                        state.library = undefined;
+                        state.updates = undefined;
+                        state.dlc = undefined;
+                        scanLocalFolder();
+                    }));
+                }
+            });
+        });
+
+        // Only show the titles which need a newer firmware
+        $("body").on("change", "#firmware_filter", e => {
+            if (e.target.checked) {
+                currTable.setFilter("needsNewerFirmware", "=", true);
+            } else {
+                currTable.clearFilter();
+            }
+        });
 
-                    sendMessage("organize", "", (r => {
+        // Merge base, latest update and DLC of a title into a single file
+        $("body").on("click", ".library-merge-action", e => {
+            e.preventDefault();
+            const titleId = $(e.currentTarget).attr("data-title-id");
+            const options = {
+                type: 'question',
+                buttons: ['Yes', 'No'],
+                defaultId: 0,
+                title: 'Confirmation',
+                message: 'Are you sure you want to merge the files of this title?',
+                detail: 'A multi-content file with the base game and the latest update will be created next to the base file, the original files are kept.',
+                checkboxLabel: 'Include DLC',
+                checkboxChecked: true,
+            };
+            dialog.showMessageBox(null, options).then( (r) => {
+                if (r.response === 0) {
+                    $(".progress-container").show();
+                    $(".progress-type").text("Merging files...");
+                    sendMessage("merge", JSON.stringify({titleId: titleId, includeDlc: r.checkboxChecked}), (r => {
                         $(".progress-container").hide();
                         state.library = undefined;
                         state.updates = undefined;
                         state.dlc = undefined;
-                        loadTab("#library");
-                        scanLocalFolder(true);
-                        dialog.showMessageBox(null, {
-                            type: 'info',
-                            buttons: ['Ok'],
-                            defaultId: 0,
-                            title: 'Success',
-                            message: 'Operation completed successfully'
-                        });
+                        scanLocalFolder();
+                    }));
+                }
+            });
+        });
+
+        // Split a multi-content file into separate base, update and DLC files
+        $("body").on("click", ".library-split-action", e => {
+            e.preventDefault();
+            const titleId = $(e.currentTarget).attr("data-title-id");
+            const options = {
+                type: 'question',
+                buttons: ['Yes', 'No'],
+                defaultId: 0,
+                title: 'Confirmation',
+                message: 'Are you sure you want to split the multi-content file of this title?',
+                detail: 'Separate NSP files will be created next to the file, named using the file name template from the Organize tab.',
+                checkboxLabel: 'Delete the multi-content file afterwards',
+                checkboxChecked: false,
+            };
+            dialog.showMessageBox(null, options).then( (r) => {
+                if (r.response === 0) {
+                    $(".progress-container").show();
+                    $(".progress-type").text("Splitting file...");
+                    sendMessage("split", JSON.stringify({titleId: titleId, deleteSource: r.checkboxChecked}), (r => {
+                        $(".progress-container").hide();
+                        state.library = undefined;
+                        state.updates = undefined;
+                        state.dlc = undefined;
+                        scanLocalFolder();
                     }));
                 }
             });
@@ -0,0 +1,52 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// first firmware using each key generation (master key revision)
+var keyGenerationFirmwares = []string{
+	"1.0.0", "3.0.0", "3.0.1", "4.0.0", "5.0.0", "6.0.0", "6.2.0", "7.0.0", "8.1.0", "9.0.0",
+	"9.1.0", "12.1.0", "13.0.0", "14.0.0", "15.0.0", "16.0.0", "17.0.0", "18.0.0", "19.0.0", "20.0.0",
+}
+
+// KeyGenerationSystemVersion returns the system version of the first firmware supporting
+// a NCA key generation (0 and 1 both use the first master key)
+func KeyGenerationSystemVersion(keyGeneration int) uint32 {
+	revision := keyGeneration - 1
+	if revision < 0 {
+		revision = 0
+	}
+	if revision >= len(keyGenerationFirmwares) {
+		revision = len(keyGenerationFirmwares) - 1
+	}
+	version, _ := ParseSystemVersion(keyGenerationFirmwares[revision])
+	return version
+}
+
+// FormatSystemVersion formats a system version (as stored in the CNMT) as major.minor.micro
+func FormatSystemVersion(version uint32) string {
+	return fmt.Sprintf("%d.%d.%d", version>>26, (version>>20)&0x3F, (version>>16)&0xF)
+}
+
+// ParseSystemVersion parses a firmware version (major.minor.micro) into a system version
+func ParseSystemVersion(version string) (uint32, error) {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return 0, errors.New("invalid firmware version " + version)
+	}
+	limits := []uint64{0x3F, 0x3F, 0xF}
+	shifts := []int{26, 20, 16}
+	result := uint32(0)
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || value > limits[i] {
+			return 0, errors.New("invalid firmware version " + version)
+		}
+		result |= uint32(value) << shifts[i]
+	}
+	return result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/magiconair/properties"
 	"go.uber.org/zap"
@@ -14,13 +15,38 @@ var (
 )
 
 type switchKeys struct {
-	keys map[string]string
+	keys      map[string]string
+	titleKeys map[string]string // encrypted title keys by rights id
+	mutex     sync.RWMutex
+	report    *KeysReport
+}
+
+// Report returns the validation report of the loaded keys
+func (k *switchKeys) Report() *KeysReport {
+	return k.report
 }
 
 func (k *switchKeys) GetKey(keyName string) string {
 	return k.keys[keyName]
 }
 
+// GetTitleKey returns the encrypted title key of a rights id, loaded from title.keys or from tickets
+func (k *switchKeys) GetTitleKey(rightsId string) string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.titleKeys[strings.ToLower(rightsId)]
+}
+
+// AddTitleKey adds a title key read from a ticket, keys from title.keys are kept
+func (k *switchKeys) AddTitleKey(rightsId string, titleKey string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	rightsId = strings.ToLower(rightsId)
+	if _, ok := k.titleKeys[rightsId]; !ok {
+		k.titleKeys[rightsId] = strings.ToLower(titleKey)
+	}
+}
+
 func SwitchKeys() (*switchKeys, error) {
 	return keysInstance, nil
 }
@@ -68,12 +94,45 @@ func InitSwitchKeys(baseFolder string) (*switchKeys, error) {
 		return nil, errors.New("Error trying to read prod.keys [reason:" + err.Error() + "]")
 	}
 
-	keysInstance = &switchKeys{keys: map[string]string{}}
+	keysInstance = &switchKeys{keys: map[string]string{}, titleKeys: map[string]string{}}
 	for _, key := range p.Keys() {
 		value, _ := p.Get(key)
 		keysInstance.keys[key] = value
 	}
 
 	logger.Infof("Loaded prod.keys from: %v", path)
+
+	keysInstance.report = validateKeys(keysInstance, path)
+	if len(keysInstance.report.InvalidKeys) != 0 {
+		logger.Warnf("Invalid keys found in prod.keys: %v", keysInstance.report.InvalidKeys)
+	}
+	if len(keysInstance.report.DerivedKeys) != 0 {
+		logger.Infof("Derived keys: %v", keysInstance.report.DerivedKeys)
+	}
+
+	loadTitleKeys(keysInstance, []string{
+		filepath.Join(filepath.Dir(path), "title.keys"),
+		filepath.Join(baseFolder, "title.keys"),
+		"${HOME}/.switch/title.keys",
+	})
 	return keysInstance, nil
 }
+
+// loadTitleKeys loads the title keys (rights id = title key) from the first title.keys file found
+func loadTitleKeys(keys *switchKeys, paths []string) {
+	logger := zap.S()
+	for _, path := range paths {
+		p, err := properties.LoadFile(path, properties.UTF8)
+		if err != nil {
+			continue
+		}
+
+		for _, rightsId := range p.Keys() {
+			value, _ := p.Get(rightsId)
+			keys.titleKeys[strings.ToLower(rightsId)] = strings.ToLower(value)
+		}
+		logger.Infof("Loaded %v title keys from: %v", len(keys.titleKeys), path)
+		return
+	}
+	logger.Info("Unable to find title.keys")
+}
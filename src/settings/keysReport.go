@@ -0,0 +1,174 @@
+package settings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/switchfs/_crypto"
+)
+
+// highest number of key generations checked
+const maxKeyGeneration = 0x20
+
+// publishedKeyHashes are the SHA-256 hashes of the key sources, which are the same for every console
+var publishedKeyHashes = map[string]string{
+	"aes_kek_generation_source":       "fc02b9d37b42d7a1452e71444f1f700311d1132e301a83b16062e72a78175085",
+	"aes_key_generation_source":       "fbd10056999edc7acdb96098e47e2c3606230270d23281e671f0f389fc5bc585",
+	"header_kek_source":               "1888caed5551b3ede01499e87ce0d86827f80820efb275921055aa4e2abdffc2",
+	"header_key_source":               "8f783e46852df6be0ba4e19273c4adbaee16380043e1b8c418c4089a8bd64aa6",
+	"key_area_key_application_source": "04ad66143c726b2a139fb6b21128b46f56c553b2b3887110304298d8d0092d9e",
+	"key_area_key_ocean_source":       "fd434000c8ff2b26f8e9a9d2d2c12f6be5773cbb9dc86300e1bd99f8ea33a417",
+	"key_area_key_system_source":      "1f17b1fd51ad1c2379b58f152ca4912ec2106441e51722f38700d5937a1162f7",
+	"master_key_source":               "7944862a3a5c31c6720595efd302245abd1b54ccdcf33000557681e65c5664a4",
+	"titlekek_source":                 "c48b619827986c7f4e3081d59db2b460c84312650e9a8e6b458e53e8cbca4e87",
+}
+
+type KeysReport struct {
+	Path           string   `json:"path"`
+	InvalidKeys    []string `json:"invalid_keys"`    // wrong format, or not matching the published hash or the value derived from the other keys
+	DerivedKeys    []string `json:"derived_keys"`    // keys missing from prod.keys, derived from the master keys and key sources
+	KeyGenerations []int    `json:"key_generations"` // generations with a key_area_key_application key
+	MaxFirmware    string   `json:"max_firmware"`    // highest firmware whose content can be read, empty when none
+}
+
+// String formats the report for the console
+func (r *KeysReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Keys loaded from: %v\n", r.Path))
+	generations := make([]string, len(r.KeyGenerations))
+	for i, generation := range r.KeyGenerations {
+		generations[i] = fmt.Sprintf("%02x", generation)
+	}
+	sb.WriteString(fmt.Sprintf("Key generations: %v\n", strings.Join(generations, ", ")))
+	if r.MaxFirmware != "" {
+		sb.WriteString(fmt.Sprintf("Content up to firmware %v can be read\n", r.MaxFirmware))
+	} else {
+		sb.WriteString("No key_area_key_application keys found, content cannot be read\n")
+	}
+	if len(r.DerivedKeys) != 0 {
+		sb.WriteString(fmt.Sprintf("Derived keys: %v\n", strings.Join(r.DerivedKeys, ", ")))
+	}
+	for _, invalid := range r.InvalidKeys {
+		sb.WriteString(fmt.Sprintf("Invalid key: %v\n", invalid))
+	}
+	return sb.String()
+}
+
+// validateKeys checks the format of the loaded keys and the key sources against their published hash,
+// derives the missing keys which can be derived from the master keys and key sources, and compares the
+// present keys with their derived value. Invalid keys are removed, so nothing is derived from them.
+func validateKeys(keys *switchKeys, path string) *KeysReport {
+	report := &KeysReport{Path: path}
+
+	for name, value := range keys.keys {
+		expectedSize := 0x10
+		if name == "header_key" || strings.HasSuffix(name, "header_key_source") {
+			expectedSize = 0x20
+		}
+		decoded, err := hex.DecodeString(value)
+		if err != nil || (isDerivableKey(name) && len(decoded) != expectedSize) {
+			report.InvalidKeys = append(report.InvalidKeys, name+" - invalid format")
+			delete(keys.keys, name)
+			continue
+		}
+		if hash, ok := publishedKeyHashes[name]; ok {
+			if sum := sha256.Sum256(decoded); hex.EncodeToString(sum[:]) != hash {
+				report.InvalidKeys = append(report.InvalidKeys, name+" - does not match its published SHA-256 hash")
+				delete(keys.keys, name)
+			}
+		}
+	}
+
+	check := func(name string, derived []byte) {
+		if derived == nil {
+			return
+		}
+		value := hex.EncodeToString(derived)
+		current, ok := keys.keys[name]
+		if !ok {
+			keys.keys[name] = value
+			report.DerivedKeys = append(report.DerivedKeys, name)
+		} else if !strings.EqualFold(current, value) {
+			report.InvalidKeys = append(report.InvalidKeys, name+" - does not match the key derived from the master key")
+		}
+	}
+
+	for generation := 0; generation < maxKeyGeneration; generation++ {
+		check(fmt.Sprintf("master_key_%02x", generation),
+			decryptKey(keys, "master_key_source", fmt.Sprintf("master_kek_%02x", generation)))
+
+		masterKey := fmt.Sprintf("master_key_%02x", generation)
+		for _, area := range []string{"application", "ocean", "system"} {
+			check(fmt.Sprintf("key_area_key_%v_%02x", area, generation),
+				generateKek(keys, "key_area_key_"+area+"_source", masterKey))
+		}
+		check(fmt.Sprintf("titlekek_%02x", generation), decryptKey(keys, "titlekek_source", masterKey))
+	}
+
+	if headerKek := generateKek(keys, "header_kek_source", "master_key_00"); headerKek != nil {
+		if source := getKeyBytes(keys, "header_key_source"); len(source) == 0x20 {
+			check("header_key", _crypto.DecryptAes128Ecb(source, headerKek))
+		}
+	}
+
+	for generation := 0; generation < maxKeyGeneration; generation++ {
+		if _, ok := keys.keys[fmt.Sprintf("key_area_key_application_%02x", generation)]; ok {
+			report.KeyGenerations = append(report.KeyGenerations, generation)
+		}
+	}
+	// content of a firmware needs every generation up to its own
+	for generation := 0; generation < len(keyGenerationFirmwares); generation++ {
+		if _, ok := keys.keys[fmt.Sprintf("key_area_key_application_%02x", generation)]; !ok {
+			break
+		}
+		report.MaxFirmware = keyGenerationFirmwares[generation]
+	}
+	if len(report.KeyGenerations) != 0 && report.KeyGenerations[len(report.KeyGenerations)-1] >= len(keyGenerationFirmwares) {
+		report.MaxFirmware += "+"
+	}
+
+	sort.Strings(report.InvalidKeys)
+	sort.Strings(report.DerivedKeys)
+	return report
+}
+
+func isDerivableKey(name string) bool {
+	for _, prefix := range []string{"master_key", "master_kek", "key_area_key", "titlekek", "header_k", "aes_k"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func getKeyBytes(keys *switchKeys, name string) []byte {
+	key, err := hex.DecodeString(keys.keys[name])
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// decryptKey decrypts the source key with the given key, nil when any of them is missing
+func decryptKey(keys *switchKeys, sourceName string, keyName string) []byte {
+	source := getKeyBytes(keys, sourceName)
+	key := getKeyBytes(keys, keyName)
+	if len(source) != 0x10 || len(key) != 0x10 {
+		return nil
+	}
+	return _crypto.DecryptAes128Ecb(source, key)
+}
+
+// generateKek derives a key from a key source and a master key (generate_kek of the switch crypto)
+func generateKek(keys *switchKeys, sourceName string, masterKeyName string) []byte {
+	kek := decryptKey(keys, "aes_kek_generation_source", masterKeyName)
+	source := getKeyBytes(keys, sourceName)
+	keySeed := getKeyBytes(keys, "aes_key_generation_source")
+	if kek == nil || len(source) != 0x10 || len(keySeed) != 0x10 {
+		return nil
+	}
+	return _crypto.DecryptAes128Ecb(keySeed, _crypto.DecryptAes128Ecb(source, kek))
+}
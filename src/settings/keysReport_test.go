@@ -0,0 +1,115 @@
+package settings
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func encryptAes128Ecb(t *testing.T, data []byte, key []byte) []byte {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+	encrypted := make([]byte, len(data))
+	for i := 0; i < len(data); i += aes.BlockSize {
+		cipher.Encrypt(encrypted[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+	}
+	return encrypted
+}
+
+func TestDecryptKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 0x10)
+	value := bytes.Repeat([]byte{0x22}, 0x10)
+	keys := &switchKeys{keys: map[string]string{
+		"master_kek_00":     hex.EncodeToString(key),
+		"master_key_source": hex.EncodeToString(encryptAes128Ecb(t, value, key)),
+		"short_source":      "0011",
+	}}
+
+	if decrypted := decryptKey(keys, "master_key_source", "master_kek_00"); !bytes.Equal(decrypted, value) {
+		t.Fatalf("expected %x, got %x", value, decrypted)
+	}
+	if decrypted := decryptKey(keys, "master_key_source", "master_kek_01"); decrypted != nil {
+		t.Fatalf("expected nil for a missing key, got %x", decrypted)
+	}
+	if decrypted := decryptKey(keys, "short_source", "master_kek_00"); decrypted != nil {
+		t.Fatalf("expected nil for a source of the wrong size, got %x", decrypted)
+	}
+}
+
+func TestGenerateKek(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{0x01}, 0x10)
+	kek := bytes.Repeat([]byte{0x02}, 0x10)
+	key := bytes.Repeat([]byte{0x03}, 0x10)
+	expected := bytes.Repeat([]byte{0x04}, 0x10)
+	keys := &switchKeys{keys: map[string]string{
+		"master_key_00":                   hex.EncodeToString(masterKey),
+		"aes_kek_generation_source":       hex.EncodeToString(encryptAes128Ecb(t, kek, masterKey)),
+		"key_area_key_application_source": hex.EncodeToString(encryptAes128Ecb(t, key, kek)),
+		"aes_key_generation_source":       hex.EncodeToString(encryptAes128Ecb(t, expected, key)),
+	}}
+
+	if generated := generateKek(keys, "key_area_key_application_source", "master_key_00"); !bytes.Equal(generated, expected) {
+		t.Fatalf("expected %x, got %x", expected, generated)
+	}
+	delete(keys.keys, "aes_key_generation_source")
+	if generated := generateKek(keys, "key_area_key_application_source", "master_key_00"); generated != nil {
+		t.Fatalf("expected nil without aes_key_generation_source, got %x", generated)
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	keys := &switchKeys{keys: map[string]string{
+		"titlekek_source":             strings.Repeat("00", 0x10),
+		"master_key_00":               "not hex",
+		"key_area_key_application_00": strings.Repeat("11", 0x10),
+		"key_area_key_application_01": strings.Repeat("22", 0x10),
+	}}
+	report := validateKeys(keys, "prod.keys")
+
+	expectedInvalid := []string{"master_key_00 - invalid format", "titlekek_source - does not match its published SHA-256 hash"}
+	if strings.Join(report.InvalidKeys, ",") != strings.Join(expectedInvalid, ",") {
+		t.Fatalf("expected invalid keys %v, got %v", expectedInvalid, report.InvalidKeys)
+	}
+	if _, ok := keys.keys["titlekek_source"]; ok {
+		t.Fatalf("expected the invalid key source to be removed")
+	}
+	if len(report.KeyGenerations) != 2 || report.MaxFirmware != keyGenerationFirmwares[1] {
+		t.Fatalf("expected key generations 0 and 1 up to firmware %v, got %v and %v",
+			keyGenerationFirmwares[1], report.KeyGenerations, report.MaxFirmware)
+	}
+}
+
+func TestValidateKeysPublishedSources(t *testing.T) {
+	sources := map[string]string{
+		"aes_kek_generation_source":       "4d870986c45d20722fba1053da92e8a9",
+		"aes_key_generation_source":       "89615ee05c31b6805fe58f3da24f7aa8",
+		"header_kek_source":               "1f12913a4acbf00d4cde3af6d523882a",
+		"header_key_source":               "5a3ed84fdec0d82631f7e25d197bf5d01c9b7bfaf628183d71f64d73f150b9d2",
+		"key_area_key_application_source": "7f59971e629f36a13098066f2144c30d",
+		"key_area_key_ocean_source":       "327d36085ad1758dab4e6fbaa555d882",
+		"key_area_key_system_source":      "8745f1bba6be79647d048ba67b5fda4a",
+		"master_key_source":               "d8a2410ac6c59001c61d6a267c513f3c",
+		"titlekek_source":                 "1edc7b3b60e6b4d878b81715985e629b",
+	}
+	keys := &switchKeys{keys: map[string]string{}}
+	for name, value := range sources {
+		keys.keys[name] = value
+	}
+	report := validateKeys(keys, "prod.keys")
+
+	if len(report.InvalidKeys) != 0 {
+		t.Fatalf("expected the published key sources to be valid, got %v", report.InvalidKeys)
+	}
+	for name := range publishedKeyHashes {
+		if _, ok := sources[name]; !ok {
+			t.Errorf("%v is not covered by the test", name)
+		}
+		if _, ok := keys.keys[name]; !ok {
+			t.Errorf("expected %v to be kept", name)
+		}
+	}
+}
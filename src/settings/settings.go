@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/mcuadros/go-version"
 	"go.uber.org/zap"
@@ -20,10 +21,13 @@ const (
 	SETTINGS_FILENAME         = "settings.json"
 	TITLE_JSON_FILENAME       = "titles.json"
 	VERSIONS_JSON_FILENAME    = "versions.json"
+	TITLES_SNAPSHOT_FILENAME  = "titledb_snapshot.json"
 	SLM_VERSION               = "1.12.0"
 	DEFAULT_TITLES_JSON_URL   = "https://tinfoil.io/repo/db/titles.json"
 	DEFAULT_VERSIONS_JSON_URL = "https://raw.githubusercontent.com/blawar/titledb/master/versions.json"
 	SLM_VERSION_URL           = "https://raw.githubusercontent.com/trembon/switch-library-manager/master/version.json"
+
+	DEFAULT_TRASH_MAX_AGE_DAYS = 30
 )
 
 const (
@@ -36,6 +40,15 @@ const (
 	TEMPLATE_TYPE        = "TYPE"
 )
 
+// what to do when the destination of a file to organize is already taken
+const (
+	COLLISION_POLICY_SKIP                    = "skip" // leave the file in place
+	COLLISION_POLICY_SUFFIX                  = "suffix"
+	COLLISION_POLICY_OVERWRITE_NEWER_VERSION = "overwrite_newer_version"
+	COLLISION_POLICY_OVERWRITE_COMPRESSED    = "overwrite_compressed"
+	COLLISION_POLICY_ASK                     = "ask" // in the GUI, skipped in command line mode
+)
+
 type OrganizeOptions struct {
 	CreateFolderPerGame        bool   `json:"create_folder_per_game"`
 	DlcFolder                  string `json:"dlc_folder"`
@@ -48,33 +61,54 @@ type OrganizeOptions struct {
 	FileNameTemplate           string `json:"file_name_template"`
 	ProcessWhenMissingBaseGame bool   `json:"process_when_missing_base_game"`
 	PrioritizeCompressed       bool   `json:"prioritize_compressed"`
+	CompressFiles              bool   `json:"compress_files"`
+	CollisionPolicy            string `json:"collision_policy"`
+}
+
+// TitlesProviderSettings configures an additional source of the titles database, see db.TitlesProvider
+type TitlesProviderSettings struct {
+	Name string `json:"name"`
+	Type string `json:"type"`           // titles, titledb, versions or csv
+	Url  string `json:"url,omitempty"`  // downloaded and cached next to the settings, unless offline
+	Path string `json:"path,omitempty"` // local file, used instead of the url
+	Mode string `json:"mode,omitempty"` // override (default) replaces the values of the previous sources, fill only sets missing values
+	Etag string `json:"etag,omitempty"`
 }
 
 type AppSettings struct {
-	VersionsJsonUrl        string          `json:"versions_json_url"`
-	VersionsEtag           string          `json:"versions_etag"`
-	TitlesJsonUrl          string          `json:"titles_json_url"`
-	TitlesEtag             string          `json:"titles_etag"`
-	Prodkeys               string          `json:"prod_keys"`
-	Folder                 string          `json:"folder"`
-	ScanFolders            []string        `json:"scan_folders"`
-	GUI                    bool            `json:"gui"`
-	Debug                  bool            `json:"debug"`
-	CheckForMissingUpdates bool            `json:"check_for_missing_updates"`
-	CheckForMissingDLC     bool            `json:"check_for_missing_dlc"`
-	HideMissingGames       bool            `json:"hide_missing_games"`
-	HideDemoGames          bool            `json:"hide_demo_games"`
-	OrganizeOptions        OrganizeOptions `json:"organize_options"`
-	ScanRecursively        bool            `json:"scan_recursively"`
-	GuiPagingSize          int             `json:"gui_page_size"`
-	DarkMode               bool            `json:"dark_mode"`
-	WindowWidth            int             `json:"window_width,omitempty"`
-	WindowHeight           int             `json:"window_height,omitempty"`
-	WindowMaximized        bool            `json:"window_maximized,omitempty"`
-	IgnoreDLCUpdates       bool            `json:"ignore_dlc_updates"`
-	IgnoreDLCTitleIds      []string        `json:"ignore_dlc_title_ids"`
-	IgnoreUpdateTitleIds   []string        `json:"ignore_update_title_ids"`
-	IgnoreFileTypes        []string        `json:"ignore_file_types"`
+	VersionsJsonUrl        string                   `json:"versions_json_url"`
+	VersionsEtag           string                   `json:"versions_etag"`
+	TitlesJsonUrl          string                   `json:"titles_json_url"`
+	TitlesEtag             string                   `json:"titles_etag"`
+	Prodkeys               string                   `json:"prod_keys"`
+	Folder                 string                   `json:"folder"`
+	ScanFolders            []string                 `json:"scan_folders"`
+	GUI                    bool                     `json:"gui"`
+	Debug                  bool                     `json:"debug"`
+	CheckForMissingUpdates bool                     `json:"check_for_missing_updates"`
+	CheckForMissingDLC     bool                     `json:"check_for_missing_dlc"`
+	HideMissingGames       bool                     `json:"hide_missing_games"`
+	HideDemoGames          bool                     `json:"hide_demo_games"`
+	OrganizeOptions        OrganizeOptions          `json:"organize_options"`
+	ScanRecursively        bool                     `json:"scan_recursively"`
+	GuiPagingSize          int                      `json:"gui_page_size"`
+	DarkMode               bool                     `json:"dark_mode"`
+	WindowWidth            int                      `json:"window_width,omitempty"`
+	WindowHeight           int                      `json:"window_height,omitempty"`
+	WindowMaximized        bool                     `json:"window_maximized,omitempty"`
+	IgnoreDLCUpdates       bool                     `json:"ignore_dlc_updates"`
+	IgnoreDLCTitleIds      []string                 `json:"ignore_dlc_title_ids"`
+	IgnoreUpdateTitleIds   []string                 `json:"ignore_update_title_ids"`
+	IgnoreFileTypes        []string                 `json:"ignore_file_types"`
+	ScanWorkers            int                      `json:"scan_workers"`
+	WatchFolders           bool                     `json:"watch_folders"`
+	TargetFirmware         string                   `json:"target_firmware"`
+	OfflineMode            bool                     `json:"offline_mode"`
+	TitlesProviders        []TitlesProviderSettings `json:"titles_providers"`
+	PreferredLanguages     []string                 `json:"preferred_languages"`
+	TrashFolder            string                   `json:"trash_folder"`       // deleted files are moved there, empty for the trash folder next to the settings
+	TrashMaxAgeDays        int                      `json:"trash_max_age_days"` // 0 keeps the deleted files until purged
+	TrashMaxSizeGB         int                      `json:"trash_max_size_gb"`  // 0 for no limit
 }
 
 func ReadSettingsAsJSON(baseFolder string) string {
@@ -90,8 +124,9 @@ func ReadSettings(baseFolder string) *AppSettings {
 	if settingsInstance != nil {
 		return settingsInstance
 	}
-	settingsInstance = &AppSettings{Debug: false, GuiPagingSize: 100, ScanFolders: []string{},
-		OrganizeOptions: OrganizeOptions{SwitchSafeFileNames: true, PrioritizeCompressed: true}, Prodkeys: "", IgnoreDLCTitleIds: []string{"01007F600B135007"}}
+	settingsInstance = &AppSettings{Debug: false, GuiPagingSize: 100, ScanFolders: []string{}, ScanWorkers: runtime.NumCPU(),
+		OrganizeOptions: OrganizeOptions{SwitchSafeFileNames: true, PrioritizeCompressed: true, CollisionPolicy: COLLISION_POLICY_SKIP}, Prodkeys: "", IgnoreDLCTitleIds: []string{"01007F600B135007"},
+		TrashMaxAgeDays: DEFAULT_TRASH_MAX_AGE_DAYS}
 	if _, err := os.Stat(filepath.Join(baseFolder, SETTINGS_FILENAME)); err == nil {
 		file, err := os.Open(filepath.Join(baseFolder, SETTINGS_FILENAME))
 		if err != nil {
@@ -114,6 +149,28 @@ func verifySettings(baseFolder string, settings *AppSettings) *AppSettings {
 	if settings.WindowHeight == 0 {
 		settings.WindowHeight = 600
 	}
+	if settings.ScanWorkers <= 0 {
+		settings.ScanWorkers = runtime.NumCPU()
+	}
+	if _, err := ParseSystemVersion(settings.TargetFirmware); settings.TargetFirmware != "" && err != nil {
+		zap.S().Warnf("Ignoring invalid target firmware %v", settings.TargetFirmware)
+		settings.TargetFirmware = ""
+	}
+	switch settings.OrganizeOptions.CollisionPolicy {
+	case COLLISION_POLICY_SKIP, COLLISION_POLICY_SUFFIX, COLLISION_POLICY_OVERWRITE_NEWER_VERSION,
+		COLLISION_POLICY_OVERWRITE_COMPRESSED, COLLISION_POLICY_ASK:
+	default:
+		if settings.OrganizeOptions.CollisionPolicy != "" {
+			zap.S().Warnf("Ignoring invalid collision policy %v", settings.OrganizeOptions.CollisionPolicy)
+		}
+		settings.OrganizeOptions.CollisionPolicy = COLLISION_POLICY_SKIP
+	}
+	if settings.TrashMaxAgeDays < 0 {
+		settings.TrashMaxAgeDays = 0
+	}
+	if settings.TrashMaxSizeGB < 0 {
+		settings.TrashMaxSizeGB = 0
+	}
 
 	// check so titles json url is set, if not revert to default
 	if settings.TitlesJsonUrl == "" {
@@ -149,6 +206,10 @@ func saveDefaultSettings(baseFolder string) *AppSettings {
 		IgnoreDLCTitleIds:      []string{},
 		IgnoreDLCUpdates:       false,
 		IgnoreFileTypes:        []string{},
+		ScanWorkers:            runtime.NumCPU(),
+		WatchFolders:           false,
+		TargetFirmware:         "",
+		TrashMaxAgeDays:        DEFAULT_TRASH_MAX_AGE_DAYS,
 		GUI:                    true,
 		GuiPagingSize:          100,
 		CheckForMissingUpdates: true,
@@ -173,6 +234,8 @@ func saveDefaultSettings(baseFolder string) *AppSettings {
 			DeleteOldUpdateFiles:       false,
 			ProcessWhenMissingBaseGame: false,
 			PrioritizeCompressed:       true,
+			CompressFiles:              false,
+			CollisionPolicy:            COLLISION_POLICY_SKIP,
 		},
 		DarkMode: true,
 	}
@@ -0,0 +1,92 @@
+package switchfs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+//https://switchbrew.org/wiki/NCA#PatchInfo
+
+const bktrNodeSize = 0x4000
+
+type aesCtrExEntry struct {
+	offset     uint64 // relative to the section start
+	generation uint32
+}
+
+// decryptAesCtrEx decrypts an AesCtrEx (patch) section, the data is split in ranges listed by
+// the AesCtrEx bucket tree, each one using the section counter with its own generation.
+func decryptAesCtrEx(ncaHeader *ncaHeader, fsHeader *fsHeader, entry fsEntry, encoded []byte) ([]byte, error) {
+	patchInfo := fsHeader.fsHeaderBytes[0x100:0x140]
+	if string(patchInfo[0x30:0x34]) != "BKTR" {
+		return nil, errors.New("invalid AesCtrEx bucket tree header")
+	}
+	tableOffset := binary.LittleEndian.Uint64(patchInfo[0x20:0x28])
+	tableSize := binary.LittleEndian.Uint64(patchInfo[0x28:0x30])
+	if tableOffset+tableSize > entry.Size || tableSize < 0x10 {
+		return nil, errors.New("invalid AesCtrEx bucket tree offset")
+	}
+
+	key, err := getNcaCtrKey(ncaHeader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make([]byte, entry.Size)
+	// the bucket tree (and anything after it) uses the regular section counter
+	cipher.NewCTR(block, fsHeader.getCounter(entry.StartOffset+tableOffset)).
+		XORKeyStream(decoded[tableOffset:], encoded[tableOffset:entry.Size])
+
+	entries, err := readAesCtrExEntries(decoded[tableOffset : tableOffset+tableSize])
+	if err != nil {
+		return nil, err
+	}
+	for i, ctrEntry := range entries {
+		end := tableOffset
+		if i+1 < len(entries) {
+			end = min(entries[i+1].offset, tableOffset)
+		}
+		if ctrEntry.offset >= end {
+			continue
+		}
+		counter := fsHeader.getCounter(entry.StartOffset + ctrEntry.offset)
+		binary.BigEndian.PutUint32(counter[0x4:0x8], ctrEntry.generation)
+		cipher.NewCTR(block, counter).XORKeyStream(decoded[ctrEntry.offset:end], encoded[ctrEntry.offset:end])
+	}
+	return decoded, nil
+}
+
+// readAesCtrExEntries reads the entries of a decrypted AesCtrEx bucket tree,
+// an offset node followed by the entry nodes (0x10 byte node header + 0x10 byte entries)
+func readAesCtrExEntries(table []byte) ([]aesCtrExEntry, error) {
+	nodeCount := int(binary.LittleEndian.Uint32(table[0x4:0x8]))
+	if len(table) < bktrNodeSize*(nodeCount+1) {
+		return nil, errors.New("invalid AesCtrEx bucket tree size")
+	}
+
+	var entries []aesCtrExEntry
+	for i := 0; i < nodeCount; i++ {
+		node := table[bktrNodeSize*(i+1) : bktrNodeSize*(i+2)]
+		entryCount := int(binary.LittleEndian.Uint32(node[0x4:0x8]))
+		if 0x10+0x10*entryCount > bktrNodeSize {
+			return nil, errors.New("invalid AesCtrEx bucket tree node")
+		}
+		for j := 0; j < entryCount; j++ {
+			entryBytes := node[0x10+0x10*j : 0x20+0x10*j]
+			entries = append(entries, aesCtrExEntry{
+				offset:     binary.LittleEndian.Uint64(entryBytes[0x0:0x8]),
+				generation: binary.LittleEndian.Uint32(entryBytes[0xC:0x10]),
+			})
+		}
+	}
+	if len(entries) == 0 || entries[0].offset != 0 {
+		return nil, errors.New("invalid AesCtrEx bucket tree entries")
+	}
+	return entries, nil
+}
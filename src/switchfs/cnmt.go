@@ -5,7 +5,10 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/trembon/switch-library-manager/settings"
 )
 
 const (
@@ -35,6 +38,24 @@ type ContentMetaAttributes struct {
 	Type     string `json:"type"`
 	Contents map[string]Content
 	Ncap     *Nacp
+	Xci      *XciInfo `json:"xci,omitempty"`
+	// RequiredSystemVersion is the minimum system version of the title (0 for DLC)
+	RequiredSystemVersion uint32 `json:"required_system_version"`
+	// KeyGeneration of the NCAs of the title
+	KeyGeneration int `json:"key_generation"`
+}
+
+// RequiredFirmware returns the minimum system version needed to run the title, based on
+// both the required system version and the key generation of its NCAs
+func (c *ContentMetaAttributes) RequiredFirmware() uint32 {
+	return maxUint32(c.RequiredSystemVersion, settings.KeyGenerationSystemVersion(c.KeyGeneration))
+}
+
+func maxUint32(a uint32, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
 }
 
 type ContentMeta struct {
@@ -66,13 +87,41 @@ func readBinaryCnmt(pfs0 *PFS0, data []byte) (*ContentMetaAttributes, error) {
 	cnmt := data[int64(cnmtFile.StartOffset):]
 	titleId := binary.LittleEndian.Uint64(cnmt[0:0x8])
 	version := binary.LittleEndian.Uint32(cnmt[0x8:0xC])
+	contents := map[string]Content{}
+	for _, content := range readCnmtContents(cnmt) {
+		contents[content.Type] = content
+	}
+	metaType := ""
+	requiredSystemVersion := uint32(0)
+	switch cnmt[0xC:0xD][0] {
+	case ContentMetaType_Application:
+		metaType = "BASE"
+		requiredSystemVersion = binary.LittleEndian.Uint32(cnmt[0x28:0x2C])
+	case ContentMetaType_AddOnContent:
+		metaType = "DLC"
+	case ContentMetaType_Patch:
+		metaType = "UPD"
+		requiredSystemVersion = binary.LittleEndian.Uint32(cnmt[0x28:0x2C])
+	}
+
+	return &ContentMetaAttributes{Contents: contents, Version: int(version), TitleId: fmt.Sprintf("0%x", titleId), Type: metaType,
+		RequiredSystemVersion: requiredSystemVersion}, nil
+}
+
+// readCnmtContents reads all the content records of a binary cnmt, including the
+// SHA-256 hash and size of each NCA
+func readCnmtContents(cnmt []byte) []Content {
 	tableOffset := binary.LittleEndian.Uint16(cnmt[0xE:0x10])
 	contentEntryCount := binary.LittleEndian.Uint16(cnmt[0x10:0x12])
 	//metaEntryCount := binary.LittleEndian.Uint16(cnmt[0x12:0x14])
-	contents := map[string]Content{}
+	contents := make([]Content, 0, contentEntryCount)
 	for i := uint16(0); i < contentEntryCount; i++ {
 		position := 0x20 /*size of cnmt header*/ + tableOffset + (i * uint16(0x38))
+		hash := cnmt[position : position+0x20]
 		ncaId := cnmt[position+0x20 : position+0x20+0x10]
+		sizeBytes := make([]byte, 8)
+		copy(sizeBytes, cnmt[position+0x30:position+0x36])
+		size := binary.LittleEndian.Uint64(sizeBytes)
 		//fmt.Println(fmt.Sprintf("0%x", ncaId))
 		contentType := ""
 		switch cnmt[position+0x36 : position+0x36+1][0] {
@@ -91,19 +140,14 @@ func readBinaryCnmt(pfs0 *PFS0, data []byte) (*ContentMetaAttributes, error) {
 		case 6:
 			contentType = "DeltaFragment"
 		}
-		contents[contentType] = Content{ID: fmt.Sprintf("%x", ncaId)}
+		contents = append(contents, Content{
+			Type: contentType,
+			ID:   fmt.Sprintf("%x", ncaId),
+			Size: strconv.FormatUint(size, 10),
+			Hash: fmt.Sprintf("%x", hash),
+		})
 	}
-	metaType := ""
-	switch cnmt[0xC:0xD][0] {
-	case ContentMetaType_Application:
-		metaType = "BASE"
-	case ContentMetaType_AddOnContent:
-		metaType = "DLC"
-	case ContentMetaType_Patch:
-		metaType = "UPD"
-	}
-
-	return &ContentMetaAttributes{Contents: contents, Version: int(version), TitleId: fmt.Sprintf("0%x", titleId), Type: metaType}, nil
+	return contents
 }
 
 func readXmlCnmt(xmlBytes []byte) (*ContentMetaAttributes, error) {
@@ -113,5 +157,8 @@ func readXmlCnmt(xmlBytes []byte) (*ContentMetaAttributes, error) {
 		return nil, err
 	}
 	titleId := strings.Replace(cmt.ID, "0x", "", 1)
-	return &ContentMetaAttributes{Version: cmt.Version, TitleId: titleId, Type: cmt.Type}, nil
+	requiredSystemVersion, _ := strconv.ParseUint(cmt.RequiredSystemVersion, 10, 32)
+	keyGeneration, _ := strconv.Atoi(cmt.KeyGenerationMin)
+	return &ContentMetaAttributes{Version: cmt.Version, TitleId: titleId, Type: cmt.Type,
+		RequiredSystemVersion: uint32(requiredSystemVersion), KeyGeneration: keyGeneration}, nil
 }
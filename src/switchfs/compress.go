@@ -0,0 +1,267 @@
+package switchfs
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/trembon/switch-library-manager/settings"
+)
+
+// NCAs smaller than this are not worth compressing
+const minCompressedNcaSize = 0x100000
+
+// CompressFile converts a NSP/XCI file into a NSZ/XCZ written to outputPath. Program and public data
+// NCAs are stored as NCZ, every NCZ is decompressed again and compared with the hash of the original
+// NCA before the file is written. NCAs that cannot be decrypted (missing keys or title keys) are kept as-is.
+func CompressFile(filePath string, outputPath string) error {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	xci, err := isXci(file)
+	if err != nil {
+		return err
+	}
+
+	return createContainer(outputPath, func(cw *containerWriter) error {
+		ncaHashes := map[string][]byte{}
+		createEntries := func(partition *PFS0, partitionOffset int64) ([]*PartitionEntry, error) {
+			return compressedEntries(file, partition, partitionOffset, ncaHashes)
+		}
+		if xci {
+			err = rebuildXci(file, cw, createEntries)
+		} else {
+			err = rebuildNsp(file, cw, createEntries)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ncaHashes) == 0 {
+			return errors.New("no NCA could be compressed")
+		}
+		return verifyCompressed(cw, ncaHashes)
+	})
+}
+
+func compressedEntries(file io.ReaderAt, partition *PFS0, partitionOffset int64, ncaHashes map[string][]byte) ([]*PartitionEntry, error) {
+	loadTickets(file, partition, partitionOffset)
+
+	var entries []*PartitionEntry
+	for _, pfs0File := range partition.Files {
+		fileOffset := partitionOffset + int64(pfs0File.StartOffset)
+		fileSize := int64(pfs0File.Size)
+
+		var sections []nczSection
+		var err error = errors.New("not a NCA")
+		if strings.HasSuffix(strings.ToLower(pfs0File.Name), ".nca") && fileSize >= minCompressedNcaSize {
+			sections, err = nczSections(file, fileOffset, fileSize)
+		}
+		if err != nil {
+			entry, err := NewSectionEntry(file, fileOffset, fileSize, pfs0File.Name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		nczName := pfs0File.Name[:len(pfs0File.Name)-len(".nca")] + ".ncz"
+		entry := &PartitionEntry{
+			Name: nczName,
+			Size: -1,
+			Write: func(w io.Writer) error {
+				hash, err := compressNca(file, fileOffset, sections, w)
+				if err != nil {
+					return errors.New(pfs0File.Name + " - " + err.Error())
+				}
+				ncaHashes[nczName] = hash
+				return nil
+			},
+		}
+		// the NCZ keeps the first 0x4000 bytes of the NCA as-is
+		entry.HashedRegionSize, entry.Hash, err = hashRegion(file, fileOffset, fileSize)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// nczSections returns the sections of a NCA as stored in the NCZ header, gaps between the
+// encrypted sections are added as plain sections so the whole NCA is covered.
+func nczSections(reader io.ReaderAt, ncaOffset int64, ncaSize int64) ([]nczSection, error) {
+	encNcaHeader := make([]byte, 0xC00)
+	_, err := reader.ReadAt(encNcaHeader, ncaOffset)
+	if err != nil {
+		return nil, errors.New("failed to read NCA header " + err.Error())
+	}
+
+	keys, err := settings.SwitchKeys()
+	if err != nil || keys == nil {
+		return nil, ErrMissingKey
+	}
+	headerKey := keys.GetKey("header_key")
+	if headerKey == "" {
+		return nil, missingKeyError("header_key")
+	}
+	ncaHeader, err := DecryptNcaHeader(headerKey, encNcaHeader)
+	if err != nil {
+		return nil, err
+	}
+	if string(ncaHeader.headerBytes[0x200:0x204]) != "NCA3" {
+		return nil, errors.New("unsupported NCA version")
+	}
+	if ncaHeader.contentType != NcaContentType_Program && ncaHeader.contentType != NcaContentType_PublicData {
+		return nil, errors.New("content type is not compressed")
+	}
+	key, err := getNcaCtrKey(ncaHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []nczSection
+	for i := 0; i < 4; i++ {
+		entry := getFsEntry(ncaHeader, i)
+		if entry.Size == 0 {
+			continue
+		}
+		fsHeader, err := getFsHeader(ncaHeader, i)
+		if err != nil {
+			return nil, err
+		}
+		section := nczSection{offset: int64(entry.StartOffset), size: int64(entry.Size), cryptoType: uint64(fsHeader.encType),
+			cryptoKey: make([]byte, 0x10), cryptoCounter: make([]byte, 0x10)}
+		switch fsHeader.encType {
+		case 1:
+		case 3, 4:
+			copy(section.cryptoKey, key)
+			copy(section.cryptoCounter, fsHeader.getCounter(0)[:0x8])
+		default:
+			return nil, fmt.Errorf("unsupported encryption type %v", fsHeader.encType)
+		}
+		sections = append(sections, section)
+	}
+	slices.SortFunc(sections, func(a, b nczSection) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	var result []nczSection
+	pos := int64(nczHeaderSize)
+	plainSection := func(offset int64, size int64) nczSection {
+		return nczSection{offset: offset, size: size, cryptoType: 1, cryptoKey: make([]byte, 0x10), cryptoCounter: make([]byte, 0x10)}
+	}
+	for _, section := range sections {
+		end := section.offset + section.size
+		if end <= pos {
+			continue
+		}
+		if section.offset < pos {
+			section.size = end - pos
+			section.offset = pos
+		}
+		if section.offset > pos {
+			result = append(result, plainSection(pos, section.offset-pos))
+		}
+		result = append(result, section)
+		pos = end
+	}
+	if pos > ncaSize {
+		return nil, errors.New("NCA sections exceed the NCA size")
+	}
+	if pos < ncaSize {
+		result = append(result, plainSection(pos, ncaSize-pos))
+	}
+	return result, nil
+}
+
+// compressNca writes the NCZ of a NCA to w and returns the SHA-256 of the original NCA,
+// the data after the NCA header is decrypted and stored as a single zstd stream.
+func compressNca(reader io.ReaderAt, ncaOffset int64, sections []nczSection, w io.Writer) ([]byte, error) {
+	hash := sha256.New()
+	_, err := io.Copy(io.MultiWriter(w, hash), io.NewSectionReader(reader, ncaOffset, nczHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	header.WriteString(nczSectionMagic)
+	binary.Write(&header, binary.LittleEndian, uint64(len(sections)))
+	for _, section := range sections {
+		binary.Write(&header, binary.LittleEndian, []uint64{uint64(section.offset), uint64(section.size), section.cryptoType, 0})
+		header.Write(section.cryptoKey)
+		header.Write(section.cryptoCounter)
+	}
+	_, err = w.Write(header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		var data io.Reader = io.TeeReader(io.NewSectionReader(reader, ncaOffset+section.offset, section.size), hash)
+		if section.cryptoType == 3 || section.cryptoType == 4 {
+			block, err := aes.NewCipher(section.cryptoKey)
+			if err != nil {
+				encoder.Close()
+				return nil, err
+			}
+			ctr := make([]byte, 0x10)
+			copy(ctr, section.cryptoCounter[:0x8])
+			binary.BigEndian.PutUint64(ctr[0x8:], uint64(section.offset>>4))
+			data = &cipher.StreamReader{S: cipher.NewCTR(block, ctr), R: data}
+		}
+		n, err := io.Copy(encoder, data)
+		if err == nil && n != section.size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			encoder.Close()
+			return nil, err
+		}
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// verifyCompressed decompresses the NCZ entries of the written file and compares them with the original NCA hashes
+func verifyCompressed(file io.ReaderAt, ncaHashes map[string][]byte) error {
+	partition, partitionOffset, err := openContentPartition(file)
+	if err != nil {
+		return err
+	}
+	for _, pfs0File := range partition.Files {
+		expectedHash, ok := ncaHashes[pfs0File.Name]
+		if !ok {
+			continue
+		}
+		hash := sha256.New()
+		err = decompressNcz(file, partitionOffset+int64(pfs0File.StartOffset), int64(pfs0File.Size), hash)
+		if err != nil {
+			return errors.New(pfs0File.Name + " - failed to verify - " + err.Error())
+		}
+		if !bytes.Equal(hash.Sum(nil), expectedHash) {
+			return errors.New(pfs0File.Name + " - compressed NCA hash mismatch")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package switchfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// DecompressFile converts an NSZ/XCZ file into a NSP/XCI written to outputPath,
+// every NCZ entry is restored to the original NCA and checked against its content id.
+func DecompressFile(filePath string, outputPath string) error {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	xci, err := isXci(file)
+	if err != nil {
+		return err
+	}
+
+	return createContainer(outputPath, func(cw *containerWriter) error {
+		createEntries := func(partition *PFS0, partitionOffset int64) ([]*PartitionEntry, error) {
+			return decompressedEntries(file, partition, partitionOffset)
+		}
+		if xci {
+			return rebuildXci(file, cw, createEntries)
+		}
+		return rebuildNsp(file, cw, createEntries)
+	})
+}
+
+func decompressedEntries(file io.ReaderAt, partition *PFS0, partitionOffset int64) ([]*PartitionEntry, error) {
+	var entries []*PartitionEntry
+	for _, pfs0File := range partition.Files {
+		fileOffset := partitionOffset + int64(pfs0File.StartOffset)
+		fileSize := int64(pfs0File.Size)
+
+		if !strings.HasSuffix(strings.ToLower(pfs0File.Name), ".ncz") {
+			entry, err := NewSectionEntry(file, fileOffset, fileSize, pfs0File.Name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		nczHeader, err := readNczHeader(file, fileOffset)
+		if err != nil {
+			return nil, errors.New(pfs0File.Name + " - " + err.Error())
+		}
+		ncaName := pfs0File.Name[:len(pfs0File.Name)-len(".ncz")] + ".nca"
+		entry := &PartitionEntry{
+			Name: ncaName,
+			Size: nczHeader.ncaSize(),
+			Write: func(w io.Writer) error {
+				hash := sha256.New()
+				err := decompressNcz(file, fileOffset, fileSize, io.MultiWriter(w, hash))
+				if err != nil {
+					return errors.New(pfs0File.Name + " - " + err.Error())
+				}
+				ncaId := strings.ToLower(strings.TrimSuffix(ncaName, ".nca"))
+				if len(ncaId) == 32 && hex.EncodeToString(hash.Sum(nil)[:0x10]) != ncaId {
+					return errors.New(pfs0File.Name + " - decompressed NCA hash mismatch")
+				}
+				return nil
+			},
+		}
+		// the NCZ keeps the first 0x4000 bytes of the NCA as-is
+		entry.HashedRegionSize, entry.Hash, err = hashRegion(file, fileOffset, entry.Size)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -13,12 +13,13 @@ type fsHeader struct {
 	hashType      byte // (0 = Auto, 2 = HierarchicalSha256, 3 = HierarchicalIntegrity (Ivfc))
 	fsHeaderBytes []byte
 	generation    uint32
+	sectionCtr    uint64
 }
 
 type fsEntry struct {
-	StartOffset uint32
-	EndOffset   uint32
-	Size        uint32
+	StartOffset uint64
+	EndOffset   uint64
+	Size        uint64
 }
 
 type hashInfo struct {
@@ -30,8 +31,8 @@ func getFsEntry(ncaHeader *ncaHeader, index int) fsEntry {
 	fsEntryOffset := 0x240 + 0x10*index
 	fsEntryBytes := ncaHeader.headerBytes[fsEntryOffset : fsEntryOffset+0x10]
 
-	entryStartOffset := binary.LittleEndian.Uint32(fsEntryBytes[0x0:0x4]) * 0x200
-	entryEndOffset := binary.LittleEndian.Uint32(fsEntryBytes[0x4:0x8]) * 0x200
+	entryStartOffset := uint64(binary.LittleEndian.Uint32(fsEntryBytes[0x0:0x4])) * 0x200
+	entryEndOffset := uint64(binary.LittleEndian.Uint32(fsEntryBytes[0x4:0x8])) * 0x200
 
 	return fsEntry{StartOffset: entryStartOffset, EndOffset: entryEndOffset, Size: entryEndOffset - entryStartOffset}
 }
@@ -57,6 +58,7 @@ func getFsHeader(ncaHeader *ncaHeader, index int) (*fsHeader, error) {
 
 	generationBytes := fsHeaderBytes[0x140 : 0x140+0x4] //generation
 	result.generation = binary.LittleEndian.Uint32(generationBytes)
+	result.sectionCtr = binary.LittleEndian.Uint64(fsHeaderBytes[0x140 : 0x140+0x8]) //generation + secure value
 
 	return &result, nil
 }
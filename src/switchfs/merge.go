@@ -0,0 +1,75 @@
+package switchfs
+
+import (
+	"errors"
+	"io"
+)
+
+// MergeFiles writes the content of several NSP/XCI (or NSZ/XCZ) files into a single multi-content file.
+// When the first file is a XCI the output is a XCI with the content of the other files added to its
+// secure partition, otherwise a NSP holding all the files. Entries with the same name are only added once.
+func MergeFiles(filePaths []string, outputPath string) error {
+	if len(filePaths) == 0 {
+		return errors.New("no files to merge")
+	}
+
+	var files []io.ReaderAt
+	for _, filePath := range filePaths {
+		file, err := OpenFile(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+
+	xci, err := isXci(files[0])
+	if err != nil {
+		return err
+	}
+
+	// entries of the first file, followed by the content of the other files
+	createEntries := func(partition *PFS0, partitionOffset int64) ([]*PartitionEntry, error) {
+		names := map[string]struct{}{}
+		entries, err := mergedEntries(files[0], partition, partitionOffset, names)
+		if err != nil {
+			return nil, err
+		}
+		for i, file := range files[1:] {
+			contentPartition, contentOffset, err := openContentPartition(file)
+			if err != nil {
+				return nil, errors.New(filePaths[i+1] + " - " + err.Error())
+			}
+			fileEntries, err := mergedEntries(file, contentPartition, contentOffset, names)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, fileEntries...)
+		}
+		return entries, nil
+	}
+
+	return createContainer(outputPath, func(cw *containerWriter) error {
+		if xci {
+			return rebuildXci(files[0], cw, createEntries)
+		}
+		return rebuildNsp(files[0], cw, createEntries)
+	})
+}
+
+func mergedEntries(file io.ReaderAt, partition *PFS0, partitionOffset int64, names map[string]struct{}) ([]*PartitionEntry, error) {
+	var entries []*PartitionEntry
+	for _, pfs0File := range partition.Files {
+		if _, ok := names[pfs0File.Name]; ok {
+			continue
+		}
+		names[pfs0File.Name] = struct{}{}
+
+		entry, err := NewSectionEntry(file, partitionOffset+int64(pfs0File.StartOffset), int64(pfs0File.Size), pfs0File.Name)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -1,6 +1,7 @@
 package switchfs
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
@@ -24,18 +25,135 @@ const (
 	Korean
 	Taiwanese
 	Chinese
+	BrazilianPortuguese
 )
 
 type NacpTitle struct {
-	Language Language
-	Title    string
+	Language  Language
+	Title     string
+	Publisher string
 }
 
+// RatingOrganization is the index of an age rating in Nacp.RatingAge
+type RatingOrganization int
+
+const (
+	RatingCERO RatingOrganization = iota
+	RatingGRACGCRB
+	RatingGSRMR
+	RatingESRB
+	RatingClassInd
+	RatingUSK
+	RatingPEGI
+	RatingPEGIPortugal
+	RatingPEGIBBFC
+	RatingRussian
+	RatingACB
+	RatingOFLC
+	RatingIARCGeneric
+)
+
+const (
+	nacpSize          = 0x4000
+	nacpNotRated int8 = -1
+)
+
 type Nacp struct {
 	TitleName             map[string]NacpTitle
 	Isbn                  string
 	DisplayVersion        string
 	SupportedLanguageFlag uint32
+
+	StartupUserAccount           uint8
+	UserAccountSwitchLock        uint8
+	AddOnContentRegistrationType uint8
+	AttributeFlag                uint32
+	ParentalControlFlag          uint32
+	Screenshot                   uint8 // 0 allowed, 1 denied
+	VideoCapture                 uint8 // 0 disabled, 1 manual, 2 enabled
+	DataLossConfirmation         uint8
+	PlayLogPolicy                uint8
+	PresenceGroupId              uint64
+	RatingAge                    map[string]int8 // age per rating organization, organizations not rating the title are left out
+	AddOnContentBaseId           uint64
+	SaveDataOwnerId              uint64
+	ApplicationErrorCodeCategory string
+	LocalCommunicationId         []uint64
+	LogoType                     uint8
+	LogoHandling                 uint8 // 0 auto, 1 manual
+	RuntimeAddOnContentInstall   uint8
+	CrashReport                  uint8
+	Hdcp                         uint8
+	SeedForPseudoDeviceId        uint64
+	BcatPassphrase               string
+	StartupUserAccountOption     uint8
+
+	UserAccountSaveDataSize           int64
+	UserAccountSaveDataJournalSize    int64
+	DeviceSaveDataSize                int64
+	DeviceSaveDataJournalSize         int64
+	BcatDeliveryCacheStorageSize      int64
+	UserAccountSaveDataSizeMax        int64
+	UserAccountSaveDataJournalSizeMax int64
+	DeviceSaveDataSizeMax             int64
+	DeviceSaveDataJournalSizeMax      int64
+	TemporaryStorageSize              int64
+	CacheStorageSize                  int64
+	CacheStorageJournalSize           int64
+	CacheStorageDataAndJournalSizeMax int64
+	CacheStorageIndexMax              uint16
+
+	PlayLogQueryableApplicationId []uint64
+	PlayLogQueryCapability        uint8
+	RepairFlag                    uint8
+	ProgramIndex                  uint8
+
+	// Icons holds the JPEG icons of the control NCA per language, left out of json. The scan
+	// cache is gob encoded, which ignores the tag, so saveIcons clears them once they are
+	// stored in the icon cache
+	Icons map[string][]byte `json:"-"`
+}
+
+// GetTitleName returns the title name in the first of the languages with a name, empty when none has one
+func (n *Nacp) GetTitleName(languages []string) string {
+	for _, language := range languages {
+		if title, ok := n.TitleName[language]; ok && title.Title != "" {
+			return title.Title
+		}
+	}
+	return ""
+}
+
+// UserTotalSaveDataSize returns the size of the save data created for each user account
+func (n *Nacp) UserTotalSaveDataSize() int64 {
+	return n.UserAccountSaveDataSize + n.UserAccountSaveDataJournalSize
+}
+
+// DeviceTotalSaveDataSize returns the size of the save data shared by all user accounts
+func (n *Nacp) DeviceTotalSaveDataSize() int64 {
+	return n.DeviceSaveDataSize + n.DeviceSaveDataJournalSize
+}
+
+// TotalSaveDataSize returns the size of the save data for a single user account
+func (n *Nacp) TotalSaveDataSize() int64 {
+	return n.UserTotalSaveDataSize() + n.DeviceTotalSaveDataSize()
+}
+
+func (r RatingOrganization) String() string {
+	return [...]string{
+		"CERO",
+		"GRACGCRB",
+		"GSRMR",
+		"ESRB",
+		"ClassInd",
+		"USK",
+		"PEGI",
+		"PEGIPortugal",
+		"PEGIBBFC",
+		"Russian",
+		"ACB",
+		"OFLC",
+		"IARCGeneric"}[r]
 }
 
 func (l Language) String() string {
@@ -55,7 +173,7 @@ func (l Language) String() string {
 		"Korean",
 		"Taiwanese",
 		"Chinese",
-		"Chinese"}[l]
+		"BrazilianPortuguese"}[l]
 }
 
 func ExtractNacp(cnmt *ContentMetaAttributes, file io.ReaderAt, securePartition *PFS0, securePartitionOffset int64) (*Nacp, error) {
@@ -81,6 +199,7 @@ func ExtractNacp(cnmt *ContentMetaAttributes, file io.ReaderAt, securePartition
 					if err != nil {
 						return nil, err
 					}
+					nacp.Icons = readNacpIcons(section, romFsHeader, fEntries)
 					return &nacp, nil
 				}
 			} else {
@@ -97,96 +216,113 @@ func ExtractNacp(cnmt *ContentMetaAttributes, file io.ReaderAt, securePartition
 /*https://switchbrew.org/wiki/NACP_Format*/
 func readNacp(data []byte, romFsHeader RomfsHeader, fileEntry RomfsFileEntry) (Nacp, error) {
 	offset := romFsHeader.DataOffset + fileEntry.offset
+	if offset+nacpSize > uint64(len(data)) {
+		return Nacp{}, errors.New("control.nacp is truncated")
+	}
+	nacp := data[offset : offset+nacpSize]
+
 	titles := map[string]NacpTitle{}
 	for i := 0; i < 16; i++ {
-		//lang := i
-		appTitleBytes := data[offset+(uint64(i)*0x300) : offset+(uint64(i)*0x300)+0x200]
-		nameBytes := readBytesUntilZero(appTitleBytes)
-		titles[Language(i).String()] = NacpTitle{Language: Language(i), Title: string(nameBytes)}
+		entry := nacp[i*0x300 : i*0x300+0x300]
+		titles[Language(i).String()] = NacpTitle{
+			Language:  Language(i),
+			Title:     string(readBytesUntilZero(entry[:0x200])),
+			Publisher: string(readBytesUntilZero(entry[0x200:])),
+		}
+	}
+
+	ratingAge := map[string]int8{}
+	for i := RatingCERO; i <= RatingIARCGeneric; i++ {
+		if age := int8(nacp[0x3040+int(i)]); age != nacpNotRated {
+			ratingAge[i.String()] = age
+		}
 	}
 
-	isbn := readBytesUntilZero(data[offset+0x3000 : offset+0x3000+0x25])
-	displayVersion := readBytesUntilZero(data[offset+0x3060 : offset+0x3060+0x10])
-	supportedLanguageFlag := binary.BigEndian.Uint32(data[offset+0x302C : offset+0x302C+0x4])
-
-	return Nacp{TitleName: titles, Isbn: string(isbn), DisplayVersion: string(displayVersion), SupportedLanguageFlag: supportedLanguageFlag}, nil
-	/*
-
-
-	   Isbn = reader.ReadUtf8Z(37);
-	   reader.BaseStream.Position = start + 0x3025;
-	   StartupUserAccount = reader.ReadByte();
-	   UserAccountSwitchLock = reader.ReadByte();
-	   AocRegistrationType = reader.ReadByte();
-	   AttributeFlag = reader.ReadInt32();
-	   supportedLanguageFlag = reader.ReadUInt32();
-	   ParentalControlFlag = reader.ReadUInt32();
-	   Screenshot = reader.ReadByte();
-	   VideoCapture = reader.ReadByte();
-	   DataLossConfirmation = reader.ReadByte();
-	   PlayLogPolicy = reader.ReadByte();
-	   PresenceGroupId = reader.ReadUInt64();
-
-	   for (int i = 0; i < RatingAge.Length; i++)
-	   {
-	       RatingAge[i] = reader.ReadSByte();
-	   }
-
-	   DisplayVersion = reader.ReadUtf8Z(16);
-	   reader.BaseStream.Position = start + 0x3070;
-	   AddOnContentBaseId = reader.ReadUInt64();
-	   SaveDataOwnerId = reader.ReadUInt64();
-	   UserAccountSaveDataSize = reader.ReadInt64();
-	   UserAccountSaveDataJournalSize = reader.ReadInt64();
-	   DeviceSaveDataSize = reader.ReadInt64();
-	   DeviceSaveDataJournalSize = reader.ReadInt64();
-	   BcatDeliveryCacheStorageSize = reader.ReadInt64();
-	   ApplicationErrorCodeCategory = reader.ReadUtf8Z(8);
-	   reader.BaseStream.Position = start + 0x30B0;
-
-	   for (int i = 0; i < LocalCommunicationId.Length; i++)
-	   {
-	       LocalCommunicationId[i] = reader.ReadUInt64();
-	   }
-
-	   LogoType = reader.ReadByte();
-	   LogoHandling = reader.ReadByte();
-	   RuntimeAddOnContentInstall = reader.ReadByte();
-	   Reserved00 = reader.ReadBytes(3);
-	   CrashReport = reader.ReadByte();
-	   Hdcp = reader.ReadByte();
-	   SeedForPseudoDeviceId = reader.ReadUInt64();
-	   BcatPassphrase = reader.ReadUtf8Z(65);
-
-	   reader.BaseStream.Position = start + 0x3141;
-	   Reserved01 = reader.ReadByte();
-	   Reserved02 = reader.ReadBytes(6);
-
-	   UserAccountSaveDataSizeMax = reader.ReadInt64();
-	   UserAccountSaveDataJournalSizeMax = reader.ReadInt64();
-	   DeviceSaveDataSizeMax = reader.ReadInt64();
-	   DeviceSaveDataJournalSizeMax = reader.ReadInt64();
-	   TemporaryStorageSize = reader.ReadInt64();
-	   CacheStorageSize = reader.ReadInt64();
-	   CacheStorageJournalSize = reader.ReadInt64();
-	   CacheStorageDataAndJournalSizeMax = reader.ReadInt64();
-	   CacheStorageIndex = reader.ReadInt16();
-	   Reserved03 = reader.ReadBytes(6);
-
-	   for (int i = 0; i < 16; i++)
-	   {
-	       ulong value = reader.ReadUInt64();
-	       if (value != 0) PlayLogQueryableApplicationId.Add(value);
-	   }
-
-	   PlayLogQueryCapability = reader.ReadByte();
-	   RepairFlag = reader.ReadByte();
-	   ProgramIndex = reader.ReadByte();
-
-	   UserTotalSaveDataSize = UserAccountSaveDataSize + UserAccountSaveDataJournalSize;
-	   DeviceTotalSaveDataSize = DeviceSaveDataSize + DeviceSaveDataJournalSize;
-	   TotalSaveDataSize = UserTotalSaveDataSize + DeviceTotalSaveDataSize;
-	*/
+	return Nacp{
+		TitleName:                    titles,
+		Isbn:                         string(readBytesUntilZero(nacp[0x3000:0x3025])),
+		StartupUserAccount:           nacp[0x3025],
+		UserAccountSwitchLock:        nacp[0x3026],
+		AddOnContentRegistrationType: nacp[0x3027],
+		AttributeFlag:                binary.LittleEndian.Uint32(nacp[0x3028:0x302C]),
+		SupportedLanguageFlag:        binary.LittleEndian.Uint32(nacp[0x302C:0x3030]),
+		ParentalControlFlag:          binary.LittleEndian.Uint32(nacp[0x3030:0x3034]),
+		Screenshot:                   nacp[0x3034],
+		VideoCapture:                 nacp[0x3035],
+		DataLossConfirmation:         nacp[0x3036],
+		PlayLogPolicy:                nacp[0x3037],
+		PresenceGroupId:              binary.LittleEndian.Uint64(nacp[0x3038:0x3040]),
+		RatingAge:                    ratingAge,
+		DisplayVersion:               string(readBytesUntilZero(nacp[0x3060:0x3070])),
+		AddOnContentBaseId:           binary.LittleEndian.Uint64(nacp[0x3070:0x3078]),
+		SaveDataOwnerId:              binary.LittleEndian.Uint64(nacp[0x3078:0x3080]),
+
+		UserAccountSaveDataSize:        readNacpInt64(nacp, 0x3080),
+		UserAccountSaveDataJournalSize: readNacpInt64(nacp, 0x3088),
+		DeviceSaveDataSize:             readNacpInt64(nacp, 0x3090),
+		DeviceSaveDataJournalSize:      readNacpInt64(nacp, 0x3098),
+		BcatDeliveryCacheStorageSize:   readNacpInt64(nacp, 0x30A0),
+
+		ApplicationErrorCodeCategory: string(readBytesUntilZero(nacp[0x30A8:0x30B0])),
+		LocalCommunicationId:         readNacpIds(nacp[0x30B0:0x30F0]),
+		LogoType:                     nacp[0x30F0],
+		LogoHandling:                 nacp[0x30F1],
+		RuntimeAddOnContentInstall:   nacp[0x30F2],
+		CrashReport:                  nacp[0x30F6],
+		Hdcp:                         nacp[0x30F7],
+		SeedForPseudoDeviceId:        binary.LittleEndian.Uint64(nacp[0x30F8:0x3100]),
+		BcatPassphrase:               string(readBytesUntilZero(nacp[0x3100:0x3141])),
+		StartupUserAccountOption:     nacp[0x3141],
+
+		UserAccountSaveDataSizeMax:        readNacpInt64(nacp, 0x3148),
+		UserAccountSaveDataJournalSizeMax: readNacpInt64(nacp, 0x3150),
+		DeviceSaveDataSizeMax:             readNacpInt64(nacp, 0x3158),
+		DeviceSaveDataJournalSizeMax:      readNacpInt64(nacp, 0x3160),
+		TemporaryStorageSize:              readNacpInt64(nacp, 0x3168),
+		CacheStorageSize:                  readNacpInt64(nacp, 0x3170),
+		CacheStorageJournalSize:           readNacpInt64(nacp, 0x3178),
+		CacheStorageDataAndJournalSizeMax: readNacpInt64(nacp, 0x3180),
+		CacheStorageIndexMax:              binary.LittleEndian.Uint16(nacp[0x3188:0x318A]),
+
+		PlayLogQueryableApplicationId: readNacpIds(nacp[0x3190:0x3210]),
+		PlayLogQueryCapability:        nacp[0x3210],
+		RepairFlag:                    nacp[0x3211],
+		ProgramIndex:                  nacp[0x3212],
+	}, nil
+}
+
+// readNacpIcons reads the icon_<Language>.dat files stored next to control.nacp
+func readNacpIcons(data []byte, romFsHeader RomfsHeader, fileEntries map[string]RomfsFileEntry) map[string][]byte {
+	icons := map[string][]byte{}
+	for i := AmericanEnglish; i <= BrazilianPortuguese; i++ {
+		language := Language(i).String()
+		entry, ok := fileEntries["icon_"+language+".dat"]
+		if !ok || entry.size == 0 {
+			continue
+		}
+		offset := romFsHeader.DataOffset + entry.offset
+		if offset+entry.size > uint64(len(data)) {
+			continue
+		}
+		// copied, so the decrypted section is not kept in memory
+		icons[language] = bytes.Clone(data[offset : offset+entry.size])
+	}
+	return icons
+}
+
+func readNacpInt64(nacp []byte, offset int) int64 {
+	return int64(binary.LittleEndian.Uint64(nacp[offset : offset+0x8]))
+}
+
+// readNacpIds reads a list of 64 bit ids, leaving out the unused (zero) entries
+func readNacpIds(data []byte) []uint64 {
+	var ids []uint64
+	for i := 0; i+0x8 <= len(data); i += 0x8 {
+		if id := binary.LittleEndian.Uint64(data[i : i+0x8]); id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
 }
 
 func readBytesUntilZero(appTitleBytes []byte) []byte {
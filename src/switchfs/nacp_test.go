@@ -0,0 +1,68 @@
+package switchfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestReadNacp(t *testing.T) {
+	romFsHeader := RomfsHeader{DataOffset: 0x10}
+	entry := RomfsFileEntry{offset: 0x20, size: nacpSize}
+	data := make([]byte, 0x30+nacpSize)
+	nacp := data[0x30:]
+
+	copy(nacp[int(Japanese)*0x300:], "ゲーム")
+	copy(nacp[int(Japanese)*0x300+0x200:], "任天堂")
+	// the last entry, filled up to the end of its publisher
+	copy(nacp[int(BrazilianPortuguese)*0x300:], bytes.Repeat([]byte{'t'}, 0x200))
+	copy(nacp[int(BrazilianPortuguese)*0x300+0x200:], bytes.Repeat([]byte{'p'}, 0x100))
+	copy(nacp[0x3000:], "ISBN-1")
+	binary.LittleEndian.PutUint32(nacp[0x302C:], 0x5)
+	for i := RatingCERO; i <= RatingIARCGeneric; i++ {
+		nacp[0x3040+int(i)] = 0xFF
+	}
+	nacp[0x3040+int(RatingPEGI)] = 12
+	copy(nacp[0x3060:], "1.2.3")
+	binary.LittleEndian.PutUint64(nacp[0x3070:], 0x0100000000001000)
+	binary.LittleEndian.PutUint64(nacp[0x3080:], 0x1000)
+	binary.LittleEndian.PutUint64(nacp[0x3088:], 0x2000)
+	binary.LittleEndian.PutUint64(nacp[0x3098:], 0x4000)
+	binary.LittleEndian.PutUint64(nacp[0x30B8:], 0x0100000000000001)
+	binary.LittleEndian.PutUint16(nacp[0x3188:], 7)
+	nacp[0x3212] = 2
+
+	result, err := readNacp(data, romFsHeader, entry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if title := result.TitleName["Japanese"]; title.Title != "ゲーム" || title.Publisher != "任天堂" {
+		t.Errorf("unexpected Japanese title %+v", title)
+	}
+	if title := result.TitleName["BrazilianPortuguese"]; len(title.Title) != 0x200 || title.Publisher != string(bytes.Repeat([]byte{'p'}, 0x100)) {
+		t.Errorf("unexpected BrazilianPortuguese title %v (%v) / %v (%v)", title.Title, len(title.Title), title.Publisher, len(title.Publisher))
+	}
+	if title := result.TitleName["AmericanEnglish"]; title.Title != "" || title.Publisher != "" {
+		t.Errorf("expected an empty AmericanEnglish title, got %+v", title)
+	}
+	if result.Isbn != "ISBN-1" || result.SupportedLanguageFlag != 0x5 || result.DisplayVersion != "1.2.3" {
+		t.Errorf("unexpected isbn, language flag or version %v %v %v", result.Isbn, result.SupportedLanguageFlag, result.DisplayVersion)
+	}
+	if !reflect.DeepEqual(result.RatingAge, map[string]int8{"PEGI": 12}) {
+		t.Errorf("unexpected ratings %v", result.RatingAge)
+	}
+	if result.AddOnContentBaseId != 0x0100000000001000 || result.CacheStorageIndexMax != 7 || result.ProgramIndex != 2 {
+		t.Errorf("unexpected ids %+v", result)
+	}
+	if result.UserTotalSaveDataSize() != 0x3000 || result.TotalSaveDataSize() != 0x7000 {
+		t.Errorf("unexpected save data sizes %v %v", result.UserTotalSaveDataSize(), result.TotalSaveDataSize())
+	}
+	if !reflect.DeepEqual(result.LocalCommunicationId, []uint64{0x0100000000000001}) {
+		t.Errorf("unexpected local communication ids %v", result.LocalCommunicationId)
+	}
+
+	if _, err = readNacp(data[:len(data)-1], romFsHeader, entry); err == nil {
+		t.Errorf("expected an error for a truncated control.nacp")
+	}
+}
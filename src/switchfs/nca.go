@@ -27,6 +27,20 @@ const (
 	NcaContentType_PublicData
 )
 
+// ErrMissingKey is returned (wrapped) when a file cannot be decrypted with the loaded keys
+var ErrMissingKey = errors.New("missing key")
+
+// ErrUnsupportedNca0 is returned for the NCA0 (pre-release) files, whose key area is RSA encrypted with a key
+// which is not part of prod.keys. It wraps ErrMissingKey, the files are reported with the missing keys.
+var ErrUnsupportedNca0 = fmt.Errorf("%w - NCA0 key area (RSA encrypted) is not supported", ErrMissingKey)
+
+func missingKeyError(keyName string) error {
+	return fmt.Errorf("%w - %v", ErrMissingKey, keyName)
+}
+
+// key area encryption keys by NCA crypto type
+var keyAreaKeyTypes = []string{"application", "ocean", "system"}
+
 func openMetaNcaDataSection(reader io.ReaderAt, ncaOffset int64) (*fsHeader, []byte, error) {
 	//read the NCA headerBytes
 	encNcaHeader := make([]byte, 0xC00)
@@ -43,20 +57,18 @@ func openMetaNcaDataSection(reader io.ReaderAt, ncaOffset int64) (*fsHeader, []b
 	if err != nil {
 		return nil, nil, err
 	}
+	if keys == nil {
+		return nil, nil, ErrMissingKey
+	}
 	headerKey := keys.GetKey("header_key")
 	if headerKey == "" {
-		return nil, nil, errors.New("missing key - header_key")
+		return nil, nil, missingKeyError("header_key")
 	}
 	ncaHeader, err := DecryptNcaHeader(headerKey, encNcaHeader)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if ncaHeader.HasRightsId() {
-		//fail - need title keys
-		return nil, nil, errors.New("non standard encryption is not supported")
-	}
-
 	/*if ncaHeader.contentType != NcaContentType_Meta {
 		return nil, errors.New("not a meta NCA")
 	}*/
@@ -80,14 +92,11 @@ func openMetaNcaDataSection(reader io.ReaderAt, ncaOffset int64) (*fsHeader, []b
 	if err != nil {
 		return nil, nil, err
 	}
-	if fsHeader.encType != 3 {
-		return nil, nil, errors.New("non supported encryption type [encryption type:" + string(fsHeader.encType))
-	}
 
 	/*if fsHeader.hashType != 2 { //Sha256 (FS_TYPE_PFS0)
 		return nil, errors.New("non FS_TYPE_PFS0")
 	}*/
-	decoded, err := decryptAesCtr(ncaHeader, fsHeader, entry.StartOffset, entry.Size, encodedEntryContent)
+	decoded, err := decryptSection(ncaHeader, fsHeader, entry, encodedEntryContent)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -99,35 +108,89 @@ func openMetaNcaDataSection(reader io.ReaderAt, ncaOffset int64) (*fsHeader, []b
 	return fsHeader, decoded[hashInfo.pfs0HeaderOffset:], nil
 }
 
-func decryptAesCtr(ncaHeader *ncaHeader, fsHeader *fsHeader, offset uint32, size uint32, encoded []byte) ([]byte, error) {
-	keyRevision := string(ncaHeader.getKeyRevision())
-	cryptoType := ncaHeader.cryptoType
-
-	if cryptoType != 0 {
-		return []byte{}, errors.New("unsupported crypto type")
+// readNcaKeyGeneration returns the key generation from the header of a NCA
+func readNcaKeyGeneration(reader io.ReaderAt, ncaOffset int64) (int, error) {
+	encNcaHeader := make([]byte, 0xC00)
+	_, err := reader.ReadAt(encNcaHeader, ncaOffset)
+	if err != nil {
+		return 0, errors.New("failed to read NCA header " + err.Error())
 	}
-
 	keys, _ := settings.SwitchKeys()
-
-	keyName := fmt.Sprintf("key_area_key_application_%x", keyRevision)
-	KeyString := keys.GetKey(keyName)
-	if KeyString == "" {
-		return nil, errors.New(fmt.Sprintf("missing Key_area_key[%v]", keyName))
+	if keys == nil {
+		return 0, ErrMissingKey
 	}
-	key, _ := hex.DecodeString(KeyString)
+	ncaHeader, err := DecryptNcaHeader(keys.GetKey("header_key"), encNcaHeader)
+	if err != nil {
+		return 0, err
+	}
+	return int(max(ncaHeader.keyGeneration1, ncaHeader.keyGeneration2)), nil
+}
 
-	decKey := _crypto.DecryptAes128Ecb(ncaHeader.encryptedKeys[0x20:0x30], key)
+// decryptSection decrypts the content of a NCA section based on its encryption type
+func decryptSection(ncaHeader *ncaHeader, fsHeader *fsHeader, entry fsEntry, encoded []byte) ([]byte, error) {
+	switch fsHeader.encType {
+	case 1: //None
+		return encoded, nil
+	case 3: //AesCtr
+		return decryptAesCtr(ncaHeader, fsHeader, entry.StartOffset, entry.Size, encoded)
+	case 4: //AesCtrEx
+		return decryptAesCtrEx(ncaHeader, fsHeader, entry, encoded)
+	}
+	return nil, fmt.Errorf("non supported encryption type [encryption type:%v]", fsHeader.encType)
+}
 
-	counter := make([]byte, 0x10)
-	binary.BigEndian.PutUint64(counter, uint64(fsHeader.generation))
-	binary.BigEndian.PutUint64(counter[8:], uint64(offset/0x10))
+func decryptAesCtr(ncaHeader *ncaHeader, fsHeader *fsHeader, offset uint64, size uint64, encoded []byte) ([]byte, error) {
+	decKey, err := getNcaCtrKey(ncaHeader)
+	if err != nil {
+		return nil, err
+	}
 
 	c, _ := aes.NewCipher(decKey)
 
 	decContent := make([]byte, size)
 
-	s := cipher.NewCTR(c, counter)
+	s := cipher.NewCTR(c, fsHeader.getCounter(offset))
 	s.XORKeyStream(decContent, encoded[0:size])
 
 	return decContent, nil
 }
+
+// getNcaCtrKey decrypts the AES-CTR key from the key area of the NCA header,
+// or the title key when the NCA uses rights id based encryption
+func getNcaCtrKey(ncaHeader *ncaHeader) ([]byte, error) {
+	if ncaHeader.HasRightsId() {
+		return getNcaTitleKey(ncaHeader)
+	}
+
+	keyRevision := ncaHeader.getKeyRevision()
+	cryptoType := ncaHeader.cryptoType
+
+	if ncaHeader.version == "NCA0" {
+		return nil, ErrUnsupportedNca0
+	}
+	if int(cryptoType) >= len(keyAreaKeyTypes) {
+		return nil, fmt.Errorf("unsupported key area crypto type [%v]", cryptoType)
+	}
+
+	keys, _ := settings.SwitchKeys()
+	if keys == nil {
+		return nil, ErrMissingKey
+	}
+
+	keyName := fmt.Sprintf("key_area_key_%v_%02x", keyAreaKeyTypes[cryptoType], keyRevision)
+	KeyString := keys.GetKey(keyName)
+	if KeyString == "" {
+		return nil, missingKeyError(keyName)
+	}
+	key, _ := hex.DecodeString(KeyString)
+
+	return _crypto.DecryptAes128Ecb(ncaHeader.encryptedKeys[0x20:0x30], key), nil
+}
+
+// getCounter returns the AES-CTR counter of the section at the given NCA offset
+func (fh *fsHeader) getCounter(offset uint64) []byte {
+	counter := make([]byte, 0x10)
+	binary.BigEndian.PutUint64(counter, fh.sectionCtr)
+	binary.BigEndian.PutUint64(counter[8:], offset/0x10)
+	return counter
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"strconv"
 
 	"github.com/trembon/switch-library-manager/switchfs/_crypto"
@@ -13,6 +14,7 @@ import (
 
 type ncaHeader struct {
 	headerBytes    []byte
+	version        string // NCA0, NCA2 or NCA3
 	rightsId       []byte
 	titleId        []byte
 	distribution   byte
@@ -65,12 +67,24 @@ func DecryptNcaHeader(key string, encHeader []byte) (*ncaHeader, error) {
 
 	magic := string(decryptNcaHeader[0x200:0x204])
 
-	if magic == "NCA3" {
+	switch magic {
+	case "NCA3":
 		endOffset = 0xC00
 		decryptNcaHeader, err = _decryptNcaHeader(c, encHeader, endOffset, sectorSize, sector)
+		if err != nil {
+			return nil, err
+		}
+	case "NCA2", "NCA0":
+		// the fs headers are encrypted separately, each one as sector 0
+		tweak := getNintendoTweak(0)
+		for pos := 0x400; pos < 0xC00; pos += sectorSize {
+			c.Decrypt(decryptNcaHeader[pos:pos+sectorSize], encHeader[pos:pos+sectorSize], &tweak)
+		}
+	default:
+		return nil, errors.New("invalid NCA header, unknown magic (header_key may be invalid)")
 	}
 
-	result := ncaHeader{headerBytes: decryptNcaHeader}
+	result := ncaHeader{headerBytes: decryptNcaHeader, version: magic}
 
 	result.distribution = decryptNcaHeader[0x204:0x205][0]
 	result.contentType = decryptNcaHeader[0x205:0x206][0]
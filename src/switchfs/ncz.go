@@ -0,0 +1,242 @@
+package switchfs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// https://github.com/nicoboss/nsz#ncz-format
+const (
+	nczHeaderSize   = 0x4000
+	nczSectionMagic = "NCZSECTN"
+	nczBlockMagic   = "NCZBLOCK"
+	nczSectionSize  = 0x40
+)
+
+type nczSection struct {
+	offset        int64
+	size          int64
+	cryptoType    uint64
+	cryptoKey     []byte
+	cryptoCounter []byte
+}
+
+type nczHeader struct {
+	sections   []nczSection
+	dataOffset int64
+}
+
+func readNczHeader(reader io.ReaderAt, nczOffset int64) (*nczHeader, error) {
+	header := make([]byte, 0x10)
+	_, err := reader.ReadAt(header, nczOffset+nczHeaderSize)
+	if err != nil {
+		return nil, errors.New("failed to read NCZ header " + err.Error())
+	}
+	if string(header[:0x8]) != nczSectionMagic {
+		return nil, errors.New("Invalid NCZ header. Expected '" + nczSectionMagic + "', got '" + string(header[:0x8]) + "'")
+	}
+
+	sectionCount := binary.LittleEndian.Uint64(header[0x8:0x10])
+	if sectionCount == 0 || sectionCount > 0x100 {
+		return nil, fmt.Errorf("invalid NCZ section count %v", sectionCount)
+	}
+
+	sectionsBytes := make([]byte, nczSectionSize*sectionCount)
+	_, err = reader.ReadAt(sectionsBytes, nczOffset+nczHeaderSize+0x10)
+	if err != nil {
+		return nil, errors.New("failed to read NCZ sections " + err.Error())
+	}
+
+	result := &nczHeader{dataOffset: nczHeaderSize + 0x10 + int64(len(sectionsBytes))}
+	for i := 0; i < int(sectionCount); i++ {
+		sectionBytes := sectionsBytes[i*nczSectionSize : (i+1)*nczSectionSize]
+		result.sections = append(result.sections, nczSection{
+			offset:        int64(binary.LittleEndian.Uint64(sectionBytes[0x0:0x8])),
+			size:          int64(binary.LittleEndian.Uint64(sectionBytes[0x8:0x10])),
+			cryptoType:    binary.LittleEndian.Uint64(sectionBytes[0x10:0x18]),
+			cryptoKey:     sectionBytes[0x20:0x30],
+			cryptoCounter: sectionBytes[0x30:0x40],
+		})
+	}
+	return result, nil
+}
+
+// ncaSize returns the size of the original NCA
+func (h *nczHeader) ncaSize() int64 {
+	size := int64(nczHeaderSize)
+	for _, section := range h.sections {
+		if section.offset+section.size > size {
+			size = section.offset + section.size
+		}
+	}
+	return size
+}
+
+// decompressNcz writes the original NCA of the NCZ located at [nczOffset, nczOffset+nczSize) to w,
+// the NCZ stores the NCA data from 0x4000 onwards decrypted, so AES-CTR sections are encrypted again.
+func decompressNcz(reader io.ReaderAt, nczOffset int64, nczSize int64, w io.Writer) error {
+	header, err := readNczHeader(reader, nczOffset)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, io.NewSectionReader(reader, nczOffset, nczHeaderSize))
+	if err != nil {
+		return err
+	}
+
+	compressed := io.NewSectionReader(reader, nczOffset+header.dataOffset, nczSize-header.dataOffset)
+	magic := make([]byte, 0x8)
+	_, err = compressed.ReadAt(magic, 0)
+	if err != nil {
+		return errors.New("failed to read NCZ data " + err.Error())
+	}
+
+	var data io.Reader
+	if string(magic) == nczBlockMagic {
+		data, err = newNczBlockReader(compressed)
+		if err != nil {
+			return err
+		}
+	} else {
+		decoder, err := zstd.NewReader(compressed)
+		if err != nil {
+			return err
+		}
+		defer decoder.Close()
+		data = decoder
+	}
+
+	pos := int64(nczHeaderSize)
+	for _, section := range header.sections {
+		end := section.offset + section.size
+		if end <= pos {
+			continue
+		}
+		// data between the sections is stored as-is
+		if section.offset > pos {
+			_, err = io.CopyN(w, data, section.offset-pos)
+			if err != nil {
+				return errors.New("failed to decompress NCZ - " + err.Error())
+			}
+			pos = section.offset
+		}
+
+		sectionData := data
+		if section.cryptoType == 3 || section.cryptoType == 4 {
+			block, err := aes.NewCipher(section.cryptoKey)
+			if err != nil {
+				return err
+			}
+			ctr := make([]byte, 0x10)
+			copy(ctr, section.cryptoCounter[:0x8])
+			binary.BigEndian.PutUint64(ctr[0x8:], uint64(pos>>4))
+			sectionData = &cipher.StreamReader{S: cipher.NewCTR(block, ctr), R: data}
+		}
+
+		_, err = io.CopyN(w, sectionData, end-pos)
+		if err != nil {
+			return errors.New("failed to decompress NCZ - " + err.Error())
+		}
+		pos = end
+	}
+	return nil
+}
+
+type nczBlockReader struct {
+	reader           io.ReaderAt
+	decoder          *zstd.Decoder
+	blockSize        int64
+	decompressedSize int64
+	blockOffsets     []int64
+	blockSizes       []int64
+	nextBlock        int
+	block            []byte
+}
+
+func newNczBlockReader(reader io.ReaderAt) (*nczBlockReader, error) {
+	header := make([]byte, 0x18)
+	_, err := reader.ReadAt(header, 0)
+	if err != nil {
+		return nil, errors.New("failed to read NCZ block header " + err.Error())
+	}
+
+	blockSizeExponent := header[0xB]
+	if blockSizeExponent < 14 || blockSizeExponent > 32 {
+		return nil, fmt.Errorf("invalid NCZ block size exponent %v", blockSizeExponent)
+	}
+	blockCount := binary.LittleEndian.Uint32(header[0xC:0x10])
+	sizesBytes := make([]byte, 0x4*int64(blockCount))
+	_, err = reader.ReadAt(sizesBytes, 0x18)
+	if err != nil {
+		return nil, errors.New("failed to read NCZ block sizes " + err.Error())
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &nczBlockReader{
+		reader:           reader,
+		decoder:          decoder,
+		blockSize:        1 << blockSizeExponent,
+		decompressedSize: int64(binary.LittleEndian.Uint64(header[0x10:0x18])),
+	}
+	offset := int64(0x18 + len(sizesBytes))
+	for i := 0; i < int(blockCount); i++ {
+		size := int64(binary.LittleEndian.Uint32(sizesBytes[i*0x4 : (i+1)*0x4]))
+		result.blockOffsets = append(result.blockOffsets, offset)
+		result.blockSizes = append(result.blockSizes, size)
+		offset += size
+	}
+	return result, nil
+}
+
+func (br *nczBlockReader) Read(p []byte) (int, error) {
+	for len(br.block) == 0 {
+		if br.nextBlock >= len(br.blockSizes) {
+			br.decoder.Close()
+			return 0, io.EOF
+		}
+		err := br.readBlock(br.nextBlock)
+		if err != nil {
+			return 0, err
+		}
+		br.nextBlock++
+	}
+	n := copy(p, br.block)
+	br.block = br.block[n:]
+	return n, nil
+}
+
+func (br *nczBlockReader) readBlock(index int) error {
+	decompressedSize := br.blockSize
+	if index == len(br.blockSizes)-1 {
+		decompressedSize = br.decompressedSize - br.blockSize*int64(index)
+	}
+
+	compressed := make([]byte, br.blockSizes[index])
+	_, err := br.reader.ReadAt(compressed, br.blockOffsets[index])
+	if err != nil {
+		return fmt.Errorf("failed to read NCZ block %v - %v", index, err)
+	}
+
+	// blocks which do not compress are stored as-is
+	if int64(len(compressed)) >= decompressedSize {
+		br.block = compressed[:decompressedSize]
+		return nil
+	}
+
+	br.block, err = br.decoder.DecodeAll(compressed, make([]byte, 0, decompressedSize))
+	if err != nil {
+		return fmt.Errorf("failed to decompress NCZ block %v - %v", index, err)
+	}
+	return nil
+}
@@ -21,6 +21,8 @@ func ReadNspMetadata(filePath string) (map[string]*ContentMetaAttributes, error)
 
 	defer file.Close()
 
+	loadTickets(file, pfs0, 0)
+
 	contentMap := map[string]*ContentMetaAttributes{}
 
 	for _, pfs0File := range pfs0.Files {
@@ -48,6 +50,10 @@ func ReadNspMetadata(filePath string) (map[string]*ContentMetaAttributes, error)
 				currCnmt.Ncap = nacp
 			}
 
+			currCnmt.KeyGeneration, err = readNcaKeyGeneration(file, fileOffset)
+			if err != nil {
+				return nil, err
+			}
 			contentMap[currCnmt.TitleId] = currCnmt
 
 		} /*else if strings.Contains(pfs0File.Name, ".cnmt.xml") {
@@ -0,0 +1,438 @@
+package switchfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+const mediaUnitSize = 0x200
+
+// PartitionEntry is a file of a PFS0/HFS0 partition written by WritePfs0/WriteHfs0, the content
+// is streamed by Write. Size is -1 when only known once the entry has been written.
+// For HFS0 partitions the hash of the first 0x200 bytes is computed while writing when Hash is nil.
+type PartitionEntry struct {
+	Name             string
+	Size             int64
+	HashedRegionSize uint32
+	Hash             []byte
+	Write            func(w io.Writer) error
+}
+
+// WritePfs0 writes a PFS0 (NSP) with the given entries to outputPath
+func WritePfs0(outputPath string, entries []*PartitionEntry) error {
+	return createContainer(outputPath, func(cw *containerWriter) error {
+		_, err := writePartition(cw, pfs0Magic, entries)
+		return err
+	})
+}
+
+// WriteHfs0 writes a HFS0 (XCI partition) with the given entries to outputPath
+func WriteHfs0(outputPath string, entries []*PartitionEntry) error {
+	return createContainer(outputPath, func(cw *containerWriter) error {
+		_, err := writePartition(cw, hfs0Magic, entries)
+		return err
+	})
+}
+
+// NewFileEntry creates an entry streaming the content of the file at filePath
+func NewFileEntry(filePath string, name string) (*PartitionEntry, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errors.New(filePath + " is a directory")
+	}
+	size := info.Size()
+	return &PartitionEntry{
+		Name: name,
+		Size: size,
+		Write: func(w io.Writer) error {
+			file, err := os.Open(filePath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(w, io.NewSectionReader(file, 0, size))
+			return err
+		},
+	}, nil
+}
+
+type containerWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+	pos  int64
+}
+
+// createContainer writes a container to a temporary file next to outputPath,
+// which is only renamed to outputPath once everything was written successfully.
+func createContainer(outputPath string, write func(cw *containerWriter) error) error {
+	tmpPath := outputPath + ".tmp"
+	output, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	cw := &containerWriter{file: output, buf: bufio.NewWriterSize(output, 0x100000)}
+	err = write(cw)
+	if err == nil {
+		err = cw.buf.Flush()
+	}
+	if err == nil {
+		err = output.Sync()
+	}
+	closeErr := output.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, outputPath)
+}
+
+func (cw *containerWriter) Write(p []byte) (int, error) {
+	n, err := cw.buf.Write(p)
+	cw.pos += int64(n)
+	return n, err
+}
+
+// WriteAt overwrites already written data, used to patch headers once the entry sizes are known
+func (cw *containerWriter) WriteAt(p []byte, off int64) (int, error) {
+	err := cw.buf.Flush()
+	if err != nil {
+		return 0, err
+	}
+	return cw.file.WriteAt(p, off)
+}
+
+// ReadAt reads back already written data
+func (cw *containerWriter) ReadAt(p []byte, off int64) (int, error) {
+	err := cw.buf.Flush()
+	if err != nil {
+		return 0, err
+	}
+	return cw.file.ReadAt(p, off)
+}
+
+// buildPartitionHeader builds a PFS0/HFS0 header, HFS0 headers and entries are aligned to
+// the gamecard media unit while PFS0 entries are stored back to back.
+func buildPartitionHeader(magic string, entries []*PartitionEntry) []byte {
+	entrySize := PfsfileEntryTableSize
+	alignment := int64(0x10)
+	if magic == hfs0Magic {
+		entrySize = HfsfileEntryTableSize
+		alignment = mediaUnitSize
+	}
+
+	var stringTable []byte
+	for _, entry := range entries {
+		stringTable = append(stringTable, []byte(entry.Name)...)
+		stringTable = append(stringTable, 0x0)
+	}
+	tableSize := int64(0x10 + entrySize*len(entries))
+	headerSize := alignUp(tableSize+int64(len(stringTable)), alignment)
+	stringTable = append(stringTable, make([]byte, headerSize-tableSize-int64(len(stringTable)))...)
+
+	header := make([]byte, 0x10, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint32(header[0x4:0x8], uint32(len(entries)))
+	binary.LittleEndian.PutUint32(header[0x8:0xC], uint32(len(stringTable)))
+
+	dataOffset := int64(0)
+	nameOffset := 0
+	for _, entry := range entries {
+		if magic == hfs0Magic {
+			dataOffset = alignUp(dataOffset, alignment)
+		}
+		size := entry.Size
+		if size < 0 {
+			size = 0
+		}
+		entryBytes := make([]byte, entrySize)
+		binary.LittleEndian.PutUint64(entryBytes[0x0:0x8], uint64(dataOffset))
+		binary.LittleEndian.PutUint64(entryBytes[0x8:0x10], uint64(size))
+		binary.LittleEndian.PutUint32(entryBytes[0x10:0x14], uint32(nameOffset))
+		if magic == hfs0Magic {
+			binary.LittleEndian.PutUint32(entryBytes[0x14:0x18], entry.HashedRegionSize)
+			copy(entryBytes[0x20:0x40], entry.Hash)
+		}
+		header = append(header, entryBytes...)
+		dataOffset += size
+		nameOffset += len(entry.Name) + 1
+	}
+	return append(header, stringTable...)
+}
+
+// writePartition writes a PFS0/HFS0 partition, entries with an unknown size get it assigned
+// once written and the header is patched afterwards. The final header is returned.
+func writePartition(cw *containerWriter, magic string, entries []*PartitionEntry) ([]byte, error) {
+	start := cw.pos
+	header := buildPartitionHeader(magic, entries)
+	_, err := cw.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if magic == hfs0Magic {
+			_, err = cw.Write(make([]byte, alignUp(cw.pos-start, mediaUnitSize)-(cw.pos-start)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		entryStart := cw.pos
+		var w io.Writer = cw
+		var regionHash *regionHasher
+		if magic == hfs0Magic && entry.Hash == nil {
+			regionHash = &regionHasher{hash: sha256.New()}
+			w = io.MultiWriter(cw, regionHash)
+		}
+		err = entry.Write(w)
+		if err != nil {
+			return nil, err
+		}
+		if regionHash != nil && entry.Hash == nil {
+			entry.HashedRegionSize = uint32(regionHash.size)
+			entry.Hash = regionHash.hash.Sum(nil)
+		}
+		if entry.Size >= 0 && cw.pos-entryStart != entry.Size {
+			return nil, errors.New("unexpected size of " + entry.Name)
+		}
+		entry.Size = cw.pos - entryStart
+	}
+
+	finalHeader := buildPartitionHeader(magic, entries)
+	if !bytes.Equal(header, finalHeader) {
+		_, err = cw.WriteAt(finalHeader, start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return finalHeader, nil
+}
+
+// regionHasher hashes the first media unit written to it, the region HFS0 entries are validated by
+type regionHasher struct {
+	hash hash.Hash
+	size int64
+}
+
+func (r *regionHasher) Write(p []byte) (int, error) {
+	if remaining := mediaUnitSize - r.size; remaining > 0 {
+		data := p[:min(int64(len(p)), remaining)]
+		r.hash.Write(data)
+		r.size += int64(len(data))
+	}
+	return len(p), nil
+}
+
+// NewSectionEntry creates an entry copying size bytes at offset of file, partitions (HFS0) keep their header hash
+func NewSectionEntry(file io.ReaderAt, offset int64, size int64, name string) (*PartitionEntry, error) {
+	hashedRegionSize, hash, err := hashRegion(file, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	return &PartitionEntry{
+		Name:             name,
+		Size:             size,
+		HashedRegionSize: hashedRegionSize,
+		Hash:             hash,
+		Write: func(w io.Writer) error {
+			_, err := io.Copy(w, io.NewSectionReader(file, offset, size))
+			return err
+		},
+	}, nil
+}
+
+// hashRegion hashes the region HFS0 entries are validated by, for partitions it is their header
+func hashRegion(file io.ReaderAt, offset int64, size int64) (uint32, []byte, error) {
+	regionSize := min(size, mediaUnitSize)
+	if size >= 0x10 {
+		magic := make([]byte, 0x4)
+		_, err := file.ReadAt(magic, offset)
+		if err != nil {
+			return 0, nil, err
+		}
+		if string(magic) == hfs0Magic {
+			partition, err := readPfs0(file, offset)
+			if err != nil {
+				return 0, nil, err
+			}
+			regionSize = int64(partition.HeaderLen)
+		}
+	}
+
+	region := make([]byte, regionSize)
+	_, err := file.ReadAt(region, offset)
+	if err != nil {
+		return 0, nil, err
+	}
+	hash := sha256.Sum256(region)
+	return uint32(regionSize), hash[:], nil
+}
+
+// rebuildNsp writes a NSP with the entries created from the source PFS0
+func rebuildNsp(file io.ReaderAt, cw *containerWriter,
+	createEntries func(partition *PFS0, partitionOffset int64) ([]*PartitionEntry, error)) error {
+	pfs0, err := readPfs0(file, 0x0)
+	if err != nil {
+		return errors.New("Invalid NSP file, reason - [" + err.Error() + "]")
+	}
+
+	entries, err := createEntries(pfs0, 0)
+	if err != nil {
+		return err
+	}
+	_, err = writePartition(cw, pfs0Magic, entries)
+	return err
+}
+
+// rebuildXci writes a XCI with the secure partition entries created from the source secure partition,
+// everything before the root partition (xci header, cert area) and the other partitions are kept,
+// only the header fields describing the rebuilt partitions are updated.
+func rebuildXci(file io.ReaderAt, cw *containerWriter,
+	createEntries func(partition *PFS0, partitionOffset int64) ([]*PartitionEntry, error)) error {
+	header := make([]byte, 0x200)
+	_, err := file.ReadAt(header, 0)
+	if err != nil {
+		return err
+	}
+	rootPartitionOffset := int64(binary.LittleEndian.Uint64(header[0x130:0x138]))
+	rootHfs0, err := readPfs0(file, rootPartitionOffset)
+	if err != nil {
+		return err
+	}
+
+	var rootEntries []*PartitionEntry
+	var secureEntry *PartitionEntry
+	for _, hfs0File := range rootHfs0.Files {
+		partitionOffset := rootPartitionOffset + int64(hfs0File.StartOffset)
+		if hfs0File.Name != "secure" {
+			entry, err := NewSectionEntry(file, partitionOffset, int64(hfs0File.Size), hfs0File.Name)
+			if err != nil {
+				return err
+			}
+			rootEntries = append(rootEntries, entry)
+			continue
+		}
+
+		secureHfs0, err := readPfs0(file, partitionOffset)
+		if err != nil {
+			return err
+		}
+		secureEntries, err := createEntries(secureHfs0, partitionOffset)
+		if err != nil {
+			return err
+		}
+		entry := &PartitionEntry{Name: hfs0File.Name, Size: -1}
+		entry.Write = func(w io.Writer) error {
+			secureHeader, err := writePartition(cw, hfs0Magic, secureEntries)
+			if err != nil {
+				return err
+			}
+			hash := sha256.Sum256(secureHeader)
+			entry.HashedRegionSize = uint32(len(secureHeader))
+			entry.Hash = hash[:]
+			return nil
+		}
+		secureEntry = entry
+		rootEntries = append(rootEntries, entry)
+	}
+	if secureEntry == nil {
+		return errors.New("secure partition not found")
+	}
+
+	prefix := make([]byte, rootPartitionOffset)
+	_, err = file.ReadAt(prefix, 0)
+	if err != nil {
+		return err
+	}
+	_, err = cw.Write(prefix)
+	if err != nil {
+		return err
+	}
+	rootHeader, err := writePartition(cw, hfs0Magic, rootEntries)
+	if err != nil {
+		return err
+	}
+	_, err = cw.Write(make([]byte, alignUp(cw.pos, mediaUnitSize)-cw.pos))
+	if err != nil {
+		return err
+	}
+
+	secureOffset := rootPartitionOffset + int64(len(rootHeader))
+	for _, entry := range rootEntries {
+		secureOffset = alignUp(secureOffset, mediaUnitSize)
+		if entry == secureEntry {
+			break
+		}
+		secureOffset += entry.Size
+	}
+	rootHash := sha256.Sum256(rootHeader)
+	binary.LittleEndian.PutUint32(header[0x104:0x108], uint32(secureOffset/mediaUnitSize))
+	binary.LittleEndian.PutUint64(header[0x118:0x120], uint64(cw.pos/mediaUnitSize-1))
+	binary.LittleEndian.PutUint64(header[0x138:0x140], uint64(len(rootHeader)))
+	copy(header[0x140:0x160], rootHash[:])
+	_, err = cw.WriteAt(header, 0)
+	return err
+}
+
+// openContentPartition returns the partition holding the NCAs, the PFS0 of a NSP or the secure partition of a XCI
+func openContentPartition(file io.ReaderAt) (*PFS0, int64, error) {
+	xci, err := isXci(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if xci {
+		header := make([]byte, 0x200)
+		_, err = file.ReadAt(header, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		rootPartitionOffset := binary.LittleEndian.Uint64(header[0x130:0x138])
+		rootHfs0, err := readPfs0(file, int64(rootPartitionOffset))
+		if err != nil {
+			return nil, 0, err
+		}
+		secureHfs0, secureOffset, err := readSecurePartition(file, rootHfs0, rootPartitionOffset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if secureHfs0 == nil {
+			return nil, 0, errors.New("secure partition not found")
+		}
+		return secureHfs0, secureOffset, nil
+	}
+
+	pfs0, err := readPfs0(file, 0x0)
+	if err != nil {
+		return nil, 0, errors.New("Invalid NSP file, reason - [" + err.Error() + "]")
+	}
+	return pfs0, 0, nil
+}
+
+func isXci(file io.ReaderAt) (bool, error) {
+	header := make([]byte, 0x200)
+	_, err := file.ReadAt(header, 0)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return string(header[0x100:0x104]) == "HEAD", nil
+}
+
+func alignUp(value int64, alignment int64) int64 {
+	return (value + alignment - 1) / alignment * alignment
+}
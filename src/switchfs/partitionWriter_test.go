@@ -0,0 +1,82 @@
+package switchfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPartition(t *testing.T, magic string) (*os.File, *PFS0) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "content.bin")
+	if err := os.WriteFile(filePath, bytes.Repeat([]byte{0xAB}, 0x300), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fileEntry, err := NewFileEntry(filePath, "content.nca")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	streamedEntry := &PartitionEntry{Name: "title.tik", Size: -1, Write: func(w io.Writer) error {
+		_, err := w.Write([]byte("ticket"))
+		return err
+	}}
+
+	outputPath := filepath.Join(dir, "output")
+	write := WritePfs0
+	if magic == hfs0Magic {
+		write = WriteHfs0
+	}
+	if err = write(outputPath, []*PartitionEntry{fileEntry, streamedEntry}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	partition, err := readPfs0(file, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(partition.Files) != 2 || partition.Files[0].Name != "content.nca" || partition.Files[1].Name != "title.tik" {
+		t.Fatalf("unexpected files %v", partition.Files)
+	}
+	ticket := make([]byte, partition.Files[1].Size)
+	if _, err = file.ReadAt(ticket, int64(partition.Files[1].StartOffset)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(ticket) != "ticket" {
+		t.Fatalf("expected ticket got %v", string(ticket))
+	}
+	return file, partition
+}
+
+func TestWritePfs0(t *testing.T) {
+	_, partition := writeTestPartition(t, pfs0Magic)
+	if partition.HeaderLen%0x10 != 0 {
+		t.Errorf("expected header aligned to 0x10, got %#x", partition.HeaderLen)
+	}
+	if partition.Files[1].StartOffset != uint64(partition.HeaderLen)+0x300 {
+		t.Errorf("expected entries stored back to back, got offset %#x", partition.Files[1].StartOffset)
+	}
+}
+
+func TestWriteHfs0(t *testing.T) {
+	file, partition := writeTestPartition(t, hfs0Magic)
+	if partition.HeaderLen%mediaUnitSize != 0 || partition.Files[1].StartOffset%mediaUnitSize != 0 {
+		t.Errorf("expected header and entries aligned to 0x200")
+	}
+
+	entry := make([]byte, HfsfileEntryTableSize)
+	if _, err := file.ReadAt(entry, 0x10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := sha256.Sum256(bytes.Repeat([]byte{0xAB}, mediaUnitSize))
+	if entry[0x14] != 0x00 || entry[0x15] != 0x02 || !bytes.Equal(entry[0x20:0x40], expected[:]) {
+		t.Errorf("unexpected hash fields %x", entry[0x14:0x40])
+	}
+}
@@ -0,0 +1,85 @@
+package switchfs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// SplitFile extracts every title of a multi-content NSP/XCI (or NSZ/XCZ) into a standalone NSP,
+// outputPaths maps the title ids (as in ContentMetaAttributes.TitleId) to the file to create,
+// titles missing from the map are not extracted. Each NSP holds the NCAs listed in the cnmt,
+// the cnmt itself and the tickets/certificates of the title.
+func SplitFile(filePath string, outputPaths map[string]string) error {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	partition, partitionOffset, err := openContentPartition(file)
+	if err != nil {
+		return err
+	}
+
+	for _, pfs0File := range partition.Files {
+		if !strings.Contains(pfs0File.Name, "cnmt.nca") {
+			continue
+		}
+
+		_, section, err := openMetaNcaDataSection(file, partitionOffset+int64(pfs0File.StartOffset))
+		if err != nil {
+			return err
+		}
+		currPfs0, err := readPfs0(bytes.NewReader(section), 0x0)
+		if err != nil {
+			return err
+		}
+		currCnmt, err := readBinaryCnmt(currPfs0, section)
+		if err != nil {
+			return err
+		}
+		outputPath, ok := outputPaths[currCnmt.TitleId]
+		if !ok {
+			continue
+		}
+
+		// the cnmt nca and the files named after the NCAs of the title (.cnmt.xml, .nacp.xml, ...)
+		prefixes := []string{strings.ToLower(strings.TrimSuffix(pfs0File.Name, ".cnmt.nca"))}
+		for _, content := range readCnmtContents(section[currPfs0.Files[0].StartOffset:]) {
+			if getNcaById(partition, content.ID) == nil {
+				// delta fragments are usually stripped from dumped updates
+				if content.Type == "DeltaFragment" {
+					continue
+				}
+				return errors.New("missing NCA " + content.ID + " of " + currCnmt.TitleId)
+			}
+			prefixes = append(prefixes, content.ID)
+		}
+
+		var entries []*PartitionEntry
+		for _, entryFile := range partition.Files {
+			name := strings.ToLower(entryFile.Name)
+			include := (strings.HasSuffix(name, ".tik") || strings.HasSuffix(name, ".cert")) &&
+				strings.HasPrefix(name, strings.ToLower(currCnmt.TitleId))
+			for _, prefix := range prefixes {
+				include = include || strings.HasPrefix(name, prefix)
+			}
+			if !include {
+				continue
+			}
+			entry, err := NewSectionEntry(file, partitionOffset+int64(entryFile.StartOffset), int64(entryFile.Size), entryFile.Name)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		err = WritePfs0(outputPath, entries)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -84,23 +84,41 @@ func NewSplitFileReader(filePath string) (*splitFile, error) {
 }
 
 func (sp *splitFile) ReadAt(p []byte, off int64) (n int, err error) {
-	//calculate the part containing the offset
-	part := int(off / sp.chunkSize)
-
-	if len(sp.info) < part {
-		return 0, errors.New("missing part " + strconv.Itoa(part))
-	}
+	// a read can span multiple parts, so keep reading until p is full
+	for n < len(p) {
+		//calculate the part containing the offset
+		partOffset := off + int64(n)
+		part := int(partOffset / sp.chunkSize)
+
+		if len(sp.info) <= part {
+			if n == 0 {
+				return 0, errors.New("missing part " + strconv.Itoa(part))
+			}
+			return n, io.EOF
+		}
 
-	if len(sp.files) == 0 || sp.files[part] == nil {
-		file, _ := _openFile(path.Join(sp.path, sp.info[part].Name()))
-		sp.files[part] = file
-	}
-	off = off - sp.chunkSize*int64(part)
+		if sp.files[part] == nil {
+			file, err := _openFile(path.Join(sp.path, sp.info[part].Name()))
+			if err != nil {
+				return n, err
+			}
+			sp.files[part] = file
+		}
+		partOffset = partOffset - sp.chunkSize*int64(part)
 
-	if off < 0 || off > sp.info[part].Size() {
-		return 0, errors.New("offset is out of bounds")
+		if partOffset < 0 || partOffset > sp.info[part].Size() {
+			return n, errors.New("offset is out of bounds")
+		}
+		read, err := sp.files[part].ReadAt(p[n:], partOffset)
+		n += read
+		if err == io.EOF && read > 0 && part+1 < len(sp.info) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
 	}
-	return sp.files[part].ReadAt(p, off)
+	return n, nil
 }
 
 func _openFile(path string) (*os.File, error) {
@@ -0,0 +1,95 @@
+package switchfs
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/trembon/switch-library-manager/settings"
+	"github.com/trembon/switch-library-manager/switchfs/_crypto"
+	"go.uber.org/zap"
+)
+
+//https://switchbrew.org/wiki/Ticket
+
+const ticketTitleKeyTypeCommon = 0x0
+
+// size of the signature (including padding) by signature type
+var ticketSignatureSizes = map[uint32]int{
+	0x010000: 0x200 + 0x3C, // RSA_4096 SHA1
+	0x010001: 0x100 + 0x3C, // RSA_2048 SHA1
+	0x010002: 0x3C + 0x40,  // ECDSA SHA1
+	0x010003: 0x200 + 0x3C, // RSA_4096 SHA256
+	0x010004: 0x100 + 0x3C, // RSA_2048 SHA256
+	0x010005: 0x3C + 0x40,  // ECDSA SHA256
+}
+
+// readTicket returns the rights id and the encrypted title key of a common ticket
+func readTicket(ticket []byte) (string, string, error) {
+	if len(ticket) < 0x4 {
+		return "", "", errors.New("invalid ticket")
+	}
+	signatureType := binary.LittleEndian.Uint32(ticket[0x0:0x4])
+	signatureSize, ok := ticketSignatureSizes[signatureType]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported ticket signature type [%x]", signatureType)
+	}
+	data := ticket[0x4+signatureSize:]
+	if len(data) < 0x170 {
+		return "", "", errors.New("invalid ticket")
+	}
+	if data[0x141] != ticketTitleKeyTypeCommon {
+		return "", "", errors.New("personalized tickets are not supported")
+	}
+	return hex.EncodeToString(data[0x160:0x170]), hex.EncodeToString(data[0x40:0x50]), nil
+}
+
+// loadTickets adds the title keys of the tickets stored in a partition to the known title keys
+func loadTickets(file io.ReaderAt, partition *PFS0, partitionOffset int64) {
+	keys, _ := settings.SwitchKeys()
+	if keys == nil || partition == nil {
+		return
+	}
+	for _, pfs0File := range partition.Files {
+		if !strings.HasSuffix(strings.ToLower(pfs0File.Name), ".tik") || pfs0File.Size > 0x1000 {
+			continue
+		}
+		ticket := make([]byte, pfs0File.Size)
+		_, err := file.ReadAt(ticket, partitionOffset+int64(pfs0File.StartOffset))
+		if err != nil {
+			zap.S().Debugf("Failed to read ticket %v [%v]", pfs0File.Name, err)
+			continue
+		}
+		rightsId, titleKey, err := readTicket(ticket)
+		if err != nil {
+			zap.S().Debugf("Failed to read ticket %v [%v]", pfs0File.Name, err)
+			continue
+		}
+		keys.AddTitleKey(rightsId, titleKey)
+	}
+}
+
+// getNcaTitleKey decrypts the title key of a NCA using rights id based encryption
+func getNcaTitleKey(ncaHeader *ncaHeader) ([]byte, error) {
+	keys, _ := settings.SwitchKeys()
+	if keys == nil {
+		return nil, ErrMissingKey
+	}
+
+	rightsId := hex.EncodeToString(ncaHeader.rightsId)
+	encTitleKey, _ := hex.DecodeString(keys.GetTitleKey(rightsId))
+	if len(encTitleKey) != 0x10 {
+		return nil, missingKeyError("title key for rights id " + rightsId)
+	}
+
+	keyName := fmt.Sprintf("titlekek_%02x", ncaHeader.getKeyRevision())
+	titleKek, _ := hex.DecodeString(keys.GetKey(keyName))
+	if len(titleKek) != 0x10 {
+		return nil, missingKeyError(keyName)
+	}
+
+	return _crypto.DecryptAes128Ecb(encTitleKey, titleKek), nil
+}
@@ -0,0 +1,161 @@
+package switchfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// padding written by the gamecard dumpers after the valid data of a XCI
+const xciPaddingByte = 0xFF
+
+// XciInfo describes the size of a XCI compared to the data it holds and the gamecard it was dumped from
+type XciInfo struct {
+	CartridgeSize int64 `json:"cartridge_size"` // 0 when the gamecard size is unknown
+	DataSize      int64 `json:"data_size"`
+	FileSize      int64 `json:"file_size"`
+}
+
+// IsTrimmed returns true when the file holds no padding after the valid data
+func (x *XciInfo) IsTrimmed() bool {
+	return x.FileSize <= x.DataSize
+}
+
+// TrimSavings returns the number of bytes trimming the file would free
+func (x *XciInfo) TrimSavings() int64 {
+	if x.IsTrimmed() {
+		return 0
+	}
+	return x.FileSize - x.DataSize
+}
+
+// usable size of the gamecards, 72MB of every GB are reserved
+var cartridgeSizes = map[byte]int64{
+	0xFA: 1,
+	0xF8: 2,
+	0xF0: 4,
+	0xE0: 8,
+	0xE1: 16,
+	0xE2: 32,
+}
+
+func getCartridgeSize(romSize byte) int64 {
+	gb, ok := cartridgeSizes[romSize]
+	if !ok {
+		return 0
+	}
+	return gb * (1024 - 72) * 1024 * 1024
+}
+
+// ReadXciInfo reads the used and gamecard size of a XCI from its header
+func ReadXciInfo(filePath string) (*XciInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return readXciInfo(file)
+}
+
+func readXciInfo(file *os.File) (*XciInfo, error) {
+	header := make([]byte, 0x200)
+	_, err := file.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+	if string(header[0x100:0x104]) != "HEAD" {
+		return nil, errors.New("Invalid XCI headerBytes. Expected 'HEAD', got '" + string(header[0x100:0x104]) + "'")
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	validDataEnd := binary.LittleEndian.Uint64(header[0x118:0x120])
+	return &XciInfo{
+		CartridgeSize: getCartridgeSize(header[0x10D]),
+		DataSize:      int64(validDataEnd+1) * mediaUnitSize,
+		FileSize:      stat.Size(),
+	}, nil
+}
+
+// TrimXci removes the padding after the valid data of a XCI, the padding is checked
+// before the file is truncated so no data is lost. The number of freed bytes is returned.
+func TrimXci(filePath string) (int64, error) {
+	if !strings.HasSuffix(strings.ToLower(filePath), ".xci") {
+		return 0, errors.New("only XCI files can be trimmed")
+	}
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	info, err := readXciInfo(file)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsTrimmed() {
+		return 0, errors.New("file is already trimmed")
+	}
+
+	buffer := make([]byte, 0x100000)
+	padding := bytes.Repeat([]byte{xciPaddingByte}, len(buffer))
+	for offset := info.DataSize; offset < info.FileSize; offset += int64(len(buffer)) {
+		n, err := file.ReadAt(buffer, offset)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if !bytes.Equal(buffer[:n], padding[:n]) {
+			return 0, errors.New("unexpected data after the end of the valid data, the file was not trimmed")
+		}
+	}
+
+	err = file.Truncate(info.DataSize)
+	if err != nil {
+		return 0, err
+	}
+	return info.TrimSavings(), file.Sync()
+}
+
+// UntrimXci pads a trimmed XCI back to the size of its gamecard
+func UntrimXci(filePath string) error {
+	if !strings.HasSuffix(strings.ToLower(filePath), ".xci") {
+		return errors.New("only XCI files can be untrimmed")
+	}
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := readXciInfo(file)
+	if err != nil {
+		return err
+	}
+	if info.CartridgeSize == 0 {
+		return errors.New("unknown gamecard size")
+	}
+	if info.FileSize >= info.CartridgeSize {
+		return errors.New("file is not trimmed")
+	}
+
+	padding := bytes.Repeat([]byte{xciPaddingByte}, 0x100000)
+	for offset := info.FileSize; offset < info.CartridgeSize; offset += int64(len(padding)) {
+		size := min(int64(len(padding)), info.CartridgeSize-offset)
+		_, err = file.WriteAt(padding[:size], offset)
+		if err != nil {
+			// keep the file as it was
+			file.Truncate(info.FileSize)
+			return err
+		}
+	}
+	return file.Sync()
+}
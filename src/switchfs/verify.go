@@ -0,0 +1,146 @@
+package switchfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type CorruptedContent struct {
+	NcaId  string
+	Type   string
+	Reason string
+}
+
+func (c CorruptedContent) String() string {
+	return fmt.Sprintf("%v [%v] - %v", c.NcaId, c.Type, c.Reason)
+}
+
+// VerifyContent streams every NCA listed in the cnmt files of an NSP/NSZ/XCI/XCZ (or a split file), NCZ entries decompressed,
+// and checks its size and SHA-256 against the values recorded in the cnmt.
+// An error is returned only when the file could not be verified at all.
+func VerifyContent(filePath string) ([]CorruptedContent, error) {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	partition, partitionOffset, err := openContentPartition(file)
+	if err != nil {
+		return nil, err
+	}
+	return verifyPartition(file, partition, partitionOffset)
+}
+
+func verifyPartition(file io.ReaderAt, partition *PFS0, partitionOffset int64) ([]CorruptedContent, error) {
+	var result []CorruptedContent
+	for _, pfs0File := range partition.Files {
+		if !strings.Contains(pfs0File.Name, "cnmt.nca") {
+			continue
+		}
+
+		_, section, err := openMetaNcaDataSection(file, partitionOffset+int64(pfs0File.StartOffset))
+		if err != nil {
+			return nil, err
+		}
+		currPfs0, err := readPfs0(bytes.NewReader(section), 0x0)
+		if err != nil {
+			return nil, err
+		}
+		if len(currPfs0.Files) != 1 {
+			return nil, errors.New("unexpected pfs0")
+		}
+
+		for _, content := range readCnmtContents(section[currPfs0.Files[0].StartOffset:]) {
+			if content.Type == "Meta" {
+				continue
+			}
+			corrupted := verifyNca(file, partition, partitionOffset, content)
+			if corrupted != nil {
+				result = append(result, *corrupted)
+			}
+		}
+	}
+	return result, nil
+}
+
+func verifyNca(file io.ReaderAt, partition *PFS0, partitionOffset int64, content Content) *CorruptedContent {
+	ncaEntry := getNcaById(partition, content.ID)
+	if ncaEntry == nil {
+		// delta fragments are usually stripped from dumped updates
+		if content.Type == "DeltaFragment" {
+			return nil
+		}
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type, Reason: "missing NCA"}
+	}
+
+	if strings.HasSuffix(strings.ToLower(ncaEntry.Name), ".ncz") {
+		return verifyNcz(file, partitionOffset, ncaEntry, content)
+	}
+
+	expectedSize, _ := strconv.ParseUint(content.Size, 10, 64)
+	if ncaEntry.Size != expectedSize {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type,
+			Reason: fmt.Sprintf("size mismatch (expected %v, got %v)", expectedSize, ncaEntry.Size)}
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.NewSectionReader(file, partitionOffset+int64(ncaEntry.StartOffset), int64(ncaEntry.Size)))
+	if err != nil {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type, Reason: "failed to read NCA - " + err.Error()}
+	}
+	if uint64(n) != ncaEntry.Size {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type,
+			Reason: fmt.Sprintf("truncated (expected %v bytes, read %v)", ncaEntry.Size, n)}
+	}
+	if fmt.Sprintf("%x", hash.Sum(nil)) != content.Hash {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type, Reason: "hash mismatch"}
+	}
+	return nil
+}
+
+// verifyNcz checks the size and SHA-256 of the original NCA, decompressed from the NCZ entry
+func verifyNcz(file io.ReaderAt, partitionOffset int64, nczEntry *fileEntry, content Content) *CorruptedContent {
+	nczOffset := partitionOffset + int64(nczEntry.StartOffset)
+	header, err := readNczHeader(file, nczOffset)
+	if err != nil {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type, Reason: err.Error()}
+	}
+
+	expectedSize, _ := strconv.ParseUint(content.Size, 10, 64)
+	if uint64(header.ncaSize()) != expectedSize {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type,
+			Reason: fmt.Sprintf("size mismatch (expected %v, got %v)", expectedSize, header.ncaSize())}
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{writer: hash}
+	if err = decompressNcz(file, nczOffset, int64(nczEntry.Size), counter); err != nil {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type, Reason: err.Error()}
+	}
+	if uint64(counter.written) != expectedSize {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type,
+			Reason: fmt.Sprintf("truncated (expected %v bytes, decompressed %v)", expectedSize, counter.written)}
+	}
+	if fmt.Sprintf("%x", hash.Sum(nil)) != content.Hash {
+		return &CorruptedContent{NcaId: content.ID, Type: content.Type, Reason: "hash mismatch"}
+	}
+	return nil
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
@@ -40,6 +40,17 @@ func ReadXciMetadata(filePath string) (map[string]*ContentMetaAttributes, error)
 		return nil, err
 	}
 
+	loadTickets(file, secureHfs0, secureOffset)
+
+	// split and compressed files are not trimmed
+	var xciInfo *XciInfo
+	if strings.HasSuffix(strings.ToLower(filePath), ".xci") {
+		xciInfo, err = ReadXciInfo(filePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	contentMap := map[string]*ContentMetaAttributes{}
 
 	for _, pfs0File := range secureHfs0.Files {
@@ -68,6 +79,11 @@ func ReadXciMetadata(filePath string) (map[string]*ContentMetaAttributes, error)
 				currCnmt.Ncap = nacp
 			}
 
+			currCnmt.Xci = xciInfo
+			currCnmt.KeyGeneration, err = readNcaKeyGeneration(file, fileOffset)
+			if err != nil {
+				return nil, err
+			}
 			contentMap[currCnmt.TitleId] = currCnmt
 
 		} /* else if strings.Contains(pfs0File.Name, ".cnmt.xml") {